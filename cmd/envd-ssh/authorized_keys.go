@@ -0,0 +1,37 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/remote/sshd"
+)
+
+// commandAuthorizedKeys replaces the authorized_keys file, it is used by
+// `envd envs share` and `envd ssh-key rotate` to modify the keys owned by root.
+var commandAuthorizedKeys = &cli.Command{
+	Name:      sshd.AuthorizedKeysCommand,
+	Usage:     "replace the authorized keys file with the keys in the argument",
+	ArgsUsage: "KEYS",
+	Hidden:    true,
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return errors.New("the keys are required")
+		}
+		return sshd.WriteAuthorizedKeys(c.String(flagAuthKey), []byte(c.Args().First()))
+	},
+}
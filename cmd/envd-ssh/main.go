@@ -21,7 +21,6 @@ import (
 	"os"
 
 	"github.com/cockroachdb/errors"
-	rawssh "github.com/gliderlabs/ssh"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
@@ -36,6 +35,12 @@ const (
 	flagNoAuth  = "no-auth"
 	flagPort    = "port"
 	flagShell   = "shell"
+	flagUser    = "user"
+
+	flagAllowedUsers = "allowed-users"
+
+	flagAuditLog        = "audit-log"
+	flagAuditRecordings = "audit-recordings"
 )
 
 func main() {
@@ -72,6 +77,30 @@ func main() {
 			Usage: "shell to use",
 			Value: "bash",
 		},
+		&cli.StringFlag{
+			Name:  flagUser,
+			Usage: "user to run the sessions of the keys without the user option, defaults to the current user",
+		},
+		&cli.StringSliceFlag{
+			Name:  flagAllowedUsers,
+			Usage: "users besides --user that the keys can be annotated with, the keys of the other users are rejected",
+		},
+		&cli.PathFlag{
+			Name:    flagAuditLog,
+			Usage:   "path to write the audit log of the sessions in JSON lines",
+			EnvVars: []string{config.EnvAuditLog},
+		},
+		&cli.PathFlag{
+			Name:    flagAuditRecordings,
+			Usage:   "directory to record the PTY sessions in asciicast v2 format, requires --" + flagAuditLog,
+			EnvVars: []string{config.EnvAuditRecordingDir},
+		},
+	}
+	app.Commands = []*cli.Command{
+		commandSupervise,
+		commandStatus,
+		commandSFTPServer,
+		commandAuthorizedKeys,
 	}
 
 	// Deal with debug flag.
@@ -106,10 +135,10 @@ func sshServer(c *cli.Context) error {
 	}
 
 	noAuth := c.Bool(flagNoAuth)
-	var keys []rawssh.PublicKey
+	var keys []sshd.AuthorizedKey
+	path := c.String(flagAuthKey)
 	if !noAuth {
 		var err error
-		path := c.String(flagAuthKey)
 		keys, err = sshd.LoadAuthorizedKeys(path)
 		if err != nil {
 			return errors.Wrapf(err, "failed to load authorized keys at %s", path)
@@ -128,6 +157,20 @@ func sshServer(c *cli.Context) error {
 		Port:           port,
 		Shell:          shell,
 		AuthorizedKeys: keys,
+		DefaultUser:    c.String(flagUser),
+		AllowedUsers:   c.StringSlice(flagAllowedUsers),
+	}
+	if !noAuth {
+		srv.AuthorizedKeysPath = path
+	}
+	if path := c.Path(flagAuditLog); path != "" {
+		srv.Auditor = &sshd.Auditor{
+			Path:         path,
+			RecordingDir: c.Path(flagAuditRecordings),
+		}
+		logrus.Infof("writing the audit log to %s", path)
+	} else if c.Path(flagAuditRecordings) != "" {
+		return errors.Newf("--%s requires --%s", flagAuditRecordings, flagAuditLog)
 	}
 
 	logrus.Infof("ssh server %s started in 0.0.0.0:%d", version.GetVersion().String(), srv.Port)
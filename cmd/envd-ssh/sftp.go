@@ -0,0 +1,49 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/remote/sshd"
+)
+
+// commandSFTPServer is started by the ssh server to serve sftp as the user
+// of the session.
+var commandSFTPServer = &cli.Command{
+	Name:   sshd.SFTPServerCommand,
+	Usage:  "serve sftp on the standard input and output",
+	Hidden: true,
+	Action: func(c *cli.Context) error {
+		return sshd.ServeSFTP(stdio{})
+	},
+}
+
+// stdio is the standard input and output as a stream.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdio) Close() error {
+	return os.Stdout.Close()
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
+)
+
+const (
+	flagSpec     = "spec"
+	flagSpecJSON = "spec-json"
+	flagStatus   = "status"
+	flagSocket   = "socket"
+)
+
+var commandSupervise = &cli.Command{
+	Name:  "supervise",
+	Usage: "run and supervise the processes in the container",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  flagSpec,
+			Usage: "path to the spec of the processes",
+		},
+		&cli.StringFlag{
+			Name:  flagSpecJSON,
+			Usage: "spec of the processes in JSON",
+		},
+		&cli.PathFlag{
+			Name:  flagStatus,
+			Usage: "path to write the status of the processes",
+			Value: config.ContainerSupervisorStatusPath,
+		},
+		&cli.PathFlag{
+			Name:  flagSocket,
+			Usage: "unix socket to serve the status of the processes",
+			Value: config.ContainerSupervisorSocketPath,
+		},
+	},
+	Action: supervise,
+}
+
+var commandStatus = &cli.Command{
+	Name:  "status",
+	Usage: "show the status of the supervised processes",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  flagSocket,
+			Usage: "unix socket of the supervisor",
+			Value: config.ContainerSupervisorSocketPath,
+		},
+	},
+	Action: status,
+}
+
+func supervise(c *cli.Context) error {
+	var spec supervisor.Spec
+	var err error
+	switch {
+	case c.String(flagSpecJSON) != "":
+		spec, err = supervisor.ParseSpec([]byte(c.String(flagSpecJSON)))
+	case c.Path(flagSpec) != "":
+		spec, err = supervisor.Load(c.Path(flagSpec))
+	default:
+		return errors.Newf("--%s or --%s is required", flagSpec, flagSpecJSON)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Stop the processes gracefully when the container is stopped.
+	ctx, stop := signal.NotifyContext(c.Context, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	s := supervisor.New(spec, c.Path(flagStatus))
+	if socket := c.Path(flagSocket); socket != "" {
+		go func() {
+			if err := s.Serve(ctx, socket); err != nil {
+				logrus.WithError(err).Warn("failed to serve the status")
+			}
+		}()
+	}
+	logrus.Infof("supervising %d processes", len(spec.Processes))
+	return s.Run(ctx)
+}
+
+func status(c *cli.Context) error {
+	statuses, err := supervisor.GetStatus(c.Context, c.Path(flagSocket))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
@@ -45,7 +45,11 @@ var _ = Describe("home context", func() {
 		socketAddr := "0.0.0.0:12345"
 
 		BeforeAll(func() {
-			err := home.GetManager().ContextCreate(contextName, builder, socketAddr, true)
+			err := home.GetManager().ContextCreate(types.Context{
+				Name:          contextName,
+				Builder:       builder,
+				BuilderSocket: socketAddr,
+			}, true)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
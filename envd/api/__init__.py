@@ -21,7 +21,7 @@ Please update the python file there instead of directly editing file inside envd
 :::
 """
 
-from typing import Optional
+from typing import List, Optional
 
 
 def base(os: str, language: str):
@@ -41,15 +41,32 @@ def shell(name: str):
     """
 
 
-def run(commands: str):
+def run(
+    commands: str, secrets: Optional[List[str]] = None, mount_ssh: bool = False
+):
     """Execute command
 
+    The commands are executed after the packages are installed and the files
+    are copied. With `config.ordered_build()`, they are executed in the order
+    they are declared, together with `io.copy` and the `install` rules.
+
     Args:
         commands (str): command to run during the building process
+        secrets (optional, List[str]): IDs of the secrets declared by
+            `config.secret` to mount for the commands
+        mount_ssh (bool): mount the SSH agent forwarded by
+            `envd build --ssh default`, the keys of the host can be used to
+            access the private git repositories
 
     Example:
     ```
     run(commands=["conda install -y -c conda-forge exa"])
+    config.secret(id="github", env="GITHUB_TOKEN")
+    run(commands=["git clone https://$GITHUB_TOKEN@github.com/org/repo"], secrets=["github"])
+    run(commands=[
+        "mkdir -p ~/.ssh && ssh-keyscan github.com >> ~/.ssh/known_hosts",
+        "git clone git@github.com:org/private.git",
+    ], mount_ssh=True)
     ```
     """
 
@@ -138,3 +138,64 @@ def rstudio_server():
     """
     Enable the RStudio Server (only work for `base(os="ubuntu20.04", language="r")`)
     """
+
+
+def users(names: List[str]):
+    """Create the users of the collaborators in the image
+
+    The sessions of the keys annotated with `user="<name>"` in the
+    authorized_keys file run as the user, e.g. the keys added by
+    `envd envs share --user <name>`. The users are in the group envd, thus
+    the permissions of the mounted directories are respected. The passwords
+    of the users and envd are locked, only envd can use sudo, and the keys
+    annotated with the undeclared users or root are rejected.
+
+    Example usage:
+    ```
+    config.users(names=["alice", "bob"])
+    ```
+
+    Args:
+        names (List[str]): names of the users
+    """
+
+
+def secret(id: str, target: Optional[str] = None, env: Optional[str] = None):
+    """Declare a secret for the build
+
+    The value is provided by `envd build --secret id=<id>,env=<HOST_ENV>` or
+    `envd build --secret id=<id>,src=<file>`. The secret is mounted into the
+    apt, pip and conda steps and the `run` steps which list it, and it is
+    never persisted in the image.
+
+    Example usage:
+    ```
+    config.secret(id="pypi", env="PIP_INDEX_URL")
+    config.secret(id="netrc", target="/home/envd/.netrc")
+    ```
+
+    Args:
+        id (str): ID of the secret
+        target (optional, str): path of the secret file in the build steps,
+            defaults to /run/secrets/<id> if env is not set
+        env (optional, str): environment variable of the secret in the
+            build steps
+    """
+
+
+def ordered_build():
+    """Build the steps in the order they are declared
+
+    By default the packages are installed first, then the files are copied
+    by `io.copy` and the `run` commands are executed last, no matter where
+    they are declared. With the ordered build, the steps are compiled in
+    the declared order, thus a package can be installed after a `run` and a
+    `run` can use the files copied after the packages.
+
+    Example usage:
+    ```
+    config.ordered_build()
+    run(["git clone https://github.com/tensorchord/envd.git /home/envd/envd"])
+    install.python_packages(name=["/home/envd/envd"])
+    ```
+    """
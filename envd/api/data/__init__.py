@@ -0,0 +1,69 @@
+# Copyright 2022 The envd Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Data sources
+
+::: tip
+Note that the documentation is automatically generated from [envd/api](https://github.com/tensorchord/envd/tree/main/envd/api) folder
+in [tensorchord/envd](https://github.com/tensorchord/envd/tree/main/envd/api) repo.
+Please update the python file there instead of directly editing file inside envd-docs repo.
+:::
+
+Data sources are mounted with `io.mount`, e.g.
+`io.mount(src=data.http(url="https://example.com/mnist.tar.gz"), dest="~/data")`
+"""
+
+
+def envd(name: str):
+    """Empty directory managed by envd in the cache dir
+
+    Args:
+        name (str): name of the data source
+    """
+
+
+def local(path: str):
+    """Existing directory on the host
+
+    Args:
+        path (str): path of the directory, `~` is expanded to the home dir and
+            relative paths are in the build context
+    """
+
+
+def s3(bucket: str, prefix: str = "", endpoint: str = ""):
+    """Objects synced from an S3-compatible bucket
+
+    Only changed objects are downloaded again. Credentials are read from the
+    `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY` and `AWS_SESSION_TOKEN`
+    environment variables, and the region from `AWS_REGION`.
+
+    Args:
+        bucket (str): name of the bucket
+        prefix (str): only sync the objects under the prefix
+        endpoint (str): S3-compatible endpoint, e.g. `http://localhost:9000` for MinIO.
+            Defaults to AWS S3 in the region, `https://s3.<AWS_REGION>.amazonaws.com`.
+    """
+
+
+def http(url: str, sha256: str = ""):
+    """File downloaded over HTTP
+
+    Archives (`.zip`, `.tar`, `.tar.gz`, `.tgz`, `.tar.bz2`, `.tar.xz`) are
+    extracted, other files are kept as is.
+
+    Args:
+        url (str): url of the file
+        sha256 (str): expected sha256 of the file, the download is cached by it if set
+    """
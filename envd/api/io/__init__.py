@@ -31,10 +31,18 @@ def copy(src: str, dest: str):
     """
 
 
-def mount(src: str, dest: str):
+def mount(
+    src: str, dest: str, readonly: bool = False, mode: str = "rw", type: str = "bind"
+):
     """Mount from host `src` to container `dest` (runtime)
 
     Args:
-        src (str): source path
+        src (str): source path or data source, e.g. `data.local(path="~/datasets")`,
+            or the volume name if `type` is `volume`
         dest (str): destination path
+        readonly (bool): mount read-only, same as `mode="ro"`
+        mode (str): `rw`, `ro` or `cow`. `cow` mounts an overlay of the source,
+            the changes are written to a per-environment dir and discarded
+            by `envd destroy`, so that the source is never modified.
+        type (str): `bind` for host paths or `volume` for named docker volumes
     """
@@ -21,7 +21,7 @@ Please update the python file there instead of directly editing file inside envd
 :::
 """
 
-from typing import Dict, Optional, List
+from typing import Dict, Optional, List, Union
 
 
 def command(commands: Dict[str, str]):
@@ -54,14 +54,27 @@ def expose(envd_port: str, host_port: Optional[str], service: Optional[str]):
     """
 
 
-def daemon(commands: List[List[str]]):
+def daemon(
+    commands: List[List[str]],
+    restart: Optional[str] = "no",
+    healthcheck: Optional[Dict[str, Union[str, int, List[str]]]] = None,
+):
     """Run daemon processes in the container
     Proposal: https://github.com/tensorchord/envd/pull/769
 
-    It's better to redirect the logs to local files for debug purposes.
+    The daemons are supervised by `envd-ssh` in the container. The state and the
+    restart count of the daemons are shown in `envd envs describe`. The output
+    of the daemon is written to `/var/envd/log/<name>.log` in the container,
+    where the name is the base name of the executable, e.g. `streamlit`.
 
     Args:
         commands (List[List[str]]): run multiple commands in the background
+        restart (Optional[str]): restart policy of the daemons, one of
+            `no`, `on-failure` and `always`
+        healthcheck (Optional[Dict]): check the daemons periodically, the daemon is
+            restarted according to the restart policy after `retries` failures. The keys are
+            `command` (str or List[str]), `interval` (default "10s"),
+            `timeout` (default "5s") and `retries` (default 3)
 
     Example usage:
     ```
@@ -69,6 +82,15 @@ def daemon(commands: List[List[str]]):
         ["jupyter-lab", "--port", "8080"],
         ["python3", "serving.py", ">>serving.log", "2>&1"],
     ])
+    runtime.daemon(
+        commands=[["streamlit", "hello", "--server.port", "8501"]],
+        restart="on-failure",
+        healthcheck={
+            "command": ["curl", "-f", "http://localhost:8501/healthz"],
+            "interval": "10s",
+            "retries": 3,
+        },
+    )
     ```
     """
 
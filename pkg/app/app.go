@@ -61,16 +61,27 @@ func New() EnvdApp {
 
 	internalApp.Commands = []*cli.Command{
 		CommandBootstrap,
+		CommandConfig,
 		CommandContext,
 		CommandBuild,
+		CommandCopy,
+		CommandData,
 		CommandDestroy,
 		CommandEnvironment,
+		CommandExport,
 		CommandImage,
 		CommandInit,
+		CommandLint,
+		CommandLock,
+		CommandLogs,
 		CommandPause,
+		CommandPortForward,
 		CommandPrune,
 		CommandRun,
 		CommandResume,
+		CommandSessions,
+		CommandSSHKey,
+		CommandSync,
 		CommandUp,
 		CommandVersion,
 		CommandTop,
@@ -58,6 +58,11 @@ var CommandBootstrap = &cli.Command{
 				sshconfig.GetPublicKeyOrPanic(), sshconfig.GetPrivateKeyOrPanic()),
 			Aliases: []string{"k"},
 		},
+		&cli.StringFlag{
+			Name:  "ssh-key-type",
+			Usage: "Type of the ssh key pair generated by envd, rsa or ed25519",
+			Value: string(sshconfig.KeyTypeRSA),
+		},
 	},
 
 	Action: bootstrap,
@@ -101,8 +106,12 @@ func sshKey(clicontext *cli.Context) error {
 			return errors.Wrap(err, "Cannot get default key status")
 		}
 		if !keyExists {
+			keyType, err := sshconfig.ParseKeyType(clicontext.String("ssh-key-type"))
+			if err != nil {
+				return err
+			}
 			// Generate SSH keys only if key doesn't exist
-			if err := sshconfig.GenerateKeys(); err != nil {
+			if err := sshconfig.GenerateKeysWithType(keyType); err != nil {
 				return errors.Wrap(err, "failed to generate ssh key")
 			}
 		}
@@ -192,14 +201,14 @@ func buildkit(clicontext *cli.Context) error {
 		return nil
 	}
 
-	currentDriver, currentSocket, err := home.GetManager().ContextGetCurrent()
+	c, err := home.GetManager().ContextGetCurrent()
 	if err != nil {
 		return errors.Wrap(err, "failed to get the current context")
 	}
 
 	logrus.Debug("bootstrap the buildkitd container")
 	bkClient, err := buildkitd.NewClient(clicontext.Context,
-		currentDriver, currentSocket, clicontext.String("dockerhub-mirror"))
+		c.Builder, c.BuilderSocket, clicontext.String("dockerhub-mirror"))
 	if err != nil {
 		return errors.Wrap(err, "failed to create buildkit client")
 	}
@@ -39,6 +39,8 @@ To build an image using build.envd:
 	$ envd build
 To build and push the image to a registry:
 	$ envd build --output type=image,name=docker.io/username/image,push=true
+To build and push the multi-platform image to a registry:
+	$ envd build --platform linux/amd64,linux/arm64 --output type=image,name=docker.io/username/image,push=true
 `,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
@@ -76,6 +78,11 @@ To build and push the image to a registry:
 			Usage: "Force rebuild the image",
 			Value: false,
 		},
+		&cli.BoolFlag{
+			Name:  "frozen",
+			Usage: "Fail if envd.lock is missing or out of date",
+			Value: false,
+		},
 		// https://github.com/urfave/cli/issues/1134#issuecomment-1191407527
 		&cli.StringFlag{
 			Name:    "export-cache",
@@ -87,6 +94,28 @@ To build and push the image to a registry:
 			Usage:   "Import the cache (e.g. type=registry,ref=<image>)",
 			Aliases: []string{"ic"},
 		},
+		&cli.StringFlag{
+			Name:  "progress",
+			Usage: "Set type of progress output (auto, plain, tty, json)",
+			Value: "auto",
+		},
+		&cli.StringFlag{
+			Name:        "platform",
+			Usage:       "Target platforms of the image, pushing multiple platforms requires --output type=image,push=true (e.g. linux/amd64,linux/arm64)",
+			DefaultText: "linux/amd64",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ssh",
+			Usage: "Forward the ssh agent or keys to the build for run(mount_ssh=True) (e.g. default or default=<path>)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "secret",
+			Usage: "Secret declared by config.secret (e.g. id=pypi,env=PIP_INDEX_URL or id=netrc,src=path)",
+		},
+		&cli.PathFlag{
+			Name:  "report",
+			Usage: "Path to save the build report as JSON",
+		},
 	},
 	Action: build,
 }
@@ -96,6 +125,9 @@ func build(clicontext *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	// The report summary would break the JSON lines.
+	opt.PrintReport = opt.ProgressMode != "json"
+	opt.ReportPath = clicontext.Path("report")
 
 	logger := logrus.WithFields(logrus.Fields{
 		"build-context": opt.BuildContextDir,
@@ -185,11 +217,18 @@ func ParseBuildOpt(clicontext *cli.Context) (builder.Options, error) {
 		ProgressMode:     "auto",
 		ExportCache:      exportCache,
 		ImportCache:      importCache,
+		Frozen:           clicontext.Bool("frozen"),
+		Secrets:          clicontext.StringSlice("secret"),
+		SSH:              clicontext.StringSlice("ssh"),
+		Platform:         clicontext.String("platform"),
 	}
 
 	debug := clicontext.Bool("debug")
 	if debug {
 		opt.ProgressMode = "plain"
 	}
+	if clicontext.IsSet("progress") {
+		opt.ProgressMode = clicontext.String("progress")
+	}
 	return opt, nil
 }
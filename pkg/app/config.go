@@ -0,0 +1,95 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/home"
+)
+
+var CommandConfig = &cli.Command{
+	Name:     "config",
+	Category: CategoryManagement,
+	Usage:    "Manage envd config",
+	Description: `
+The config is stored in ~/.config/envd/config.yaml. The keys are separated
+by dots, and the contexts are looked up by their names:
+	$ envd config get current
+	$ envd config set contexts.default.builder_socket tcp://localhost:8888
+`,
+	Subcommands: []*cli.Command{
+		CommandConfigGet,
+		CommandConfigSet,
+		CommandConfigView,
+	},
+}
+
+var CommandConfigGet = &cli.Command{
+	Name:      "get",
+	Usage:     "Print the value of the key in the config",
+	ArgsUsage: "<key>",
+	Action:    configGet,
+}
+
+func configGet(clicontext *cli.Context) error {
+	if clicontext.NArg() != 1 {
+		return errors.New("expected the key")
+	}
+	v, err := home.GetManager().ConfigGet(clicontext.Args().First())
+	if err != nil {
+		return err
+	}
+	fmt.Println(v)
+	return nil
+}
+
+var CommandConfigSet = &cli.Command{
+	Name:      "set",
+	Usage:     "Set the value of the key in the config",
+	ArgsUsage: "<key> <value>",
+	Action:    configSet,
+}
+
+func configSet(clicontext *cli.Context) error {
+	if clicontext.NArg() != 2 {
+		return errors.New("expected the key and the value")
+	}
+	key, value := clicontext.Args().Get(0), clicontext.Args().Get(1)
+	if err := home.GetManager().ConfigSet(key, value); err != nil {
+		return err
+	}
+	logrus.Infof("%s is set to %s", key, value)
+	return nil
+}
+
+var CommandConfigView = &cli.Command{
+	Name:   "view",
+	Usage:  "Print the config",
+	Action: configView,
+}
+
+func configView(clicontext *cli.Context) error {
+	v, err := home.GetManager().ConfigView()
+	if err != nil {
+		return err
+	}
+	fmt.Print(v)
+	return nil
+}
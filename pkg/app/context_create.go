@@ -43,6 +43,19 @@ var CommandContextCreate = &cli.Command{
 			Usage: "Builder socket",
 			Value: "envd_buildkitd",
 		},
+		&cli.StringFlag{
+			Name:  "runner",
+			Usage: "Runtime to run the environments (docker, podman), the environments of the kube-pod builder run as pods",
+			Value: string(types.RunnerTypeDocker),
+		},
+		&cli.StringFlag{
+			Name:  "runner-address",
+			Usage: "Address of the runtime API, e.g. unix:///run/podman/podman.sock, or the kubeconfig path of the kube-pod builder",
+		},
+		&cli.StringFlag{
+			Name:  "runner-namespace",
+			Usage: "Namespace of the environments of the kube-pod builder",
+		},
 		&cli.BoolFlag{
 			Name:  "use",
 			Usage: "Use the context",
@@ -57,8 +70,14 @@ func contextCreate(clicontext *cli.Context) error {
 	builderSocket := clicontext.String("builder-socket")
 	use := clicontext.Bool("use")
 
-	err := home.GetManager().ContextCreate(name,
-		types.BuilderType(builder), builderSocket, use)
+	err := home.GetManager().ContextCreate(types.Context{
+		Name:            name,
+		Builder:         types.BuilderType(builder),
+		BuilderSocket:   builderSocket,
+		Runner:          types.RunnerType(clicontext.String("runner")),
+		RunnerAddress:   clicontext.String("runner-address"),
+		RunnerNamespace: clicontext.String("runner-namespace"),
+	}, use)
 	if err != nil {
 		return errors.Wrap(err, "failed to create context")
 	}
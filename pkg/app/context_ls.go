@@ -44,7 +44,7 @@ func contextList(clicontext *cli.Context) error {
 
 func renderContext(contexts types.EnvdContext, w io.Writer) {
 	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{"context", "builder", "socket"})
+	table.SetHeader([]string{"context", "builder", "socket", "runner", "runner address"})
 
 	table.SetAutoWrapText(false)
 	table.SetAutoFormatHeaders(true)
@@ -59,7 +59,7 @@ func renderContext(contexts types.EnvdContext, w io.Writer) {
 	table.SetNoWhiteSpace(true)
 
 	for _, p := range contexts.Contexts {
-		envRow := make([]string, 3)
+		envRow := make([]string, 5)
 		if p.Name == contexts.Current {
 			envRow[0] = fmt.Sprintf("%s (current)", p.Name)
 		} else {
@@ -67,6 +67,8 @@ func renderContext(contexts types.EnvdContext, w io.Writer) {
 		}
 		envRow[1] = string(p.Builder)
 		envRow[2] = fmt.Sprintf("%s://%s", p.Builder, p.BuilderSocket)
+		envRow[3] = string(p.Runner)
+		envRow[4] = p.RunnerAddress
 		table.Append(envRow)
 	}
 	table.Render()
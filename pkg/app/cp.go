@@ -0,0 +1,89 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/ssh"
+)
+
+var CommandCopy = &cli.Command{
+	Name:      "cp",
+	Category:  CategoryBasic,
+	Usage:     "Copy files or directories between the host and the envd environment",
+	ArgsUsage: "<env>:<path> <local> | <local> <env>:<path>",
+	Description: `
+The relative path in the environment is relative to the working directory.
+To copy the outputs in the environment mnist to the host:
+	$ envd cp mnist:outputs ./outputs
+To copy the file on the host to the environment:
+	$ envd cp ./data.csv mnist:/home/envd/data.csv
+`,
+	Action: copyFiles,
+}
+
+func copyFiles(clicontext *cli.Context) error {
+	if clicontext.NArg() != 2 {
+		return errors.New("expected the source and the destination")
+	}
+	srcEnv, src := parseEnvPath(clicontext.Args().Get(0))
+	dstEnv, dst := parseEnvPath(clicontext.Args().Get(1))
+	if (srcEnv == "") == (dstEnv == "") {
+		return errors.New("exactly one of the source and the destination should be in the <env>:<path> format")
+	}
+	name := srcEnv + dstEnv
+
+	opt, err := ssh.GetOptions(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the ssh options")
+	}
+	cli, err := ssh.NewClient(*opt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the ssh client")
+	}
+	defer cli.Close()
+	sftpClient, err := cli.SFTP()
+	if err != nil {
+		return err
+	}
+	defer sftpClient.Close()
+
+	if srcEnv != "" {
+		err = ssh.Download(sftpClient, src, dst)
+	} else {
+		err = ssh.Upload(sftpClient, src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("copied %s to %s", clicontext.Args().Get(0), clicontext.Args().Get(1))
+	return nil
+}
+
+// parseEnvPath parses the path in the <env>:<path> format, the env is empty
+// if it is a local path, e.g. ./a:b or C:\data.
+func parseEnvPath(s string) (string, string) {
+	env, p, ok := strings.Cut(s, ":")
+	if !ok || env == "" || strings.ContainsAny(env, `/\`) || filepath.VolumeName(s) != "" {
+		return "", s
+	}
+	return env, p
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/envd"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+var CommandData = &cli.Command{
+	Name:     "data",
+	Category: CategoryManagement,
+	Usage:    "Manage envd datasets",
+	Subcommands: []*cli.Command{
+		CommandDataDescribe,
+		CommandDataExport,
+		CommandDataImport,
+		CommandDataList,
+		CommandDataRemove,
+	},
+}
+
+var CommandDataList = &cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls", "l"},
+	Usage:   "List envd datasets",
+	Action:  dataList,
+}
+
+func dataList(clicontext *cli.Context) error {
+	envdEngine, err := envd.New(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	datasets, err := envdEngine.ListDataset(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	renderDatasets(datasets, os.Stdout)
+	return nil
+}
+
+func renderDatasets(datasets []types.Dataset, w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Name", "Size", "Files", "Last Used", "Environments"})
+
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+
+	for _, d := range datasets {
+		row := make([]string, 5)
+		row[0] = d.Name
+		row[1] = units.HumanSizeWithPrecision(float64(d.Size), 3)
+		row[2] = strconv.Itoa(d.Files)
+		row[3] = lastUsedString(d)
+		row[4] = stringOrNone(strings.Join(datasetEnvironments(d), ","))
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func lastUsedString(d types.Dataset) string {
+	for _, m := range d.Mounts {
+		if m.Running {
+			return "in use"
+		}
+	}
+	if d.LastUsed.IsZero() {
+		return ""
+	}
+	return units.HumanDuration(time.Since(d.LastUsed)) + " ago"
+}
+
+// datasetEnvironments returns the names of the environments mounting the
+// dataset.
+func datasetEnvironments(d types.Dataset) []string {
+	var envs []string
+	seen := map[string]bool{}
+	for _, m := range d.Mounts {
+		if !seen[m.Environment] {
+			seen[m.Environment] = true
+			envs = append(envs, m.Environment)
+		}
+	}
+	return envs
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/go-units"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/envd"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+var CommandDataDescribe = &cli.Command{
+	Name:    "describe",
+	Aliases: []string{"d"},
+	Usage:   "Show details about the dataset, including the environments mounting it",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "Name of the dataset",
+			Aliases:  []string{"n"},
+			Required: true,
+		},
+	},
+	Action: dataDescribe,
+}
+
+func dataDescribe(clicontext *cli.Context) error {
+	d, err := getDataset(clicontext)
+	if err != nil {
+		return err
+	}
+	renderDataset(d, os.Stdout)
+	return nil
+}
+
+// getDataset returns the dataset named by the --name flag.
+func getDataset(clicontext *cli.Context) (types.Dataset, error) {
+	name := clicontext.String("name")
+	envdEngine, err := envd.New(clicontext.Context)
+	if err != nil {
+		return types.Dataset{}, errors.Wrap(err, "failed to create envd engine")
+	}
+	datasets, err := envdEngine.ListDataset(clicontext.Context)
+	if err != nil {
+		return types.Dataset{}, errors.Wrap(err, "failed to list datasets")
+	}
+	for _, d := range datasets {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+	return types.Dataset{}, errors.Newf("dataset %s does not exist", name)
+}
+
+func renderDataset(d types.Dataset, w io.Writer) {
+	fmt.Fprintf(w, "Name:\t\t%s\n", d.Name)
+	fmt.Fprintf(w, "Path:\t\t%s\n", d.Path)
+	fmt.Fprintf(w, "Size:\t\t%s\n", units.HumanSizeWithPrecision(float64(d.Size), 3))
+	fmt.Fprintf(w, "Files:\t\t%d\n", d.Files)
+	fmt.Fprintf(w, "Last Used:\t%s\n", lastUsedString(d))
+	if len(d.Mounts) == 0 {
+		return
+	}
+	table := createTable(w, []string{"Environment", "Destination", "Running"})
+	for _, m := range d.Mounts {
+		table.Append([]string{m.Environment, m.Destination, strconv.FormatBool(m.Running)})
+	}
+	table.Render()
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/data"
+)
+
+var CommandDataImport = &cli.Command{
+	Name:  "import",
+	Usage: "Import a local dir, a tarball or a zip archive as a dataset",
+	Description: `
+The dataset can be mounted with io.mount(src=data.envd(name="mnist"), dest="~/data").
+	$ envd data import --name mnist --src ./mnist.tar.gz
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "Name of the dataset",
+			Aliases:  []string{"n"},
+			Required: true,
+		},
+		&cli.PathFlag{
+			Name:     "src",
+			Usage:    "Path of the dir, tarball or zip archive",
+			Aliases:  []string{"s"},
+			Required: true,
+		},
+	},
+	Action: dataImport,
+}
+
+func dataImport(clicontext *cli.Context) error {
+	name := clicontext.String("name")
+	if err := data.ImportDataset(name, clicontext.Path("src")); err != nil {
+		return errors.Wrap(err, "failed to import dataset")
+	}
+	logrus.Infof("Dataset %s is imported", name)
+	return nil
+}
+
+var CommandDataExport = &cli.Command{
+	Name:  "export",
+	Usage: "Export a dataset to a tarball",
+	Description: `
+The tarball is gzipped unless the output ends with .tar.
+	$ envd data export --name mnist --output ./mnist.tar.gz
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "Name of the dataset",
+			Aliases:  []string{"n"},
+			Required: true,
+		},
+		&cli.PathFlag{
+			Name:     "output",
+			Usage:    "Path of the tarball",
+			Aliases:  []string{"o"},
+			Required: true,
+		},
+	},
+	Action: dataExport,
+}
+
+func dataExport(clicontext *cli.Context) error {
+	name := clicontext.String("name")
+	output := clicontext.Path("output")
+	if err := data.ExportDataset(name, output); err != nil {
+		return errors.Wrap(err, "failed to export dataset")
+	}
+	logrus.Infof("Dataset %s is exported to %s", name, output)
+	return nil
+}
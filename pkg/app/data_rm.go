@@ -0,0 +1,101 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/data"
+	"github.com/tensorchord/envd/pkg/envd"
+)
+
+var CommandDataRemove = &cli.Command{
+	Name:    "remove",
+	Aliases: []string{"rm", "r"},
+	Usage:   "Remove envd datasets",
+	Description: `
+Datasets mounted by an environment are only removed with --force.
+To remove the dataset mnist:
+	$ envd data rm --name mnist
+To remove all the datasets which are not mounted by any environment:
+	$ envd data rm --unused
+`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "name",
+			Usage:   "Name of the dataset, can be specified multiple times",
+			Aliases: []string{"n"},
+		},
+		&cli.BoolFlag{
+			Name:  "unused",
+			Usage: "Remove all the datasets which are not mounted by any environment",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Usage:   "Remove the datasets even if they are mounted by environments",
+			Aliases: []string{"f"},
+		},
+	},
+	Action: dataRemove,
+}
+
+func dataRemove(clicontext *cli.Context) error {
+	names := clicontext.StringSlice("name")
+	unused := clicontext.Bool("unused")
+	force := clicontext.Bool("force")
+	if len(names) == 0 && !unused {
+		return errors.New("--name or --unused is required")
+	}
+	if len(names) != 0 && unused {
+		return errors.New("--name and --unused cannot be used together")
+	}
+
+	envdEngine, err := envd.New(clicontext.Context)
+	if err != nil {
+		return errors.Wrap(err, "failed to create envd engine")
+	}
+	datasets, err := envdEngine.ListDataset(clicontext.Context)
+	if err != nil {
+		return errors.Wrap(err, "failed to list datasets")
+	}
+
+	mounted := map[string][]string{}
+	for _, d := range datasets {
+		mounted[d.Name] = datasetEnvironments(d)
+	}
+	if unused {
+		for _, d := range datasets {
+			if len(d.Mounts) == 0 {
+				names = append(names, d.Name)
+			}
+		}
+		if len(names) == 0 {
+			logrus.Info("No unused dataset")
+		}
+	}
+
+	for _, name := range names {
+		if envs := mounted[name]; len(envs) != 0 && !force {
+			return errors.Newf("dataset %s is mounted by %v, use --force to remove it", name, envs)
+		}
+		if err := data.RemoveDataset(name); err != nil {
+			return errors.Wrap(err, "failed to remove dataset")
+		}
+		logrus.Infof("Dataset %s is removed", name)
+	}
+	return nil
+}
@@ -38,6 +38,7 @@ var CommandEnvironment = &cli.Command{
 	Subcommands: []*cli.Command{
 		CommandDescribeEnvironment,
 		CommandListEnv,
+		CommandShareEnvironment,
 	},
 }
 
@@ -17,19 +17,23 @@ package app
 import (
 	"io"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/docker/go-units"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 
 	"github.com/tensorchord/envd/pkg/envd"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
 	"github.com/tensorchord/envd/pkg/types"
 )
 
 var CommandDescribeEnvironment = &cli.Command{
 	Name:    "describe",
 	Aliases: []string{"d"},
-	Usage:   "Show details about environments, including dependencies and port binding",
+	Usage:   "Show details about environments, including dependencies, port binding and daemons",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:     "env",
@@ -58,8 +62,14 @@ func getEnvironmentDescriptions(clicontext *cli.Context) error {
 		return errors.Wrap(err, "failed to list port bindings")
 	}
 
+	daemons, err := envdEngine.ListEnvDaemonStatus(clicontext.Context, envName)
+	if err != nil {
+		return errors.Wrap(err, "failed to list daemon status")
+	}
+
 	renderDependencies(os.Stdout, dep)
 	renderPortBindings(os.Stdout, ports)
+	renderDaemons(os.Stdout, daemons)
 	return nil
 }
 
@@ -98,6 +108,31 @@ func renderPortBindings(w io.Writer, ports []types.PortBinding) {
 	table.Render()
 }
 
+func renderDaemons(w io.Writer, daemons []supervisor.Status) {
+	if len(daemons) == 0 {
+		return
+	}
+	table := createTable(w, []string{"Daemon", "State", "Healthy", "Restarts", "Exit Code", "Since"})
+	for _, d := range daemons {
+		row := make([]string, 6)
+		row[0] = d.Name
+		row[1] = string(d.State)
+		row[2] = "-"
+		if d.Healthy != nil {
+			row[2] = strconv.FormatBool(*d.Healthy)
+		}
+		row[3] = strconv.Itoa(d.Restarts)
+		row[4] = "-"
+		if d.State == supervisor.StateExited || d.State == supervisor.StateFailed ||
+			d.State == supervisor.StateBackoff {
+			row[4] = strconv.Itoa(d.ExitCode)
+		}
+		row[5] = units.HumanDuration(time.Since(d.Since)) + " ago"
+		table.Append(row)
+	}
+	table.Render()
+}
+
 func renderDependencies(w io.Writer, dep *types.Dependency) {
 	if dep == nil {
 		return
@@ -0,0 +1,124 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/remote/sshd"
+)
+
+var CommandShareEnvironment = &cli.Command{
+	Name:  "share",
+	Usage: "Share the running environment with the public key of a collaborator",
+	Description: `
+The key takes effect immediately without rebuilding the environment. The
+sessions of the key run as the user, which should be declared in build.envd
+with config.users, or as envd by default:
+	$ envd envs share --name mnist --key ~/alice.pub --user alice
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "Name of the environment",
+			Aliases:  []string{"n"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "key",
+			Usage:    "Path to the public key, or the public key in the authorized_keys format",
+			Aliases:  []string{"k"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "user",
+			Usage:   "User in the environment to run the sessions of the key as",
+			Aliases: []string{"u"},
+		},
+	},
+	Action: shareEnvironment,
+}
+
+func shareEnvironment(clicontext *cli.Context) error {
+	name := clicontext.String("name")
+	user := clicontext.String("user")
+	if user == "root" {
+		return errors.New("the sessions cannot run as root")
+	}
+	key, comment, err := parsePublicKey(clicontext.String("key"))
+	if err != nil {
+		return err
+	}
+
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	if user != "" {
+		if err := dockerClient.ExecOutput(clicontext.Context, name,
+			[]string{"id", "-u", user}, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+			return errors.Wrapf(err, "failed to find the user %s in the environment %s, "+
+				"is it declared with config.users?", user, name)
+		}
+	}
+
+	data, err := dockerClient.ReadFile(clicontext.Context, name, config.ContainerAuthorizedKeysPath)
+	if err != nil && !client.IsErrNotFound(err) {
+		return errors.Wrap(err, "failed to read the authorized keys")
+	}
+	keys, err := sshd.ParseAuthorizedKeys(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the authorized keys")
+	}
+	for _, k := range keys {
+		if bytes.Equal(k.Key.Marshal(), key.Marshal()) {
+			return errors.Newf("the key %s is already authorized in the environment %s",
+				gossh.FingerprintSHA256(key), name)
+		}
+	}
+
+	if len(data) != 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, sshd.FormatAuthorizedKey(key, user, comment)+"\n"...)
+	if err := writeAuthorizedKeys(clicontext.Context, dockerClient, name, data); err != nil {
+		return errors.Wrap(err, "failed to add the key to the environment")
+	}
+	logrus.Infof("the environment %s is shared with the key %s", name, gossh.FingerprintSHA256(key))
+	return nil
+}
+
+// parsePublicKey reads the public key from the file, or parses the argument
+// if the file does not exist.
+func parsePublicKey(s string) (gossh.PublicKey, string, error) {
+	data := []byte(s)
+	if content, err := os.ReadFile(s); err == nil {
+		data = content
+	}
+	key, comment, _, _, err := gossh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to parse the public key")
+	}
+	return key, comment, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/builder"
+	"github.com/tensorchord/envd/pkg/editor/devcontainer"
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+const (
+	exportFormatDockerfile   = "dockerfile"
+	exportFormatDevContainer = "devcontainer"
+)
+
+var CommandExport = &cli.Command{
+	Name:     "export",
+	Category: CategoryBasic,
+	Usage:    "Export the environment to a standalone Dockerfile or devcontainer.json",
+	Description: `
+To export the build.envd in the current directory to ./Dockerfile:
+	$ envd export
+To print the Dockerfile to stdout:
+	$ envd export --output -
+To export .devcontainer/devcontainer.json, which uses the image built by envd:
+	$ envd build && envd export --format devcontainer
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "tag",
+			Usage:       "Name of the image used in devcontainer.json, in the 'name:tag' format",
+			Aliases:     []string{"t"},
+			DefaultText: "PROJECT:dev",
+		},
+		&cli.PathFlag{
+			Name:    "from",
+			Usage:   "Function to execute, format `file:func`",
+			Aliases: []string{"f"},
+			Value:   "build.envd:build",
+		},
+		&cli.PathFlag{
+			Name:    "path",
+			Usage:   "Path to the directory containing the build.envd",
+			Aliases: []string{"p"},
+			Value:   ".",
+		},
+		&cli.StringFlag{
+			Name:        "output",
+			Usage:       "Path to the exported file, relative to the build context. Use - for stdout",
+			Aliases:     []string{"o"},
+			DefaultText: "Dockerfile or .devcontainer/devcontainer.json",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Export format (dockerfile, devcontainer)",
+			Value: exportFormatDockerfile,
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Overwrite the output file if it exists",
+		},
+	},
+	Action: export,
+}
+
+func export(clicontext *cli.Context) error {
+	format := clicontext.String("format")
+	if format != exportFormatDockerfile && format != exportFormatDevContainer {
+		return errors.Newf("unknown export format %s", format)
+	}
+	opt, err := ParseBuildOpt(clicontext)
+	if err != nil {
+		return err
+	}
+	logger := logrus.WithFields(logrus.Fields{
+		"build-context": opt.BuildContextDir,
+		"build-file":    opt.ManifestFilePath,
+		"format":        format,
+	})
+	logger.Debug("starting export command")
+
+	if err := builder.Interpret(opt); err != nil {
+		return errors.Wrap(err, "failed to interpret the manifest")
+	}
+	var content []byte
+	output := clicontext.String("output")
+	switch format {
+	case exportFormatDockerfile:
+		dockerfile, err := ir.Dockerfile(opt.BuildContextDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate the Dockerfile")
+		}
+		content = []byte(dockerfile)
+		if output == "" {
+			output = "Dockerfile"
+		}
+	case exportFormatDevContainer:
+		content, err = ir.DevContainer(opt.BuildContextDir, opt.Tag).Marshal()
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			output = devcontainer.Path("")
+		}
+	}
+
+	if output == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(opt.BuildContextDir, output)
+	}
+	exists, err := fileutil.FileExists(output)
+	if err != nil {
+		return err
+	}
+	if exists && !clicontext.Bool("force") {
+		return errors.Newf("%s already exists, use --force to overwrite it", output)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of %s", output)
+	}
+	if err := os.WriteFile(output, content, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", output)
+	}
+	logger.WithField("output", output).Info("exported the environment")
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/cockroachdb/errors"
 	cli "github.com/urfave/cli/v2"
 
+	"github.com/tensorchord/envd/pkg/editor/devcontainer"
 	"github.com/tensorchord/envd/pkg/util/fileutil"
 )
 
@@ -35,10 +36,9 @@ var CommandInit = &cli.Command{
 	Usage:    "Initializes the current directory with the build.envd file",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "lang",
-			Usage:    "language usage. Support Python, R, Julia",
-			Aliases:  []string{"l"},
-			Required: true,
+			Name:    "lang",
+			Usage:   "language usage. Support Python, R, Julia",
+			Aliases: []string{"l"},
 		},
 		&cli.BoolFlag{
 			Name:     "force",
@@ -46,6 +46,10 @@ var CommandInit = &cli.Command{
 			Aliases:  []string{"f"},
 			Required: false,
 		},
+		&cli.PathFlag{
+			Name:  "from-devcontainer",
+			Usage: "generate the build.envd from the devcontainer.json, e.g. .devcontainer/devcontainer.json",
+		},
 	},
 	Action: initCommand,
 }
@@ -64,7 +68,11 @@ func isValidLang(lang string) bool {
 func initCommand(clicontext *cli.Context) error {
 	lang := strings.ToLower(clicontext.String("lang"))
 	force := clicontext.Bool("force")
-	if !isValidLang(lang) {
+	fromDevContainer := clicontext.Path("from-devcontainer")
+	if lang == "" && fromDevContainer == "" {
+		return errors.New("--lang or --from-devcontainer is required")
+	}
+	if lang != "" && !isValidLang(lang) {
 		return errors.Errorf("invalid language %s", lang)
 	}
 
@@ -76,9 +84,18 @@ func initCommand(clicontext *cli.Context) error {
 		return errors.Errorf("build.envd already exists, use --force to overwrite it")
 	}
 
-	buildEnvdContent, err := templatef.ReadFile("template/" + lang + ".envd")
-	if err != nil {
-		return err
+	var buildEnvdContent []byte
+	if fromDevContainer != "" {
+		c, err := devcontainer.Load(fromDevContainer)
+		if err != nil {
+			return err
+		}
+		buildEnvdContent = []byte(devcontainer.Manifest(*c, lang))
+	} else {
+		buildEnvdContent, err = templatef.ReadFile("template/" + lang + ".envd")
+		if err != nil {
+			return err
+		}
 	}
 	err = ioutil.WriteFile("build.envd", buildEnvdContent, 0644)
 	if err != nil {
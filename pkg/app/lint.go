@@ -0,0 +1,124 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/builder"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/lint"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+var CommandLint = &cli.Command{
+	Name:     "lint",
+	Category: CategoryBasic,
+	Usage:    "Check the build.envd for problems without building it",
+	Description: `
+To check the build.envd in the current directory:
+	$ envd lint
+To output the problems in JSON:
+	$ envd lint --format json
+`,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "from",
+			Usage:   "Function to execute, format `file:func`",
+			Aliases: []string{"f"},
+			Value:   "build.envd:build",
+		},
+		&cli.PathFlag{
+			Name:    "path",
+			Usage:   "Path to the directory containing the build.envd",
+			Aliases: []string{"p"},
+			Value:   ".",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format (plain, json)",
+			Value: "plain",
+		},
+	},
+	Action: lintManifest,
+}
+
+func lintManifest(clicontext *cli.Context) error {
+	format := clicontext.String("format")
+	if format != "plain" && format != "json" {
+		return errors.Newf("unknown output format %s", format)
+	}
+	buildContext, err := filepath.Abs(clicontext.Path("path"))
+	if err != nil {
+		return errors.Wrap(err, "failed to get absolute path of the build context")
+	}
+	fileName, _, err := builder.ParseFromStr(clicontext.String("from"))
+	if err != nil {
+		return err
+	}
+	manifest, err := fileutil.FindFileAbsPath(buildContext, fileName)
+	if err != nil {
+		return errors.Wrap(err, "failed to get absolute path of the build file")
+	}
+	if manifest == "" {
+		return errors.New("file does not exist")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"build-context": buildContext,
+		"build-file":    manifest,
+	}).Debug("starting lint command")
+	issues, err := lint.LintFile(manifest, buildContext)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if err := renderIssuesJSON(issues, os.Stdout); err != nil {
+			return err
+		}
+	} else {
+		renderIssues(issues, os.Stdout)
+	}
+	if len(issues) != 0 {
+		return errors.Newf("found %d problem(s) in %s", len(issues), manifest)
+	}
+	return nil
+}
+
+func renderIssues(issues []lint.Issue, w io.Writer) {
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue.String())
+	}
+}
+
+func renderIssuesJSON(issues []lint.Issue, w io.Writer) error {
+	if issues == nil {
+		issues = []lint.Issue{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(issues); err != nil {
+		return errors.Wrap(err, "failed to encode the problems")
+	}
+	return nil
+}
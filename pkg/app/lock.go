@@ -0,0 +1,118 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/lockfile"
+	sshconfig "github.com/tensorchord/envd/pkg/ssh/config"
+)
+
+var CommandLock = &cli.Command{
+	Name:     "lock",
+	Category: CategoryBasic,
+	Usage:    "Resolve the packages and pin their versions in envd.lock",
+	Description: `
+To resolve the packages declared in build.envd and write envd.lock:
+	$ envd lock
+To build with exactly the locked versions:
+	$ envd build --frozen
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "tag",
+			Usage:       "Name and optionally a tag in the 'name:tag' format",
+			Aliases:     []string{"t"},
+			DefaultText: "PROJECT:dev",
+		},
+		&cli.PathFlag{
+			Name:    "from",
+			Usage:   "Function to execute, format `file:func`",
+			Aliases: []string{"f"},
+			Value:   "build.envd:build",
+		},
+		&cli.PathFlag{
+			Name:    "path",
+			Usage:   "Path to the directory containing the build.envd",
+			Aliases: []string{"p"},
+			Value:   ".",
+		},
+		&cli.PathFlag{
+			Name:    "public-key",
+			Usage:   "Path to the public key",
+			Aliases: []string{"pubk"},
+			Value:   sshconfig.GetPublicKeyOrPanic(),
+			Hidden:  true,
+		},
+	},
+	Action: lock,
+}
+
+func lock(clicontext *cli.Context) error {
+	opt, err := ParseBuildOpt(clicontext)
+	if err != nil {
+		return err
+	}
+	// Resolve the packages again instead of installing the locked versions.
+	opt.IgnoreLockfile = true
+
+	logger := logrus.WithFields(logrus.Fields{
+		"build-context": opt.BuildContextDir,
+		"build-file":    opt.ManifestFilePath,
+		"tag":           opt.Tag,
+	})
+	logger.Debug("starting lock command")
+
+	builder, err := GetBuilder(clicontext, opt)
+	if err != nil {
+		return err
+	}
+	if err = InterpretEnvdDef(builder); err != nil {
+		return err
+	}
+	if err := builder.Build(clicontext.Context, true); err != nil {
+		return errors.Wrap(err, "failed to build the image")
+	}
+
+	r, err := ir.Requirements(opt.BuildContextDir)
+	if err != nil {
+		return err
+	}
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the docker client")
+	}
+	run := func(ctx context.Context, cmd []string) (string, error) {
+		return dockerClient.RunCommand(ctx, opt.Tag, cmd)
+	}
+	f, err := lockfile.Resolve(clicontext.Context, run, r)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(opt.ManifestFilePath)
+	if err := f.Save(dir); err != nil {
+		return err
+	}
+	logger.Infof("%s is written", lockfile.Path(dir))
+	return nil
+}
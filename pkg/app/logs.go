@@ -0,0 +1,270 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
+)
+
+var CommandLogs = &cli.Command{
+	Name:     "logs",
+	Category: CategoryBasic,
+	Usage:    "Show the logs of the envd environment",
+	Description: `
+To show the logs of envd-ssh, which supervises the processes in the environment:
+	$ envd logs --name mnist
+To follow the logs of jupyter:
+	$ envd logs --name mnist --service jupyter -f
+To show the last 10 lines of the daemon streamlit in the last hour:
+	$ envd logs --name mnist --service streamlit --since 1h --tail 10
+`,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:        "path",
+			Usage:       "Path to the directory containing the build.envd",
+			Aliases:     []string{"p"},
+			DefaultText: "current directory",
+		},
+		&cli.StringFlag{
+			Name:    "name",
+			Usage:   "Name of the environment",
+			Aliases: []string{"n"},
+		},
+		&cli.StringFlag{
+			Name:    "service",
+			Usage:   "Service to show the logs of, e.g. ssh, jupyter, rstudio or the name of the daemon",
+			Aliases: []string{"s"},
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Usage:   "Follow the log output",
+			Aliases: []string{"f"},
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "Show logs since timestamp (e.g. 2022-09-01T13:23:37Z) or relative (e.g. 42m for 42 minutes)",
+		},
+		&cli.BoolFlag{
+			Name:    "timestamps",
+			Usage:   "Show timestamps",
+			Aliases: []string{"t"},
+		},
+		&cli.StringFlag{
+			Name:  "tail",
+			Usage: "Number of lines to show from the end of the logs",
+			Value: "all",
+		},
+	},
+	Action: logs,
+}
+
+func logs(clicontext *cli.Context) error {
+	name, err := environmentName(clicontext)
+	if err != nil {
+		return err
+	}
+
+	tail := -1
+	if t := clicontext.String("tail"); t != "all" {
+		n, err := strconv.Atoi(t)
+		if err != nil || n < 0 {
+			return errors.Newf("invalid --tail %s, expected all or a non-negative number", t)
+		}
+		tail = n
+	}
+	var since time.Time
+	if s := clicontext.String("since"); s != "" {
+		var err error
+		if since, err = parseSince(s, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	opt := logOptions{
+		name:       name,
+		service:    clicontext.String("service"),
+		follow:     clicontext.Bool("follow"),
+		timestamps: clicontext.Bool("timestamps"),
+		since:      since,
+		tail:       tail,
+	}
+	logrus.WithFields(logrus.Fields{
+		"env":     opt.name,
+		"service": opt.service,
+	}).Debug("showing the logs")
+
+	if opt.service != "" {
+		err := serviceLogs(clicontext, dockerClient, opt)
+		if err == nil || !client.IsErrNotFound(err) {
+			return err
+		}
+		logrus.Warnf("the logs of the service %s are not found in the environment, "+
+			"showing the logs of the container instead", opt.service)
+	}
+	return containerLogs(clicontext, dockerClient, opt)
+}
+
+type logOptions struct {
+	name       string
+	service    string
+	follow     bool
+	timestamps bool
+	since      time.Time
+	// tail is the number of lines from the end, -1 means all.
+	tail int
+}
+
+func containerLogs(clicontext *cli.Context, dockerClient docker.Client, opt logOptions) error {
+	o := types.ContainerLogsOptions{
+		Follow:     opt.follow,
+		Timestamps: opt.timestamps,
+		Tail:       "all",
+	}
+	if opt.tail >= 0 {
+		o.Tail = strconv.Itoa(opt.tail)
+	}
+	if !opt.since.IsZero() {
+		o.Since = strconv.FormatInt(opt.since.Unix(), 10)
+	}
+	return dockerClient.Logs(clicontext.Context, opt.name, o, os.Stdout, os.Stderr)
+}
+
+// serviceLogs shows the log file of the service written by envd-ssh. The
+// lines in the file are prefixed with the timestamps.
+func serviceLogs(clicontext *cli.Context, dockerClient docker.Client, opt logOptions) error {
+	path := supervisor.LogPath(config.ContainerLogDir, opt.service)
+	data, err := dockerClient.ReadFile(clicontext.Context, opt.name, path)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if ts, _, ok := supervisor.ParseLogLine(line); ok && ts.Before(opt.since) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if opt.tail >= 0 && len(lines) > opt.tail {
+		lines = lines[len(lines)-opt.tail:]
+	}
+	w := &logPrinter{w: os.Stdout, timestamps: opt.timestamps}
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	if !opt.follow {
+		return w.Flush()
+	}
+
+	// Follow the file from the end of the content above, the file is only
+	// appended by envd-ssh.
+	cmd := []string{"tail", "-c", fmt.Sprintf("+%d", len(data)+1), "-F", path}
+	err = dockerClient.ExecOutput(clicontext.Context, opt.name, cmd, w, os.Stderr)
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// logPrinter writes the lines in the log file, and removes the timestamps
+// if they are not required.
+type logPrinter struct {
+	mu         sync.Mutex
+	w          io.Writer
+	timestamps bool
+	buf        []byte
+}
+
+func (p *logPrinter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(string(p.buf[:i])); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes the last line which does not end with a newline.
+func (p *logPrinter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(string(p.buf))
+	p.buf = nil
+	return err
+}
+
+func (p *logPrinter) writeLine(line string) error {
+	if !p.timestamps {
+		if _, msg, ok := supervisor.ParseLogLine(line); ok {
+			line = msg
+		}
+	}
+	_, err := fmt.Fprintln(p.w, line)
+	return err
+}
+
+// parseSince parses the timestamp or the duration relative to now, as
+// `docker logs --since` does.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, errors.Newf("invalid --since %s, expected a timestamp or a duration", s)
+}
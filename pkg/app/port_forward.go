@@ -0,0 +1,105 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/ssh"
+)
+
+var CommandPortForward = &cli.Command{
+	Name:      "port-forward",
+	Category:  CategoryBasic,
+	Usage:     "Forward the local ports to the envd environment, or the reverse",
+	ArgsUsage: "[LOCAL_PORT:]REMOTE_PORT...",
+	Description: `
+To reach TensorBoard listening on 6006 in the environment at localhost:6006:
+	$ envd port-forward --name mnist 6006
+To forward localhost:8080 to 6006 in the environment:
+	$ envd port-forward --name mnist 8080:6006
+To reach the service listening on 9000 on the host at localhost:9000 in the environment:
+	$ envd port-forward --name mnist -R 9000:9000
+`,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:        "path",
+			Usage:       "Path to the directory containing the build.envd",
+			Aliases:     []string{"p"},
+			DefaultText: "current directory",
+		},
+		&cli.StringFlag{
+			Name:    "name",
+			Usage:   "Name of the environment",
+			Aliases: []string{"n"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "remote",
+			Usage:   "Reverse forward in the [REMOTE_PORT:]LOCAL_PORT format",
+			Aliases: []string{"R"},
+		},
+		&cli.StringFlag{
+			Name:  "address",
+			Usage: "Address to listen on for the local ports, or to connect to for the reverse forwards",
+			Value: "localhost",
+		},
+	},
+	Action: portForward,
+}
+
+func portForward(clicontext *cli.Context) error {
+	name, err := environmentName(clicontext)
+	if err != nil {
+		return err
+	}
+
+	address := clicontext.String("address")
+	forwards := []ssh.Forward{}
+	for _, arg := range clicontext.Args().Slice() {
+		f, err := ssh.ParseForward(arg, address, false)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, f)
+	}
+	for _, arg := range clicontext.StringSlice("remote") {
+		f, err := ssh.ParseForward(arg, address, true)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, f)
+	}
+	if len(forwards) == 0 {
+		return errors.New("at least one port forward is required")
+	}
+
+	opt, err := ssh.GetOptions(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the ssh options")
+	}
+	for _, f := range forwards {
+		logrus.Infof("forwarding %s", f)
+	}
+
+	ctx, stop := signal.NotifyContext(clicontext.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ssh.NewPortForwarder(*opt, forwards).Run(ctx)
+}
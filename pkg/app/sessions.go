@@ -0,0 +1,183 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/go-units"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/remote/sshd"
+	"github.com/tensorchord/envd/pkg/util/asciicast"
+)
+
+var sessionEnvFlags = []cli.Flag{
+	&cli.PathFlag{
+		Name:        "path",
+		Usage:       "Path to the directory containing the build.envd",
+		Aliases:     []string{"p"},
+		DefaultText: "current directory",
+	},
+	&cli.StringFlag{
+		Name:    "name",
+		Usage:   "Name of the environment",
+		Aliases: []string{"n"},
+	},
+}
+
+var CommandSessions = &cli.Command{
+	Name:     "sessions",
+	Category: CategoryBasic,
+	Usage:    "List and replay the audited SSH sessions of the envd environment",
+	Description: `
+The sessions are audited if the environment is started with --audit:
+	$ envd up --audit --audit-recording
+To list the sessions:
+	$ envd sessions ls --name mnist
+To replay the recording of the session:
+	$ envd sessions replay --name mnist <session-id>
+`,
+	Subcommands: []*cli.Command{
+		CommandListSessions,
+		CommandReplaySession,
+	},
+}
+
+var CommandListSessions = &cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls", "l"},
+	Usage:   "List the SSH sessions in the audit log",
+	Flags:   sessionEnvFlags,
+	Action:  listSessions,
+}
+
+var CommandReplaySession = &cli.Command{
+	Name:      "replay",
+	Usage:     "Replay the recording of the SSH session",
+	ArgsUsage: "<session-id>",
+	Flags: append([]cli.Flag{
+		&cli.Float64Flag{
+			Name:  "speed",
+			Usage: "Playback speed",
+			Value: 1,
+		},
+		&cli.DurationFlag{
+			Name:  "max-idle",
+			Usage: "Limit the idle time between the outputs, 0 means no limit",
+			Value: 2 * time.Second,
+		},
+		&cli.PathFlag{
+			Name:    "output",
+			Usage:   "Save the asciicast recording to the file instead of playing it",
+			Aliases: []string{"o"},
+		},
+	}, sessionEnvFlags...),
+	Action: replaySession,
+}
+
+func readSessions(clicontext *cli.Context, dockerClient docker.Client, name string) ([]sshd.AuditRecord, error) {
+	data, err := dockerClient.ReadFile(clicontext.Context, name, config.ContainerAuditLogPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the audit log in %s, "+
+			"is the environment started with --audit?", name)
+	}
+	return sshd.ReadAuditLog(data)
+}
+
+func listSessions(clicontext *cli.Context) error {
+	name, err := environmentName(clicontext)
+	if err != nil {
+		return err
+	}
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	records, err := readSessions(clicontext, dockerClient, name)
+	if err != nil {
+		return err
+	}
+	renderSessions(os.Stdout, records)
+	return nil
+}
+
+func renderSessions(w io.Writer, records []sshd.AuditRecord) {
+	table := createTable(w, []string{
+		"Session ID", "User", "Fingerprint", "Command", "Started", "Duration", "Exit Status", "Recorded"})
+	for _, r := range records {
+		row := make([]string, 8)
+		row[0] = r.SessionID
+		row[1] = r.User
+		if r.RunAs != "" {
+			row[1] = r.RunAs
+		}
+		row[2] = r.Fingerprint
+		row[3] = r.Command
+		if row[3] == "" {
+			row[3] = "<shell>"
+		}
+		row[4] = units.HumanDuration(time.Since(r.Start)) + " ago"
+		row[5] = units.HumanDuration(r.End.Sub(r.Start))
+		row[6] = strconv.Itoa(r.ExitStatus)
+		row[7] = strconv.FormatBool(r.Recording != "")
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func replaySession(clicontext *cli.Context) error {
+	if clicontext.NArg() != 1 {
+		return errors.New("the session id is required")
+	}
+	sessionID := clicontext.Args().First()
+	name, err := environmentName(clicontext)
+	if err != nil {
+		return err
+	}
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return err
+	}
+
+	// The session in progress is not in the audit log yet, thus fall back
+	// to the default path of the recording.
+	path := filepath.Join(config.ContainerAuditRecordingDir, sessionID+".cast")
+	if records, err := readSessions(clicontext, dockerClient, name); err == nil {
+		for _, r := range records {
+			if r.SessionID == sessionID && r.Recording != "" {
+				path = r.Recording
+			}
+		}
+	}
+	data, err := dockerClient.ReadFile(clicontext.Context, name, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the recording of the session %s", sessionID)
+	}
+
+	if output := clicontext.Path("output"); output != "" {
+		return os.WriteFile(output, data, 0644)
+	}
+	return asciicast.Play(clicontext.Context, bytes.NewReader(data), os.Stdout,
+		clicontext.Float64("speed"), clicontext.Duration("max-idle"))
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/pkg/namesgenerator"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/envd"
+	"github.com/tensorchord/envd/pkg/remote/sshd"
+	sshconfig "github.com/tensorchord/envd/pkg/ssh/config"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+var CommandSSHKey = &cli.Command{
+	Name:     "ssh-key",
+	Category: CategoryManagement,
+	Usage:    "Manage the ssh key pair of envd",
+
+	Subcommands: []*cli.Command{
+		CommandRotateSSHKey,
+	},
+}
+
+var CommandRotateSSHKey = &cli.Command{
+	Name:  "rotate",
+	Usage: "Generate a new ssh key pair and push it to the running environments",
+	Description: `
+The old private key is kept for the environments which are not running, and
+their entries in the ssh config still use it until they are rebuilt.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "type",
+			Usage:       "Type of the new ssh key pair, rsa or ed25519",
+			DefaultText: "type of the current key",
+		},
+	},
+	Action: rotateSSHKey,
+}
+
+func rotateSSHKey(clicontext *cli.Context) error {
+	public, err := sshconfig.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	private, err := sshconfig.GetPrivateKey()
+	if err != nil {
+		return err
+	}
+	oldKey, _, err := parsePublicKey(public)
+	if err != nil {
+		return err
+	}
+
+	keyType, err := sshconfig.GetKeyType(public)
+	if err != nil {
+		return err
+	}
+	if t := clicontext.String("type"); t != "" {
+		if keyType, err = sshconfig.ParseKeyType(t); err != nil {
+			return err
+		}
+	}
+	newPublic, newPrivate, err := sshconfig.GenerateKeyPair(keyType)
+	if err != nil {
+		return err
+	}
+	newKey, _, err := parsePublicKey(string(newPublic))
+	if err != nil {
+		return err
+	}
+
+	envdEngine, err := envd.New(clicontext.Context)
+	if err != nil {
+		return errors.Wrap(err, "failed to create envd engine")
+	}
+	envs, err := envdEngine.ListEnvironment(clicontext.Context)
+	if err != nil {
+		return err
+	}
+	dockerClient, err := docker.NewClient(clicontext.Context)
+	if err != nil {
+		return err
+	}
+
+	// Authorize the new key before switching to it, thus the environments
+	// are still accessible if the rotation fails halfway.
+	updated := []string{}
+	for _, env := range envs {
+		logger := logrus.WithField("env", env.Name)
+		if env.State != "running" {
+			logger.Warnf("the environment is %s, rebuild it with envd up to use the new key", env.State)
+			continue
+		}
+		if err := rewriteAuthorizedKeys(clicontext.Context, dockerClient, env.Name,
+			func(data []byte) []byte {
+				line := sshd.FormatAuthorizedKey(newKey, "", "envd")
+				return append(data, []byte(line+"\n")...)
+			}); err != nil {
+			logger.WithError(err).Warn("failed to push the new key, the environment keeps using the old key")
+			continue
+		}
+		updated = append(updated, env.Name)
+	}
+
+	// The old private key is moved aside, and the ssh config entries use it.
+	backup, err := backupKeyPath(private)
+	if err != nil {
+		return err
+	}
+	if err := sshconfig.ReplaceKeyManagedByEnvd(private, backup); err != nil {
+		return errors.Wrap(err, "failed to move the old private key")
+	}
+	if err := sshconfig.WriteKeys(public, private, newPublic, newPrivate); err != nil {
+		return err
+	}
+	logrus.Infof("the new %s key pair is written to %s and %s, the old private key is moved to %s",
+		keyType, public, private, backup)
+
+	for _, name := range updated {
+		logger := logrus.WithField("env", name)
+		if err := sshconfig.SetIdentityFile(name, private); err != nil {
+			logger.WithError(err).Warn("failed to update the ssh config")
+			continue
+		}
+		if err := rewriteAuthorizedKeys(clicontext.Context, dockerClient, name,
+			func(data []byte) []byte {
+				return removeAuthorizedKey(data, oldKey)
+			}); err != nil {
+			logger.WithError(err).Warn("failed to remove the old key")
+			continue
+		}
+		logger.Info("the environment uses the new key")
+	}
+	return nil
+}
+
+// backupKeyPath returns a new path next to the private key.
+func backupKeyPath(private string) (string, error) {
+	for {
+		p := filepath.Join(filepath.Dir(private),
+			fmt.Sprintf("envd_%s.pk", namesgenerator.GetRandomName(0)))
+		exists, err := fileutil.FileExists(p)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return p, nil
+		}
+	}
+}
+
+// rewriteAuthorizedKeys replaces the authorized_keys file in the running
+// environment, the file is renamed in place thus envd-ssh reloads it.
+func rewriteAuthorizedKeys(ctx context.Context, dockerClient docker.Client,
+	name string, fn func([]byte) []byte) error {
+	data, err := dockerClient.ReadFile(ctx, name, config.ContainerAuthorizedKeysPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the authorized keys")
+	}
+	return writeAuthorizedKeys(ctx, dockerClient, name, fn(data))
+}
+
+// writeAuthorizedKeys writes the authorized_keys file by envd-ssh as root,
+// since the file is owned by root if the sessions run as the users.
+func writeAuthorizedKeys(ctx context.Context, dockerClient docker.Client,
+	name string, data []byte) error {
+	cmd := []string{config.ContainerEnvdSSHPath,
+		"--authorized-keys", config.ContainerAuthorizedKeysPath,
+		sshd.AuthorizedKeysCommand, string(data)}
+	var stderr bytes.Buffer
+	if err := dockerClient.ExecOutputAs(ctx, name, "root", cmd, &bytes.Buffer{}, &stderr); err != nil {
+		return errors.Wrapf(err, "failed to write the authorized keys: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// removeAuthorizedKey removes the lines of the key, and keeps the others.
+func removeAuthorizedKey(data []byte, key gossh.PublicKey) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if k, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line)); err == nil &&
+			bytes.Equal(k.Marshal(), key.Marshal()) {
+			continue
+		}
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/ssh"
+)
+
+// syncIgnoreFile is read from the local directory for the ignore patterns.
+const syncIgnoreFile = ".envdignore"
+
+var CommandSync = &cli.Command{
+	Name:      "sync",
+	Category:  CategoryBasic,
+	Usage:     "Sync the local directory to the envd environment",
+	ArgsUsage: "<local> <env>:<path>",
+	Description: `
+Only the files changed since the last sync are uploaded, which are compared by
+SHA256. The patterns in the .envdignore file in the local directory are
+ignored, in the same format as .dockerignore.
+To sync the current directory to the environment mnist, and keep syncing the changes:
+	$ envd sync --watch . mnist:/home/envd/mnist
+`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "watch",
+			Usage:   "Keep syncing the changes until interrupted",
+			Aliases: []string{"w"},
+		},
+		&cli.BoolFlag{
+			Name:  "delete",
+			Usage: "Delete the files in the environment which do not exist locally",
+		},
+		&cli.StringSliceFlag{
+			Name:    "ignore",
+			Usage:   "Patterns of the files not to sync, in addition to the ones in .envdignore",
+			Aliases: []string{"i"},
+		},
+	},
+	Action: syncFiles,
+}
+
+func syncFiles(clicontext *cli.Context) error {
+	if clicontext.NArg() != 2 {
+		return errors.New("expected the local directory and the destination")
+	}
+	local := clicontext.Args().Get(0)
+	name, remote := parseEnvPath(clicontext.Args().Get(1))
+	if name == "" {
+		return errors.New("the destination should be in the <env>:<path> format")
+	}
+	if info, err := os.Stat(local); err != nil || !info.IsDir() {
+		return errors.Newf("%s is not a directory", local)
+	}
+	if remote == "" {
+		remote = "."
+	}
+
+	ignore, err := readIgnoreFile(filepath.Join(local, syncIgnoreFile))
+	if err != nil {
+		return err
+	}
+	ignore = append(ignore, clicontext.StringSlice("ignore")...)
+
+	opt, err := ssh.GetOptions(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the ssh options")
+	}
+	cli, err := ssh.NewClient(*opt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the ssh client")
+	}
+	defer cli.Close()
+	syncer, err := ssh.NewSyncer(cli, local, remote, ssh.SyncOptions{
+		Ignore: ignore,
+		Delete: clicontext.Bool("delete"),
+	})
+	if err != nil {
+		return err
+	}
+	defer syncer.Close()
+
+	res, err := syncer.Sync()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("synced %s to %s: %d uploaded, %d deleted",
+		local, clicontext.Args().Get(1), res.Uploaded, res.Deleted)
+	if !clicontext.Bool("watch") {
+		return nil
+	}
+
+	logrus.Infof("watching the changes in %s", local)
+	ctx, stop := signal.NotifyContext(clicontext.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return syncer.Watch(ctx, func(res ssh.SyncResult) {
+		logrus.Infof("synced the changes: %d uploaded, %d deleted", res.Uploaded, res.Deleted)
+	})
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+	patterns, err := dockerignore.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	return patterns, nil
+}
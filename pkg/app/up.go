@@ -15,6 +15,7 @@
 package app
 
 import (
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -23,7 +24,9 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/tensorchord/envd/pkg/builder"
+	"github.com/tensorchord/envd/pkg/config"
 	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/home"
 	"github.com/tensorchord/envd/pkg/lang/ir"
 	"github.com/tensorchord/envd/pkg/ssh"
 	sshconfig "github.com/tensorchord/envd/pkg/ssh/config"
@@ -54,7 +57,7 @@ var CommandUp = &cli.Command{
 		},
 		&cli.StringSliceFlag{
 			Name:    "volume",
-			Usage:   "Mount host directory into container",
+			Usage:   "Mount host directory or named volume into container, format `SRC:DEST[:ro|cow]`",
 			Aliases: []string{"v"},
 		},
 		&cli.PathFlag{
@@ -97,7 +100,33 @@ var CommandUp = &cli.Command{
 			Usage: "Force rebuild and run the container although the previous container is running",
 			Value: false,
 		},
+		&cli.BoolFlag{
+			Name:  "frozen",
+			Usage: "Fail if envd.lock is missing or out of date",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "audit",
+			Usage: "Write the audit log of the SSH sessions, see `envd sessions`",
+		},
+		&cli.BoolFlag{
+			Name:  "audit-recording",
+			Usage: "Record the PTY sessions in the audit log, implies --audit",
+		},
 		// https://github.com/urfave/cli/issues/1134#issuecomment-1191407527
+		&cli.StringFlag{
+			Name:        "platform",
+			Usage:       "Target platform of the environment (e.g. linux/arm64)",
+			DefaultText: "linux/amd64",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ssh",
+			Usage: "Forward the ssh agent or keys to the build for run(mount_ssh=True) (e.g. default or default=<path>)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "secret",
+			Usage: "Secret declared by config.secret (e.g. id=pypi,env=PIP_INDEX_URL or id=netrc,src=path)",
+		},
 		&cli.StringFlag{
 			Name:    "export-cache",
 			Usage:   "Export the cache (e.g. type=registry,ref=<image>)",
@@ -128,6 +157,15 @@ func up(clicontext *cli.Context) error {
 	})
 	logger.Debug("starting up command")
 
+	c, err := home.GetManager().ContextGetCurrent()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the current context")
+	}
+	if c.RunsOnKubernetes() {
+		// The image should be pulled by the cluster.
+		buildOpt.OutputOpts = fmt.Sprintf("type=image,name=%s,push=true", buildOpt.Tag)
+	}
+
 	builder, err := GetBuilder(clicontext, buildOpt)
 	if err != nil {
 		return err
@@ -149,6 +187,10 @@ func up(clicontext *cli.Context) error {
 	}
 	numGPUs := builder.NumGPUs()
 
+	if c.RunsOnKubernetes() {
+		return upKubernetes(clicontext, buildOpt, *c, gpu, numGPUs, detach)
+	}
+
 	sshPortInHost, error := StartEnvd(clicontext, buildOpt, gpu, numGPUs)
 	if error != nil {
 		return error
@@ -198,7 +240,7 @@ func StartEnvd(clicontext *cli.Context, buildOpt builder.Options, gpu bool, numG
 		return 0, errors.Wrap(err, "failed to clean the envd environment")
 	}
 	containerID, containerIP, err := dockerClient.StartEnvd(clicontext.Context,
-		buildOpt.Tag, ctr, buildOpt.BuildContextDir, gpu, numGPUs, sshPortInHost, *ir.DefaultGraph, clicontext.Duration("timeout"),
+		buildOpt.Tag, ctr, buildOpt.BuildContextDir, gpu, numGPUs, sshPortInHost, runtimeGraph(clicontext), clicontext.Duration("timeout"),
 		clicontext.StringSlice("volume"))
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to start the envd environment")
@@ -214,3 +256,21 @@ func StartEnvd(clicontext *cli.Context, buildOpt builder.Options, gpu bool, numG
 	return sshPortInHost, nil
 
 }
+
+// runtimeGraph returns the graph with the environment variables set by the
+// flags, e.g. --audit.
+func runtimeGraph(clicontext *cli.Context) ir.Graph {
+	g := *ir.DefaultGraph
+	env := map[string]string{}
+	for k, v := range g.RuntimeEnviron {
+		env[k] = v
+	}
+	if clicontext.Bool("audit") || clicontext.Bool("audit-recording") {
+		env[config.EnvAuditLog] = config.ContainerAuditLogPath
+	}
+	if clicontext.Bool("audit-recording") {
+		env[config.EnvAuditRecordingDir] = config.ContainerAuditRecordingDir
+	}
+	g.RuntimeEnviron = env
+	return g
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tensorchord/envd/pkg/builder"
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/kubernetes"
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/ssh"
+	sshconfig "github.com/tensorchord/envd/pkg/ssh/config"
+	"github.com/tensorchord/envd/pkg/types"
+	"github.com/tensorchord/envd/pkg/util/netutil"
+)
+
+// upKubernetes runs the environment in kubernetes, and forwards the SSH port
+// to the local host until the session ends. It returns once the pod is
+// created if detach is true.
+func upKubernetes(clicontext *cli.Context, buildOpt builder.Options,
+	c types.Context, gpu bool, numGPUs int, detach bool) error {
+	ctx, cancel := signal.NotifyContext(clicontext.Context, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cli, pod, err := StartEnvdOnKubernetes(ctx, clicontext, buildOpt, c, gpu, numGPUs, detach)
+	if err != nil {
+		return err
+	}
+	if detach {
+		logrus.Infof("The environment %s is created in the namespace %s, forward its SSH port "+
+			"with `kubectl port-forward -n %s pod/%s <port>:%d` once it is ready",
+			pod, cli.Namespace(), cli.Namespace(), pod, config.SSHPortInContainer)
+		return nil
+	}
+
+	sshPortInHost, err := netutil.GetFreePort()
+	if err != nil {
+		return errors.Wrap(err, "failed to get a free port")
+	}
+	ready := make(chan struct{})
+	errC := make(chan error, 1)
+	go func() {
+		errC <- cli.PortForward(ctx, pod, sshPortInHost, config.SSHPortInContainer, ready)
+	}()
+	select {
+	case <-ready:
+	case err := <-errC:
+		return errors.Wrap(err, "failed to forward the ssh port")
+	}
+
+	ctr := filepath.Base(buildOpt.BuildContextDir)
+	if err = sshconfig.AddEntry(
+		ctr, localhost, sshPortInHost, clicontext.Path("private-key")); err != nil {
+		return errors.Wrap(err, "failed to add entry to your SSH config file")
+	}
+
+	opt := ssh.DefaultOptions()
+	opt.PrivateKeyPath = clicontext.Path("private-key")
+	opt.Port = sshPortInHost
+	sshClient, err := ssh.NewClient(opt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the ssh client")
+	}
+	if err := sshClient.Attach(); err != nil {
+		return errors.Wrap(err, "failed to attach to the pod")
+	}
+	return nil
+}
+
+// StartEnvdOnKubernetes creates the pod of the environment and returns its
+// name. It waits until the pod is ready unless detach is true.
+func StartEnvdOnKubernetes(ctx context.Context, clicontext *cli.Context,
+	buildOpt builder.Options, c types.Context, gpu bool, numGPUs int, detach bool) (kubernetes.Client, string, error) {
+	cli, err := kubernetes.NewClient(c.RunnerAddress, c.RunnerNamespace)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create the kubernetes client")
+	}
+
+	ctr := filepath.Base(buildOpt.BuildContextDir)
+	if err := cli.CleanEnvdIfExists(ctx, ctr); err != nil {
+		return nil, "", errors.Wrap(err, "failed to clean the envd environment")
+	}
+	ep, err := ir.CompileEntrypoint(buildOpt.BuildContextDir)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get entrypoint")
+	}
+	pod, err := cli.StartEnvd(ctx, kubernetes.StartOptions{
+		Tag:             buildOpt.Tag,
+		Name:            ctr,
+		BuildContextDir: buildOpt.BuildContextDir,
+		Entrypoint:      ep,
+		GPUEnabled:      gpu,
+		NumGPUs:         numGPUs,
+		Graph:           runtimeGraph(clicontext),
+		Timeout:         clicontext.Duration("timeout"),
+		Detach:          detach,
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to start the envd environment")
+	}
+	logrus.WithFields(logrus.Fields{
+		"namespace": cli.Namespace(),
+		"pod":       pod,
+	}).Debug("pod is started")
+	return cli, pod, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// environmentName returns the environment from --name, or the build context
+// in --path, which defaults to the current directory.
+func environmentName(clicontext *cli.Context) (string, error) {
+	path := clicontext.Path("path")
+	name := clicontext.String("name")
+	if path != "" && name != "" {
+		return "", errors.New("Cannot specify --path and --name at the same time.")
+	}
+	if name != "" {
+		return name, nil
+	}
+	if path == "" {
+		path = "."
+	}
+	buildContext, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get absolute path of the build context")
+	}
+	return filepath.Base(buildContext), nil
+}
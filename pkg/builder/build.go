@@ -16,55 +16,119 @@ package builder
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
 	"github.com/cockroachdb/errors"
+	"github.com/containerd/containerd/platforms"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
 	"github.com/moby/buildkit/frontend/gateway/client"
+	"golang.org/x/sync/errgroup"
 )
 
 func (b generalBuilder) BuildFunc() func(ctx context.Context, c client.Client) (*client.Result, error) {
 	return func(ctx context.Context, c client.Client) (*client.Result, error) {
 		b.logger.Debug("running BuildFunc for envd")
 
-		sreq := client.SolveRequest{
-			Definition: b.definition.ToPB(),
+		cacheImports, err := b.cacheImports()
+		if err != nil {
+			return nil, err
 		}
 
-		// Get the envd default cache importer in docker.io/tensorchord/...
-		if defaultImporter, err := b.defaultCacheImporter(); err != nil {
-			return nil, errors.Wrap(err, "failed to get default importer")
-		} else if defaultImporter != nil {
-			b.logger.WithField("default-cache", *defaultImporter).
-				Debug("import remote cache")
-			ci, err := ParseImportCache([]string{*defaultImporter})
+		targets := b.targetPlatforms()
+		if len(targets) == 1 {
+			res, err := c.Solve(ctx, client.SolveRequest{
+				Definition:   b.definitions[0].ToPB(),
+				CacheImports: cacheImports,
+			})
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to get the import cache")
+				return nil, errors.Wrap(err, "failed to solve")
 			}
-			sreq.CacheImports = append(sreq.CacheImports, ci...)
-		}
 
-		// Get the user-defined cache importer.
-		if b.Options.ImportCache != "" {
-			ci, err := ParseImportCache([]string{b.Options.ImportCache})
+			imageConfig, err := b.imageConfig(ctx, targets[0])
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to get the import cache")
+				return nil, errors.Wrap(err, "failed to get labels")
 			}
-			sreq.CacheImports = append(sreq.CacheImports, ci...)
+
+			res.AddMeta(exptypes.ExporterImageConfigKey, []byte(imageConfig))
+			b.logger.Debugf("setting image config: %s", imageConfig)
+
+			return res, nil
 		}
 
-		res, err := c.Solve(ctx, sreq)
+		// Solve the platforms in parallel, the image exporter pushes them
+		// as a manifest list.
+		res := client.NewResult()
+		exp := exptypes.Platforms{
+			Platforms: make([]exptypes.Platform, len(targets)),
+		}
+		eg, ctx := errgroup.WithContext(ctx)
+		for i := range targets {
+			i := i
+			eg.Go(func() error {
+				p := targets[i]
+				id := platforms.Format(p)
+				r, err := c.Solve(ctx, client.SolveRequest{
+					Definition:   b.definitions[i].ToPB(),
+					CacheImports: cacheImports,
+				})
+				if err != nil {
+					return errors.Wrapf(err, "failed to solve for %s", id)
+				}
+				ref, err := r.SingleRef()
+				if err != nil {
+					return err
+				}
+				imageConfig, err := b.imageConfig(ctx, p)
+				if err != nil {
+					return errors.Wrap(err, "failed to get labels")
+				}
+				res.AddRef(id, ref)
+				res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageConfigKey, id), []byte(imageConfig))
+				exp.Platforms[i] = exptypes.Platform{
+					ID:       id,
+					Platform: p,
+				}
+				return nil
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+		dt, err := json.Marshal(exp)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to solve")
+			return nil, errors.Wrap(err, "failed to marshal the platforms")
 		}
+		res.AddMeta(exptypes.ExporterPlatformsKey, dt)
+		return res, nil
+	}
+}
 
-		imageConfig, err := b.imageConfig(ctx)
+// cacheImports returns the envd default cache importer and the
+// user-defined one.
+func (b generalBuilder) cacheImports() ([]client.CacheOptionsEntry, error) {
+	cacheImports := []client.CacheOptionsEntry{}
+
+	// Get the envd default cache importer in docker.io/tensorchord/...
+	if defaultImporter, err := b.defaultCacheImporter(); err != nil {
+		return nil, errors.Wrap(err, "failed to get default importer")
+	} else if defaultImporter != nil {
+		b.logger.WithField("default-cache", *defaultImporter).
+			Debug("import remote cache")
+		ci, err := ParseImportCache([]string{*defaultImporter})
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get labels")
+			return nil, errors.Wrap(err, "failed to get the import cache")
 		}
+		cacheImports = append(cacheImports, ci...)
+	}
 
-		res.AddMeta(exptypes.ExporterImageConfigKey, []byte(imageConfig))
-		b.logger.Debugf("setting image config: %s", imageConfig)
-
-		return res, nil
+	// Get the user-defined cache importer.
+	if b.Options.ImportCache != "" {
+		ci, err := ParseImportCache([]string{b.Options.ImportCache})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the import cache")
+		}
+		cacheImports = append(cacheImports, ci...)
 	}
+	return cacheImports, nil
 }
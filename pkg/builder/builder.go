@@ -16,6 +16,7 @@ package builder
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,6 +26,10 @@ import (
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
@@ -34,8 +39,11 @@ import (
 	"github.com/tensorchord/envd/pkg/home"
 	"github.com/tensorchord/envd/pkg/lang/frontend/starlark"
 	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/lockfile"
 	"github.com/tensorchord/envd/pkg/progress/progresswriter"
+	"github.com/tensorchord/envd/pkg/progress/report"
 	"github.com/tensorchord/envd/pkg/types"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
 )
 
 type Builder interface {
@@ -50,7 +58,7 @@ type Options struct {
 	ManifestFilePath string
 	// ConfigFilePath is the path to the config file `config.envd`.
 	ConfigFilePath string
-	// ProgressMode is the output mode (auto, plain).
+	// ProgressMode is the output mode (auto, plain, tty, json).
 	ProgressMode string
 	// Tag is the name of the image.
 	Tag string
@@ -68,14 +76,35 @@ type Options struct {
 	// ImportCache is the option to import cache.
 	// e.g. type=registry,ref=docker.io/username/image
 	ImportCache string
+	// Frozen fails the build if envd.lock is missing or out of date.
+	Frozen bool
+	// IgnoreLockfile builds without the versions pinned in envd.lock.
+	IgnoreLockfile bool
+	// PrintReport prints the timings and the cache summary after the build.
+	PrintReport bool
+	// ReportPath is the path to save the build report as JSON.
+	ReportPath string
+	// Secrets are the values of the secrets declared by config.secret.
+	// e.g. id=pypi,env=PIP_INDEX_URL
+	Secrets []string
+	// SSH are the SSH agents or keys forwarded to the build.
+	// e.g. default, default=/home/user/.ssh/id_rsa
+	SSH []string
+	// Platform is the comma-separated target platforms.
+	// e.g. linux/amd64,linux/arm64
+	Platform string
 }
 
 type generalBuilder struct {
 	Options
 	manifestCodeHash string
 	entries          []client.ExportEntry
+	secrets          []secretsprovider.Source
+	sshAgents        []sshprovider.AgentConfig
 
-	definition *llb.Definition
+	platforms []v1.Platform
+	// definitions are the compiled definitions of the platforms.
+	definitions []*llb.Definition
 
 	logger *logrus.Entry
 	starlark.Interpreter
@@ -100,25 +129,51 @@ func New(ctx context.Context, opt Options) (Builder, error) {
 		return nil, errors.New("only one output type is supported")
 	}
 
+	secretSources, err := ParseSecrets(opt.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	sshAgents, err := ParseSSH(opt.SSH)
+	if err != nil {
+		return nil, err
+	}
+
+	platforms, err := ParsePlatforms(opt.Platform)
+	if err != nil {
+		return nil, err
+	}
+	if len(platforms) > 1 && entries[0].Type == client.ExporterDocker {
+		return nil, errors.New("the multi-platform image cannot be loaded into docker, " +
+			"push it with --output type=image,name=<image>,push=true")
+	}
+
 	manifestHash, err := starlark.GetEnvdProgramHash(opt.ManifestFilePath)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to compile manifest file")
 	}
+	// The image is rebuilt if the platform changes.
+	if opt.Platform != "" {
+		manifestHash = fmt.Sprintf("%s-%s", manifestHash, formatPlatforms(platforms))
+	}
 
 	b := &generalBuilder{
 		Options:          opt,
 		manifestCodeHash: manifestHash,
 		entries:          entries,
+		secrets:          secretSources,
+		sshAgents:        sshAgents,
+		platforms:        platforms,
 		logger: logrus.WithFields(logrus.Fields{
 			"tag": opt.Tag,
 		}),
 	}
 
-	currentDriver, currentSocket, err := home.GetManager().ContextGetCurrent()
+	c, err := home.GetManager().ContextGetCurrent()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get the current context")
 	}
-	cli, err := buildkitd.NewClient(ctx, currentDriver, currentSocket, "")
+	cli, err := buildkitd.NewClient(ctx, c.Builder, c.BuilderSocket, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create buildkit client")
 	}
@@ -128,6 +183,19 @@ func New(ctx context.Context, opt Options) (Builder, error) {
 	return b, nil
 }
 
+// Interpret evaluates the manifest into ir.DefaultGraph without connecting
+// to buildkitd, e.g. to export the environment.
+func Interpret(opt Options) error {
+	b := generalBuilder{
+		Options: opt,
+		logger: logrus.WithFields(logrus.Fields{
+			"tag": opt.Tag,
+		}),
+		Interpreter: starlark.NewInterpreter(opt.BuildContextDir),
+	}
+	return b.Interpret()
+}
+
 // GPUEnabled returns true if cuda is enabled.
 func (b generalBuilder) GPUEnabled() bool {
 	return ir.GPUEnabled()
@@ -143,23 +211,73 @@ func (b generalBuilder) Build(ctx context.Context, force bool) error {
 		return nil
 	}
 
-	def, err := b.compile(ctx)
+	defs, err := b.compile(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to compile")
 	}
-	b.definition = def
+	b.definitions = defs
 
 	pw, err := progresswriter.NewPrinter(ctx, os.Stdout, b.ProgressMode)
 	if err != nil {
 		return errors.Wrap(err, "failed to create progress writer")
 	}
 
-	if err = b.build(ctx, pw); err != nil {
+	if !b.PrintReport && b.ReportPath == "" {
+		if err = b.build(ctx, pw); err != nil {
+			return errors.Wrap(err, "failed to build")
+		}
+		return nil
+	}
+
+	// The rule digests of the previous build are read before the image
+	// is replaced.
+	previous := b.previousRuleDigests(ctx)
+	collector := report.NewCollector()
+	ch := make(chan *client.SolveStatus)
+	collected := make(chan struct{})
+	go func() {
+		collector.Collect(ch)
+		close(collected)
+	}()
+	if err = b.build(ctx, progresswriter.Tee(pw, ch)); err != nil {
 		return errors.Wrap(err, "failed to build")
 	}
+	<-collected
+
+	r := collector.Report(b.Tag, report.DefaultSlowest)
+	r.Compare(previous, ir.RuleDigests())
+	if b.PrintReport {
+		report.Print(os.Stdout, r)
+	}
+	if b.ReportPath != "" {
+		if err := r.WriteFile(b.ReportPath); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// previousRuleDigests returns the rule digests of the image of the tag,
+// or nil if there is no such image.
+func (b generalBuilder) previousRuleDigests(ctx context.Context) []types.RuleDigest {
+	dockerClient, err := docker.NewClient(ctx)
+	if err != nil {
+		b.logger.Debugf("failed to create docker client: %s", err)
+		return nil
+	}
+	image, err := dockerClient.GetImage(ctx, b.Tag)
+	if err != nil {
+		b.logger.Debugf("failed to get the previous image: %s", err)
+		return nil
+	}
+	digests, err := types.RuleDigestsFromLabels(image.Labels)
+	if err != nil {
+		b.logger.Debugf("failed to parse the rule digests: %s", err)
+		return nil
+	}
+	return digests
+}
+
 func (b generalBuilder) Interpret() error {
 	// Evaluate config first.
 	if b.ConfigFilePath != "" {
@@ -172,24 +290,64 @@ func (b generalBuilder) Interpret() error {
 	if _, err := b.ExecFile(b.ManifestFilePath, b.BuildFuncName); err != nil {
 		return errors.Wrapf(err, "failed to exec starlark file %s", b.ManifestFilePath)
 	}
+	return b.loadLockfile()
+}
+
+// loadLockfile pins the versions of the packages if envd.lock exists.
+func (b generalBuilder) loadLockfile() error {
+	if b.IgnoreLockfile {
+		return nil
+	}
+	dir := filepath.Dir(b.ManifestFilePath)
+	f, err := lockfile.Load(dir)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		if b.Frozen {
+			return errors.Newf("%s does not exist, please run `envd lock` first", lockfile.Path(dir))
+		}
+		return nil
+	}
+
+	r, err := ir.Requirements(b.BuildContextDir)
+	if err != nil {
+		return err
+	}
+	if err := f.Check(r); err != nil {
+		if b.Frozen {
+			return err
+		}
+		b.logger.Warnf("%s, please run `envd lock` to update it", err)
+	}
+	b.logger.WithField("lockfile", lockfile.Path(dir)).Debug("pin the packages with the lockfile")
+	ir.Lockfile(f)
 	return nil
 }
 
-func (b generalBuilder) compile(ctx context.Context) (*llb.Definition, error) {
+func (b generalBuilder) compile(ctx context.Context) ([]*llb.Definition, error) {
 	envName := filepath.Base(b.BuildContextDir)
-	def, err := ir.Compile(ctx, envName, b.PubKeyPath)
+	defs, err := ir.Compile(ctx, envName, b.PubKeyPath, b.ProgressMode, b.targetPlatforms())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to compile build.envd")
 	}
 	b.logger.Debug("compiled build.envd")
-	return def, nil
+	return defs, nil
+}
+
+// targetPlatforms returns the platforms to build, linux/amd64 by default.
+func (b generalBuilder) targetPlatforms() []v1.Platform {
+	if len(b.platforms) == 0 {
+		return []v1.Platform{ir.DefaultPlatform}
+	}
+	return b.platforms
 }
 
 func (b generalBuilder) addBuilderTag(labels *map[string]string) {
 	(*labels)[types.ImageLabelCacheHash] = b.manifestCodeHash
 }
 
-func (b generalBuilder) imageConfig(ctx context.Context) (string, error) {
+func (b generalBuilder) imageConfig(ctx context.Context, platform v1.Platform) (string, error) {
 	labels, err := ir.Labels()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get labels")
@@ -210,7 +368,7 @@ func (b generalBuilder) imageConfig(ctx context.Context) (string, error) {
 
 	env := ir.CompileEnviron()
 
-	data, err := ImageConfigStr(labels, ports, ep, env)
+	data, err := ImageConfigStr(labels, ports, ep, env, platform)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get image config")
 	}
@@ -235,12 +393,27 @@ func (b generalBuilder) build(ctx context.Context, pw progresswriter.Writer) err
 	defer cancel()
 	eg, ctx := errgroup.WithContext(ctx)
 
+	secretStore, err := b.secretStore()
+	if err != nil {
+		return err
+	}
+	sshProvider, err := b.sshProvider()
+	if err != nil {
+		return err
+	}
+
 	// Create a pipe to load the image into the docker host.
 	pipeR, pipeW := io.Pipe()
 
 	for _, entry := range b.entries {
 		// Set up docker config auth.
-		attachable := []session.Attachable{authprovider.NewDockerAuthProvider(os.Stderr)}
+		attachable := []session.Attachable{
+			authprovider.NewDockerAuthProvider(os.Stderr),
+			secretsprovider.NewSecretProvider(secretStore),
+		}
+		if sshProvider != nil {
+			attachable = append(attachable, sshProvider)
+		}
 		b.logger.WithFields(logrus.Fields{
 			"type": entry.Type,
 		}).Debug("build image with buildkit")
@@ -336,11 +509,50 @@ func (b generalBuilder) build(ctx context.Context, pw progresswriter.Writer) err
 	return nil
 }
 
+// secretStore returns the values of the secrets declared in build.envd.
+func (b generalBuilder) secretStore() (secrets.SecretStore, error) {
+	provided := map[string]bool{}
+	for _, s := range b.secrets {
+		provided[s.ID] = true
+	}
+	for _, id := range ir.SecretIDs() {
+		if !provided[id] {
+			return nil, errors.Newf("secret %s is not provided, use --secret id=%s,env=<env> or --secret id=%s,src=<file>",
+				id, id, id)
+		}
+	}
+	store, err := secretsprovider.NewStore(b.secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the secrets")
+	}
+	return store, nil
+}
+
+// sshProvider forwards the SSH agents to the build, or returns nil if
+// --ssh is not set.
+func (b generalBuilder) sshProvider() (session.Attachable, error) {
+	if len(b.sshAgents) == 0 {
+		if ir.SSHRequired() {
+			return nil, errors.New("run(mount_ssh=True) requires the ssh agent, use --ssh default")
+		}
+		return nil, nil
+	}
+	provider, err := sshprovider.NewSSHAgentProvider(b.sshAgents)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to forward the ssh agent")
+	}
+	return provider, nil
+}
+
 func (b generalBuilder) checkIfNeedBuild(ctx context.Context) bool {
 	depsFiles := []string{
 		b.PubKeyPath,
 		b.ConfigFilePath,
 	}
+	lock := lockfile.Path(filepath.Dir(b.ManifestFilePath))
+	if exists, _ := fileutil.FileExists(lock); exists && !b.IgnoreLockfile {
+		depsFiles = append(depsFiles, lock)
+	}
 	isUpdated, err := b.checkDepsFileUpdate(ctx, b.Tag, b.ManifestFilePath, depsFiles)
 	if err != nil {
 		b.logger.Debugf("failed to check manifest update: %s", err)
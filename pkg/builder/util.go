@@ -26,8 +26,12 @@ import (
 	"github.com/containerd/containerd/platforms"
 	"github.com/moby/buildkit/client"
 	gatewayclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/lang/ir"
 )
 
 const (
@@ -36,8 +40,8 @@ const (
 )
 
 func ImageConfigStr(labels map[string]string, ports map[string]struct{},
-	entrypoint []string, env []string) (string, error) {
-	pl := platforms.Normalize(platforms.DefaultSpec())
+	entrypoint []string, env []string, platform v1.Platform) (string, error) {
+	pl := platforms.Normalize(platform)
 	img := v1.Image{
 		Config: v1.ImageConfig{
 			Labels:       labels,
@@ -47,6 +51,7 @@ func ImageConfigStr(labels map[string]string, ports map[string]struct{},
 			Entrypoint:   entrypoint,
 		},
 		Architecture: pl.Architecture,
+		Variant:      pl.Variant,
 		// Refer to https://github.com/tensorchord/envd/issues/269#issuecomment-1152944914
 		OS: "linux",
 		RootFS: v1.RootFS{
@@ -216,6 +221,108 @@ func parseExportCacheCSV(s string) (client.CacheOptionsEntry, error) {
 	return ex, nil
 }
 
+// ParseSecrets parses --secret, e.g. id=pypi,env=PIP_INDEX_URL or
+// id=netrc,src=/home/user/.netrc.
+// Refer to https://github.com/moby/buildkit/blob/master/cmd/buildctl/build/secret.go
+func ParseSecrets(secrets []string) ([]secretsprovider.Source, error) {
+	sources := []secretsprovider.Source{}
+	for _, s := range secrets {
+		source, err := parseSecretCSV(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse secret %s", s)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func parseSecretCSV(s string) (secretsprovider.Source, error) {
+	fs := secretsprovider.Source{}
+	csvReader := csv.NewReader(strings.NewReader(s))
+	fields, err := csvReader.Read()
+	if err != nil {
+		return fs, err
+	}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fs, errors.Errorf("invalid value %s", field)
+		}
+		key := strings.ToLower(parts[0])
+		value := parts[1]
+		switch key {
+		case "id":
+			fs.ID = value
+		case "source", "src":
+			fs.FilePath = value
+		case "env":
+			fs.Env = value
+		default:
+			return fs, errors.Errorf("unexpected key '%s' in '%s'", key, field)
+		}
+	}
+	if fs.ID == "" {
+		return fs, errors.New("--secret requires id=<id>")
+	}
+	if fs.FilePath != "" && fs.Env != "" {
+		return fs, errors.New("--secret accepts either src or env")
+	}
+	return fs, nil
+}
+
+// ParsePlatforms parses --platform, e.g. linux/amd64,linux/arm64.
+func ParsePlatforms(s string) ([]v1.Platform, error) {
+	if s == "" {
+		return nil, nil
+	}
+	result := []v1.Platform{}
+	seen := map[string]bool{}
+	for _, str := range strings.Split(s, ",") {
+		p, err := platforms.Parse(strings.TrimSpace(str))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse platform %s", str)
+		}
+		p = platforms.Normalize(p)
+		if err := ir.ValidatePlatform(p); err != nil {
+			return nil, err
+		}
+		if id := platforms.Format(p); !seen[id] {
+			seen[id] = true
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func formatPlatforms(ps []v1.Platform) string {
+	strs := []string{}
+	for _, p := range ps {
+		strs = append(strs, platforms.Format(p))
+	}
+	return strings.Join(strs, ",")
+}
+
+// ParseSSH parses --ssh, e.g. default, default=$SSH_AUTH_SOCK or
+// default=/home/user/.ssh/id_rsa.
+// Refer to https://github.com/moby/buildkit/blob/master/cmd/buildctl/build/ssh.go
+func ParseSSH(inp []string) ([]sshprovider.AgentConfig, error) {
+	configs := make([]sshprovider.AgentConfig, 0, len(inp))
+	for _, v := range inp {
+		parts := strings.SplitN(v, "=", 2)
+		if parts[0] == "" {
+			return nil, errors.Errorf("invalid ssh %s, the id is required", v)
+		}
+		cfg := sshprovider.AgentConfig{
+			ID: parts[0],
+		}
+		if len(parts) > 1 {
+			cfg.Paths = strings.Split(parts[1], ",")
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
 // parseOutput parses --output
 // Refer to https://github.com/moby/buildkit/blob/master/cmd/buildctl/build/output.go#L56
 func parseOutput(exports string) ([]client.ExportEntry, error) {
@@ -14,10 +14,15 @@
 package builder
 
 import (
+	"encoding/json"
+	"path/filepath"
 	"testing"
 
 	"github.com/moby/buildkit/client"
 	gatewayclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
@@ -132,6 +137,8 @@ func TestParseOutput(t *testing.T) {
 		output string
 	}
 
+	// The tar exporter creates the dest file, keep it out of the tree.
+	dest := filepath.Join(t.TempDir(), "test.tar")
 	tests := []struct {
 		name            string
 		args            args
@@ -141,7 +148,7 @@ func TestParseOutput(t *testing.T) {
 	}{{
 		"parsing output successfully",
 		args{
-			output: "type=tar,dest=test.tar",
+			output: "type=tar,dest=" + dest,
 		},
 		"tar",
 		1,
@@ -149,7 +156,7 @@ func TestParseOutput(t *testing.T) {
 	}, {
 		"output without type",
 		args{
-			output: "type=,dest=test.tar",
+			output: "type=,dest=" + dest,
 		},
 		"",
 		0,
@@ -258,3 +265,84 @@ func TestParseFromStr(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSecrets(t *testing.T) {
+	type testCase struct {
+		secrets     []string // --secret
+		expected    []secretsprovider.Source
+		expectedErr string
+	}
+	testCases := []testCase{
+		{
+			secrets: []string{"id=pypi,env=PIP_INDEX_URL", "id=netrc,src=/root/.netrc", "id=token"},
+			expected: []secretsprovider.Source{
+				{ID: "pypi", Env: "PIP_INDEX_URL"},
+				{ID: "netrc", FilePath: "/root/.netrc"},
+				{ID: "token"},
+			},
+		},
+		{
+			secrets:     []string{"env=PIP_INDEX_URL"},
+			expectedErr: "requires id",
+		},
+		{
+			secrets:     []string{"id=pypi,env=PIP_INDEX_URL,src=/root/.netrc"},
+			expectedErr: "either src or env",
+		},
+		{
+			secrets:     []string{"id=pypi,type=file"},
+			expectedErr: "unexpected key",
+		},
+	}
+	for _, tc := range testCases {
+		sources, err := ParseSecrets(tc.secrets)
+		if tc.expectedErr == "" {
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, sources)
+		} else {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		}
+	}
+}
+
+func TestParseSSH(t *testing.T) {
+	configs, err := ParseSSH([]string{"default", "github=/root/.ssh/id_rsa,/root/.ssh/id_ed25519"})
+	require.NoError(t, err)
+	require.Equal(t, []sshprovider.AgentConfig{
+		{ID: "default"},
+		{ID: "github", Paths: []string{"/root/.ssh/id_rsa", "/root/.ssh/id_ed25519"}},
+	}, configs)
+
+	_, err = ParseSSH([]string{"=/root/.ssh/id_rsa"})
+	require.Error(t, err)
+}
+
+func TestParsePlatforms(t *testing.T) {
+	ps, err := ParsePlatforms("linux/amd64, linux/arm64,linux/arm64/v8")
+	require.NoError(t, err)
+	require.Equal(t, []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}, ps)
+	require.Equal(t, "linux/amd64,linux/arm64", formatPlatforms(ps))
+
+	ps, err = ParsePlatforms("")
+	require.NoError(t, err)
+	require.Empty(t, ps)
+
+	_, err = ParsePlatforms("windows/amd64")
+	require.Error(t, err)
+	_, err = ParsePlatforms("linux/riscv64")
+	require.Error(t, err)
+}
+
+func TestImageConfigStr(t *testing.T) {
+	data, err := ImageConfigStr(map[string]string{}, map[string]struct{}{}, nil, nil,
+		v1.Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	var img v1.Image
+	require.NoError(t, json.Unmarshal([]byte(data), &img))
+	require.Equal(t, "arm64", img.Architecture)
+	require.Equal(t, "linux", img.OS)
+}
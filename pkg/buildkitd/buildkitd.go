@@ -31,6 +31,7 @@ import (
 
 	"github.com/tensorchord/envd/pkg/docker"
 	"github.com/tensorchord/envd/pkg/flag"
+	"github.com/tensorchord/envd/pkg/home"
 	"github.com/tensorchord/envd/pkg/types"
 )
 
@@ -62,6 +63,8 @@ type generalClient struct {
 
 	driver types.BuilderType
 	socket string
+	// runner runs the buildkitd container of the docker-container driver.
+	runner types.RunnerType
 
 	*client.Client
 	logger *logrus.Entry
@@ -76,11 +79,13 @@ func NewClient(ctx context.Context, driver types.BuilderType,
 	}
 	c.socket = socket
 	c.driver = driver
+	c.runner = currentRunner()
 	c.logger = logrus.WithFields(logrus.Fields{
 		"container": c.containerName,
 		"image":     c.image,
 		"socket":    c.socket,
 		"driver":    c.driver,
+		"runner":    c.runner,
 	})
 
 	cli, err := client.New(ctx, c.BuildkitdAddr(), client.WithFailFast())
@@ -239,5 +244,22 @@ func (c generalClient) Prune(ctx context.Context, keepDuration time.Duration,
 }
 
 func (c generalClient) BuildkitdAddr() string {
+	// The container is created by docker.NewClient in the runner of the
+	// context, thus it is only reachable by the podman CLI on podman.
+	if c.driver == types.BuilderTypeDocker && c.runner == types.RunnerTypePodman {
+		return fmt.Sprintf("podman-container://%s", c.socket)
+	}
 	return fmt.Sprintf("%s://%s", c.driver, c.socket)
 }
+
+// currentRunner returns the runner of the current context, it defaults to
+// docker.
+func currentRunner() types.RunnerType {
+	if m := home.GetManager(); m != nil {
+		if c, err := m.ContextGetCurrent(); err == nil && !c.RunsOnKubernetes() &&
+			c.Runner != "" {
+			return c.Runner
+		}
+	}
+	return types.RunnerTypeDocker
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildkitd
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tensorchord/envd/pkg/home"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+var _ = Describe("buildkitd address", func() {
+	It("should use the docker container by default", func() {
+		c := generalClient{driver: types.BuilderTypeDocker, socket: "envd_buildkitd",
+			runner: types.RunnerTypeDocker}
+		Expect(c.BuildkitdAddr()).To(Equal("docker-container://envd_buildkitd"))
+		c = generalClient{driver: types.BuilderTypeTCP, socket: "0.0.0.0:8888",
+			runner: types.RunnerTypePodman}
+		Expect(c.BuildkitdAddr()).To(Equal("tcp://0.0.0.0:8888"))
+	})
+
+	Describe("podman context", Ordered, func() {
+		testContext := "envd_buildkitd_podman_test"
+
+		BeforeAll(func() {
+			Expect(home.Initialize()).To(Succeed())
+			Expect(home.GetManager().ContextCreate(types.Context{
+				Name:          testContext,
+				Builder:       types.BuilderTypeDocker,
+				BuilderSocket: "envd_buildkitd",
+				Runner:        types.RunnerTypePodman,
+			}, true)).To(Succeed())
+		})
+
+		It("should connect to the podman container", func() {
+			c := generalClient{driver: types.BuilderTypeDocker, socket: "envd_buildkitd",
+				runner: currentRunner()}
+			Expect(c.BuildkitdAddr()).To(Equal("podman-container://envd_buildkitd"))
+		})
+
+		AfterAll(func() {
+			Expect(home.GetManager().ContextUse("default")).To(Succeed())
+			Expect(home.GetManager().ContextRemove(testContext)).To(Succeed())
+		})
+	})
+})
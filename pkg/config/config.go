@@ -16,9 +16,24 @@ package config
 type UpState string
 
 const (
-	PrivateKeyFile               = "id_rsa_envd"
-	PublicKeyFile                = "id_rsa_envd.pub"
-	ContainerAuthorizedKeysPath  = "/var/envd/authorized_keys"
+	PrivateKeyFile              = "id_rsa_envd"
+	PublicKeyFile               = "id_rsa_envd.pub"
+	ContainerAuthorizedKeysPath = "/var/envd/authorized_keys"
+	// ContainerEnvdSSHPath is the envd-ssh binary in the container.
+	ContainerEnvdSSHPath = "/var/envd/bin/envd-ssh"
+	// ContainerSupervisorStatusPath is the status of the processes supervised by envd-ssh.
+	ContainerSupervisorStatusPath = "/var/envd/supervisor-status.json"
+	// ContainerSupervisorSocketPath serves the status of the supervised processes.
+	ContainerSupervisorSocketPath = "/var/envd/supervisor.sock"
+	// ContainerLogDir is the directory of the logs of the supervised processes.
+	ContainerLogDir = "/var/envd/log"
+	// ContainerAuditLogPath is the audit log of the ssh sessions.
+	ContainerAuditLogPath = "/var/envd/audit/sessions.log"
+	// ContainerAuditRecordingDir is the directory of the recordings of the PTY sessions.
+	ContainerAuditRecordingDir = "/var/envd/audit/recordings"
+	// EnvAuditLog and EnvAuditRecordingDir enable the audit log in envd-ssh.
+	EnvAuditLog                  = "ENVD_AUDIT_LOG"
+	EnvAuditRecordingDir         = "ENVD_AUDIT_RECORDINGS"
 	SSHPortInContainer           = 2222
 	JupyterPortInContainer       = 8888
 	RStudioServerPortInContainer = 8787
@@ -0,0 +1,57 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/tensorchord/envd/pkg/home"
+)
+
+// cacheRoot returns root if it is set, or the envd cache directory.
+func cacheRoot(root string) string {
+	if root != "" {
+		return root
+	}
+	return home.GetManager().CacheDir()
+}
+
+// sourcesDir is the dir of the content-addressed data sources in the data
+// dir. Dataset names cannot start with '.', so it never clashes with the
+// datasets of data.envd.
+const sourcesDir = ".sources"
+
+// contentDir returns the content-addressed directory of a data source,
+// <cache>/data/.sources/<kind>/<sha256 of key>.
+func contentDir(root, kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cacheRoot(root), "data", sourcesDir, kind, hex.EncodeToString(sum[:]))
+}
+
+// withinDir joins name to dir and rejects names escaping dir.
+func withinDir(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if p != filepath.Clean(dir) &&
+		!strings.HasPrefix(p, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return "", errors.Newf("%s: illegal file path", name)
+	}
+	return p, nil
+}
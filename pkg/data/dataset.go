@@ -0,0 +1,272 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/pkg/archive"
+
+	"github.com/tensorchord/envd/pkg/types"
+	"github.com/tensorchord/envd/pkg/util/ziputil"
+)
+
+// contentKinds are the kinds of the content-addressed data dirs in
+// <cache>/data/.sources, their datasets are named <kind>/<hash>.
+var contentKinds = []string{"http", "s3"}
+
+func isContentKind(name string) bool {
+	for _, k := range contentKinds {
+		if name == k {
+			return true
+		}
+	}
+	return false
+}
+
+// ListDatasets returns the datasets in the envd cache dir.
+func ListDatasets() ([]types.Dataset, error) {
+	return listDatasets("")
+}
+
+// GetDataset returns the dataset with the given name.
+func GetDataset(name string) (types.Dataset, error) {
+	return getDataset("", name)
+}
+
+// RemoveDataset removes the dataset from the envd cache dir.
+func RemoveDataset(name string) error {
+	return removeDataset("", name)
+}
+
+// ImportDataset copies a local dir, a tarball or a zip archive into a new
+// dataset.
+func ImportDataset(name, src string) error {
+	return importDataset("", name, src)
+}
+
+// ExportDataset writes the dataset to a tarball, it is gzipped unless the
+// path ends with .tar.
+func ExportDataset(name, dst string) error {
+	return exportDataset("", name, dst)
+}
+
+func listDatasets(root string) ([]types.Dataset, error) {
+	dataDir := filepath.Join(cacheRoot(root), "data")
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read the data dir")
+	}
+
+	var names []string
+	for _, kind := range contentKinds {
+		children, err := os.ReadDir(filepath.Join(dataDir, sourcesDir, kind))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrap(err, "failed to read the data dir")
+		}
+		for _, c := range children {
+			if c.IsDir() && !strings.HasPrefix(c.Name(), ".") {
+				names = append(names, kind+"/"+c.Name())
+			}
+		}
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+
+	datasets := make([]types.Dataset, 0, len(names))
+	for _, name := range names {
+		d, err := getDataset(root, name)
+		if err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, d)
+	}
+	return datasets, nil
+}
+
+func getDataset(root, name string) (types.Dataset, error) {
+	dir, err := datasetDir(root, name)
+	if err != nil {
+		return types.Dataset{}, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return types.Dataset{}, errors.Newf("dataset %s does not exist", name)
+		}
+		return types.Dataset{}, errors.Wrapf(err, "failed to stat dataset %s", name)
+	}
+	d := types.Dataset{Name: name, Path: dir, LastUsed: info.ModTime()}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(d.LastUsed) {
+			d.LastUsed = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			d.Size += info.Size()
+			d.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return types.Dataset{}, errors.Wrapf(err, "failed to walk dataset %s", name)
+	}
+	return d, nil
+}
+
+func removeDataset(root, name string) error {
+	dir, err := datasetDir(root, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return errors.Newf("dataset %s does not exist", name)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrapf(err, "failed to remove dataset %s", name)
+	}
+	// The sync index of the s3 data source.
+	if err := os.Remove(dir + ".json"); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove dataset %s", name)
+	}
+	return nil
+}
+
+func importDataset(root, name, src string) error {
+	if strings.Contains(name, "/") {
+		return errors.Newf("invalid dataset name %s", name)
+	}
+	dir, err := datasetDir(root, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return errors.Newf("dataset %s already exists", name)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", src)
+	}
+
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	tmp, err := os.MkdirTemp(parent, ".import-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	defer os.RemoveAll(tmp)
+
+	if info.IsDir() {
+		rc, err := archive.TarWithOptions(src, &archive.TarOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", src)
+		}
+		defer rc.Close()
+		if err := archive.Untar(rc, tmp, &archive.TarOptions{NoLchown: true}); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", src)
+		}
+	} else if strings.HasSuffix(strings.ToLower(src), ".zip") {
+		if _, err := ziputil.Unzip(src, tmp); err != nil {
+			return errors.Wrapf(err, "failed to unzip %s", src)
+		}
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %s", src)
+		}
+		defer f.Close()
+		// Untar detects the compression itself.
+		if err := archive.Untar(f, tmp, &archive.TarOptions{NoLchown: true}); err != nil {
+			return errors.Wrapf(err, "failed to extract %s", src)
+		}
+	}
+
+	if err := os.Chmod(tmp, 0777); err != nil { // Avoid UID/GID issues
+		return errors.Wrap(err, "failed to change data dir mode")
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	return nil
+}
+
+func exportDataset(root, name, dst string) error {
+	d, err := getDataset(root, name)
+	if err != nil {
+		return err
+	}
+	compression := archive.Gzip
+	if strings.HasSuffix(strings.ToLower(dst), ".tar") {
+		compression = archive.Uncompressed
+	}
+	rc, err := archive.TarWithOptions(d.Path, &archive.TarOptions{Compression: compression})
+	if err != nil {
+		return errors.Wrapf(err, "failed to archive dataset %s", name)
+	}
+	defer rc.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", dst)
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return errors.Wrapf(err, "failed to export dataset %s", name)
+	}
+	return f.Close()
+}
+
+// datasetDir returns the dir of the dataset, names are either <name> for
+// data.envd or <kind>/<hash> for the content-addressed data sources.
+func datasetDir(root, name string) (string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) > 2 || (len(parts) == 2 && !isContentKind(parts[0])) {
+		return "", errors.Newf("invalid dataset name %s", name)
+	}
+	for _, p := range parts {
+		if !validDatasetName(p) {
+			return "", errors.Newf("invalid dataset name %s", name)
+		}
+	}
+	if len(parts) == 2 {
+		return filepath.Join(cacheRoot(root), "data", sourcesDir, parts[0], parts[1]), nil
+	}
+	return filepath.Join(cacheRoot(root), "data", name), nil
+}
+
+// validDatasetName returns true if name is a valid data.envd dataset name.
+// Names starting with '.' are reserved for the data dir itself.
+func validDatasetName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsAny(name, "/"+string(filepath.Separator))
+}
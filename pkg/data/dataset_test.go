@@ -0,0 +1,78 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("datasets", func() {
+	var root, src string
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		src = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(src, "train"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "train", "a.txt"), []byte("aa"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "b.txt"), []byte("b"), 0644)).To(Succeed())
+	})
+
+	It("should import, list, export and remove datasets", func() {
+		Expect(importDataset(root, "mnist", src)).To(Succeed())
+		Expect(importDataset(root, "mnist", src)).To(MatchError(ContainSubstring("already exists")))
+
+		// The datasets of the content-addressed data sources are listed too.
+		Expect(os.MkdirAll(filepath.Join(root, "data", ".sources", "http", "0123"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(root, "data", ".sources", "http", ".download-1"), 0755)).To(Succeed())
+		// data.envd("http") does not clash with them.
+		Expect(importDataset(root, "http", src)).To(Succeed())
+
+		datasets, err := listDatasets(root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(datasets).To(HaveLen(3))
+		Expect(datasets[0].Name).To(Equal("http/0123"))
+		Expect(datasets[1].Name).To(Equal("http"))
+		Expect(datasets[1].Files).To(Equal(2))
+		Expect(datasets[2].Name).To(Equal("mnist"))
+		Expect(datasets[2].Size).To(Equal(int64(3)))
+		Expect(datasets[2].Files).To(Equal(2))
+
+		tarball := filepath.Join(GinkgoT().TempDir(), "mnist.tar.gz")
+		Expect(exportDataset(root, "mnist", tarball)).To(Succeed())
+		Expect(importDataset(root, "copy", tarball)).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(root, "data", "copy", "train", "a.txt"))).To(Equal([]byte("aa")))
+
+		Expect(removeDataset(root, "mnist")).To(Succeed())
+		Expect(removeDataset(root, "http/0123")).To(Succeed())
+		Expect(removeDataset(root, "http")).To(Succeed())
+		Expect(removeDataset(root, "mnist")).To(MatchError(ContainSubstring("does not exist")))
+		datasets, err = listDatasets(root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(datasets).To(HaveLen(1))
+		Expect(datasets[0].Name).To(Equal("copy"))
+	})
+
+	It("should reject invalid names", func() {
+		for _, name := range []string{"", "..", "a/b", "http/..", ".hidden"} {
+			_, err := datasetDir(root, name)
+			Expect(err).To(HaveOccurred(), name)
+		}
+		Expect(importDataset(root, "http/0123", src)).NotTo(Succeed())
+	})
+})
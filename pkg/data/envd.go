@@ -17,6 +17,8 @@ package data
 import (
 	"fmt"
 
+	"github.com/cockroachdb/errors"
+
 	"github.com/tensorchord/envd/pkg/home"
 
 	"go.starlark.net/starlark"
@@ -28,6 +30,9 @@ type EnvdManagedDataSource struct {
 }
 
 func (e *EnvdManagedDataSource) Init() error {
+	if !validDatasetName(e.name) {
+		return errors.Newf("invalid dataset name %s", e.name)
+	}
 	manager := home.GetManager()
 	hostDataDir, err := manager.InitDataDir(e.name)
 	if err != nil {
@@ -0,0 +1,191 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+	"github.com/tensorchord/envd/pkg/util/ziputil"
+)
+
+var tarSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"}
+
+// HTTPDataSource downloads a file or an archive over HTTP. Archives are
+// extracted, other files are placed in the directory as is.
+type HTTPDataSource struct {
+	url         string
+	sha256      string
+	root        string
+	hostDataDir string
+}
+
+func (h *HTTPDataSource) Init() error {
+	name, err := h.fileName()
+	if err != nil {
+		return err
+	}
+	key := h.url
+	if h.sha256 != "" {
+		key = "sha256:" + strings.ToLower(h.sha256)
+	}
+	dir := contentDir(h.root, "http", key)
+	exists, err := fileutil.DirExists(dir)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logrus.Debugf("data source %s is cached in %s", h.url, dir)
+		h.hostDataDir = dir
+		return nil
+	}
+
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	// Download into a temporary dir next to the final one, and rename it
+	// once it is complete so that a failed download is never reused.
+	tmp, err := os.MkdirTemp(parent, ".download-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	defer os.RemoveAll(tmp)
+
+	logrus.Infof("downloading data source %s", h.url)
+	file, err := h.download(parent)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	if err := extract(name, file, tmp); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0777); err != nil { // Avoid UID/GID issues
+		return errors.Wrap(err, "failed to change data dir mode")
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	h.hostDataDir = dir
+	return nil
+}
+
+// download fetches the url into a temporary file in dir and verifies its
+// checksum.
+func (h *HTTPDataSource) download(dir string) (string, error) {
+	resp, err := http.Get(h.url)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", h.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Newf("failed to download %s: %s", h.url, resp.Status)
+	}
+
+	f, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp file")
+	}
+	defer f.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, digest), resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrapf(err, "failed to download %s", h.url)
+	}
+	if h.sha256 != "" {
+		actual := hex.EncodeToString(digest.Sum(nil))
+		if !strings.EqualFold(actual, h.sha256) {
+			os.Remove(f.Name())
+			return "", errors.Newf("sha256 mismatch for %s: expected %s, got %s",
+				h.url, h.sha256, actual)
+		}
+	}
+	return f.Name(), nil
+}
+
+// extract unpacks the downloaded file according to its name into dir.
+func extract(name, file, dir string) error {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".zip") {
+		if _, err := ziputil.Unzip(file, dir); err != nil {
+			return errors.Wrapf(err, "failed to unzip %s", name)
+		}
+		return nil
+	}
+	for _, suffix := range tarSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			f, err := os.Open(file)
+			if err != nil {
+				return errors.Wrapf(err, "failed to open %s", name)
+			}
+			defer f.Close()
+			// Untar detects the compression itself.
+			if err := archive.Untar(f, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+				return errors.Wrapf(err, "failed to extract %s", name)
+			}
+			return nil
+		}
+	}
+	target, err := withinDir(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(file, target); err != nil {
+		return errors.Wrapf(err, "failed to move %s", name)
+	}
+	return os.Chmod(target, 0644)
+}
+
+func (h *HTTPDataSource) fileName() (string, error) {
+	u, err := url.Parse(h.url)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse url %s", h.url)
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", errors.Newf("failed to get the file name of %s", h.url)
+	}
+	return name, nil
+}
+
+func (h *HTTPDataSource) GetHostDir() (string, error) {
+	return h.hostDataDir, nil
+}
+
+func (h *HTTPDataSource) Type() string {
+	return "http data source"
+}
+
+func (h *HTTPDataSource) Hash() (uint32, error) {
+	return starlark.String(h.url).Hash()
+}
+
+func NewHTTPDataSource(url, sha256 string) *HTTPDataSource {
+	return &HTTPDataSource{url: url, sha256: sha256}
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"go.starlark.net/starlark"
+
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+// LocalDataSource mounts an existing directory on the host.
+type LocalDataSource struct {
+	path string
+	// buildContextDir is the dir of the relative paths.
+	buildContextDir string
+	hostDataDir     string
+}
+
+func (l *LocalDataSource) Init() error {
+	path := l.path
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return errors.Wrap(err, "failed to get the home dir")
+		}
+		path = filepath.Join(dir, strings.TrimPrefix(path, "~"))
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(l.buildContextDir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the absolute path of %s", l.path)
+	}
+	exists, err := fileutil.DirExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", path)
+	}
+	if !exists {
+		return errors.Newf("local data source %s is not a directory", l.path)
+	}
+	l.hostDataDir = path
+	return nil
+}
+
+func (l *LocalDataSource) GetHostDir() (string, error) {
+	return l.hostDataDir, nil
+}
+
+func (l *LocalDataSource) Type() string {
+	return "local data source"
+}
+
+func (l *LocalDataSource) Hash() (uint32, error) {
+	return starlark.String("file://" + l.path).Hash()
+}
+
+// NewLocalDataSource returns the data source of the directory, relative
+// paths are resolved against the build context dir.
+func NewLocalDataSource(buildContextDir, path string) *LocalDataSource {
+	return &LocalDataSource{path: path, buildContextDir: buildContextDir}
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+)
+
+// defaultS3Endpoint returns the regional endpoint of AWS S3. The requests
+// are signed with the region, thus the global endpoint only works for the
+// buckets in us-east-1.
+func defaultS3Endpoint(region string) string {
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+}
+
+// S3DataSource syncs the objects under a prefix of an S3-compatible bucket.
+// Credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables, the bucket is accessed
+// anonymously if they are not set.
+type S3DataSource struct {
+	bucket      string
+	prefix      string
+	endpoint    string
+	root        string
+	hostDataDir string
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int64  `xml:"Size"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *S3DataSource) Init() error {
+	if s.bucket == "" {
+		return errors.New("s3 data source requires a bucket")
+	}
+	dir := contentDir(s.root, "s3", s.uri())
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return errors.Wrap(err, "failed to create data dir")
+	}
+	if err := os.Chmod(dir, 0777); err != nil { // Avoid UID/GID issues
+		return errors.Wrap(err, "failed to change data dir mode")
+	}
+
+	client := newS3Client(s.endpoint)
+	objects, err := client.list(s.bucket, s.prefix)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list %s", s.uri())
+	}
+
+	// The index records the ETag of every synced object, it is kept next
+	// to the dir so that it is not visible in the container.
+	indexPath := dir + ".json"
+	index := map[string]string{}
+	if data, err := os.ReadFile(indexPath); err == nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			logrus.Debugf("ignoring invalid index %s: %v", indexPath, err)
+			index = map[string]string{}
+		}
+	}
+
+	logrus.Infof("syncing data source %s", s.uri())
+	remote := map[string]bool{}
+	for _, obj := range objects {
+		name := strings.TrimPrefix(strings.TrimPrefix(obj.Key, s.prefix), "/")
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		target, err := withinDir(dir, name)
+		if err != nil {
+			return err
+		}
+		remote[name] = true
+		if _, err := os.Stat(target); err == nil && index[name] == obj.ETag {
+			continue
+		}
+		logrus.Debugf("downloading s3 object %s", obj.Key)
+		if err := client.download(s.bucket, obj.Key, target); err != nil {
+			return err
+		}
+		index[name] = obj.ETag
+	}
+	for name := range index {
+		if remote[name] {
+			continue
+		}
+		target, err := withinDir(dir, name)
+		if err != nil {
+			return err
+		}
+		logrus.Debugf("removing deleted s3 object %s", name)
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %s", target)
+		}
+		delete(index, name)
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the index")
+	}
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write the index")
+	}
+	s.hostDataDir = dir
+	return nil
+}
+
+func (s *S3DataSource) uri() string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.endpointURL(), "/"), s.bucket, s.prefix)
+}
+
+func (s *S3DataSource) endpointURL() string {
+	if s.endpoint == "" {
+		return defaultS3Endpoint(regionFromEnv())
+	}
+	return s.endpoint
+}
+
+func (s *S3DataSource) GetHostDir() (string, error) {
+	return s.hostDataDir, nil
+}
+
+func (s *S3DataSource) Type() string {
+	return "s3 data source"
+}
+
+func (s *S3DataSource) Hash() (uint32, error) {
+	return starlark.String(s.uri()).Hash()
+}
+
+func NewS3DataSource(bucket, prefix, endpoint string) *S3DataSource {
+	return &S3DataSource{bucket: bucket, prefix: prefix, endpoint: endpoint}
+}
+
+type s3Client struct {
+	endpoint    string
+	credentials s3Credentials
+	client      *http.Client
+}
+
+func newS3Client(endpoint string) *s3Client {
+	credentials := credentialsFromEnv()
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint(credentials.region)
+	} else if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return &s3Client{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		credentials: credentials,
+		client:      http.DefaultClient,
+	}
+}
+
+// list returns all the objects under the prefix with ListObjectsV2.
+func (c *s3Client) list(bucket, prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2"}
+		if prefix != "" {
+			query["prefix"] = prefix
+		}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		resp, err := c.do(bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode the object list")
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (c *s3Client) download(bucket, key, target string) error {
+	resp, err := c.do(bucket, key, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s", key)
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return errors.Wrap(err, "failed to create dir")
+	}
+	tmp := target + ".download"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", tmp)
+	}
+	_, err = io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to download %s", key)
+	}
+	return os.Rename(tmp, target)
+}
+
+// do sends a signed GET request for the path-style url of the bucket and key.
+func (c *s3Client) do(bucket, key string, query map[string]string) (*http.Response, error) {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse endpoint %s", c.endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = awsEscapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	c.credentials.sign(req, time.Now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var e s3Error
+		if err := xml.NewDecoder(resp.Body).Decode(&e); err != nil || e.Code == "" {
+			return nil, errors.Newf("s3 request failed: %s", resp.Status)
+		}
+		return nil, errors.Newf("s3 request failed: %s: %s", e.Code, e.Message)
+	}
+	return resp, nil
+}
@@ -15,6 +15,7 @@
 package docker
 
 import (
+	"archive/tar"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -32,12 +33,15 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	"github.com/moby/term"
 	"github.com/sirupsen/logrus"
 
 	envdconfig "github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/home"
 	"github.com/tensorchord/envd/pkg/lang/ir"
+	envdtypes "github.com/tensorchord/envd/pkg/types"
 	"github.com/tensorchord/envd/pkg/util/netutil"
 )
 
@@ -65,9 +69,24 @@ type Client interface {
 	WaitUntilRunning(ctx context.Context, name string, timeout time.Duration) error
 
 	Exec(ctx context.Context, cname string, cmd []string) error
+	// ExecOutput runs the command in the container and copies the output
+	// until the command exits or the context is canceled.
+	ExecOutput(ctx context.Context, cname string, cmd []string, stdout, stderr io.Writer) error
+	// ExecOutputAs runs the command as the user, e.g. root, like ExecOutput.
+	ExecOutputAs(ctx context.Context, cname, user string, cmd []string, stdout, stderr io.Writer) error
+	// ReadFile reads the file in the container.
+	ReadFile(ctx context.Context, cname, path string) ([]byte, error)
+	// Logs copies the logs of the container.
+	Logs(ctx context.Context, cname string, opt types.ContainerLogsOptions, stdout, stderr io.Writer) error
+	// RunCommand runs the command in a temporary container of the image
+	// and returns the stdout.
+	RunCommand(ctx context.Context, image string, cmd []string) (string, error)
 	Destroy(ctx context.Context, name string) (string, error)
 
 	ListContainer(ctx context.Context) ([]types.Container, error)
+	// ListCopyOnWriteSources returns the lower dirs of the copy-on-write
+	// volumes by the volume names.
+	ListCopyOnWriteSources(ctx context.Context) (map[string]string, error)
 	GetContainer(ctx context.Context, cname string) (types.ContainerJSON, error)
 	PauseContainer(ctx context.Context, name string) (string, error)
 	ResumeContainer(ctx context.Context, name string) (string, error)
@@ -86,10 +105,46 @@ type Client interface {
 
 type generalClient struct {
 	*client.Client
+	runner envdtypes.RunnerType
 }
 
+// NewClient creates the client of the runner in the current context.
 func NewClient(ctx context.Context) (Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	runner, address := envdtypes.RunnerTypeDocker, ""
+	if m := home.GetManager(); m != nil {
+		// The images of the kube-pod context are still managed by the
+		// local docker daemon.
+		if c, err := m.ContextGetCurrent(); err == nil && !c.RunsOnKubernetes() {
+			runner, address = c.Runner, c.RunnerAddress
+		}
+	}
+	return NewClientWithRunner(ctx, runner, address)
+}
+
+// NewClientWithRunner creates the client of the given runner. Podman is
+// supported by its Docker-compatible API.
+func NewClientWithRunner(ctx context.Context,
+	runner envdtypes.RunnerType, address string) (Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	switch runner {
+	case envdtypes.RunnerTypeDocker, "":
+		runner = envdtypes.RunnerTypeDocker
+	case envdtypes.RunnerTypePodman:
+		if address == "" {
+			address = podmanDefaultAddress()
+		}
+	default:
+		return nil, errors.Newf("unknown runner type %s", runner)
+	}
+	if address != "" {
+		opts = append(opts, client.WithHost(address))
+	}
+	logrus.WithFields(logrus.Fields{
+		"runner":  runner,
+		"address": address,
+	}).Debug("creating the runner client")
+
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -99,10 +154,14 @@ func NewClient(ctx context.Context) (Client, error) {
 		if strings.Contains(err.Error(), "permission denied") {
 			err = errors.New(`It seems that current user have no access to docker daemon,
 please visit https://docs.docker.com/engine/install/linux-postinstall/ for more info.`)
+		}
+		if runner == envdtypes.RunnerTypePodman {
+			err = errors.Wrapf(err, `failed to connect to podman at %s,
+please make sure the podman socket is running (e.g. systemctl --user start podman.socket)`, address)
 		}
 		return nil, err
 	}
-	return generalClient{cli}, nil
+	return generalClient{cli, runner}, nil
 }
 
 // Normalize the name accord the spec of docker, It may support normalize imagea and container in the future.
@@ -136,6 +195,10 @@ func (c generalClient) GPUEnabled(ctx context.Context) (bool, error) {
 		return false, errors.Wrap(err, "failed to get docker info")
 	}
 	logrus.WithField("info", info).Debug("docker info")
+	if c.runner == envdtypes.RunnerTypePodman {
+		// Podman exposes the GPUs by the container device interface.
+		return cdiGPUEnabled()
+	}
 	nv := info.Runtimes["nvidia"]
 	return nv.Path != "", nil
 }
@@ -291,6 +354,9 @@ func (c generalClient) Destroy(ctx context.Context, name string) (string, error)
 	if err := c.ContainerRemove(ctx, name, types.ContainerRemoveOptions{}); err != nil {
 		return "", errors.Wrap(err, "failed to remove the container")
 	}
+	if err := c.removeCopyOnWriteVolumes(ctx, name); err != nil {
+		return "", err
+	}
 	return name, nil
 }
 
@@ -407,38 +473,58 @@ func (c generalClient) StartEnvd(ctx context.Context, tag, name, buildContext st
 		Image:        tag,
 		User:         "envd",
 		ExposedPorts: nat.PortSet{},
+		// The environment variables are merged with the ones in the image.
+		Env: g.EnvString(),
+	}
+	if len(g.Users) != 0 {
+		// envd-ssh runs the sessions as the users only if it is root, and
+		// the other processes still run as envd.
+		config.User = "root"
 	}
 	base := filepath.Base(buildContext)
 	base = filepath.Join("/home/envd", base)
 	config.WorkingDir = base
 
-	mountOption := make([]mount.Mount, 0, len(mountOptionsStr)+len(g.Mount)+1)
+	mounts := make([]ir.MountInfo, 0, len(mountOptionsStr)+len(g.Mount))
+	copyOnWrite := false
 	for _, option := range mountOptionsStr {
-		mStr := strings.Split(option, ":")
-		if len(mStr) != 2 {
-			return "", "", errors.Newf("Invalid mount options %s", option)
+		m, err := ir.ParseVolume(option)
+		if err != nil {
+			return "", "", err
 		}
-
-		logger.WithFields(logrus.Fields{
-			"mount-path":     mStr[0],
-			"container-path": mStr[1],
-		}).Debug("setting up container working directory")
-		mountOption = append(mountOption, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: mStr[0],
-			Target: mStr[1],
-		})
+		mounts = append(mounts, m)
+		copyOnWrite = copyOnWrite || m.CopyOnWrite
 	}
 	for _, m := range g.Mount {
+		if err := m.InitDataSource(); err != nil {
+			return "", "", errors.Wrapf(err, "failed to fetch the data of mount %s", m.Destination)
+		}
+		mounts = append(mounts, m)
+		copyOnWrite = copyOnWrite || m.CopyOnWrite
+	}
+	if copyOnWrite {
+		info, err := c.GetInfo(ctx)
+		if err != nil {
+			return "", "", errors.Wrap(err, "failed to get the runner info")
+		}
+		if err := checkCopyOnWrite(c.runner, info); err != nil {
+			return "", "", err
+		}
+	}
+
+	mountOption := make([]mount.Mount, 0, len(mounts)+1)
+	for _, m := range mounts {
 		logger.WithFields(logrus.Fields{
 			"mount-path":     m.Source,
 			"container-path": m.Destination,
+			"read-only":      m.ReadOnly,
+			"copy-on-write":  m.CopyOnWrite,
 		}).Debug("setting up declared mount directory")
-		mountOption = append(mountOption, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: m.Source,
-			Target: m.Destination,
-		})
+		mo, err := containerMount(name, m)
+		if err != nil {
+			return "", "", err
+		}
+		mountOption = append(mountOption, mo)
 	}
 
 	mountOption = append(mountOption, mount.Mount{
@@ -530,7 +616,11 @@ func (c generalClient) StartEnvd(ctx context.Context, tag, name, buildContext st
 
 	if gpuEnabled {
 		logger.Debug("GPU is enabled.")
-		hostConfig.DeviceRequests = deviceRequests(numGPUs)
+		if c.runner == envdtypes.RunnerTypePodman {
+			hostConfig.Devices = cdiDevices(numGPUs)
+		} else {
+			hostConfig.DeviceRequests = deviceRequests(numGPUs)
+		}
 	}
 
 	config.Labels = labels(name, g,
@@ -555,7 +645,8 @@ func (c generalClient) StartEnvd(ctx context.Context, tag, name, buildContext st
 		ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
 		errCause := errors.UnwrapAll(err)
 		// Hack to check if the port is already allocated.
-		if strings.Contains(errCause.Error(), "port is already allocated") {
+		if strings.Contains(errCause.Error(), "port is already allocated") ||
+			strings.Contains(errCause.Error(), "address already in use") {
 			logrus.Debugf("failed to allocate the port: %s", err)
 			return "", "", errors.New("port is already allocated in the host")
 		}
@@ -611,6 +702,7 @@ func (c generalClient) Load(ctx context.Context, r io.ReadCloser, quiet bool) er
 
 func (c generalClient) Exec(ctx context.Context, cname string, cmd []string) error {
 	execConfig := types.ExecConfig{
+		User:   "envd",
 		Cmd:    cmd,
 		Detach: true,
 	}
@@ -624,6 +716,139 @@ func (c generalClient) Exec(ctx context.Context, cname string, cmd []string) err
 	})
 }
 
+func (c generalClient) ExecOutput(ctx context.Context,
+	cname string, cmd []string, stdout, stderr io.Writer) error {
+	// The container runs as root if there are collaborators.
+	return c.ExecOutputAs(ctx, cname, "envd", cmd, stdout, stderr)
+}
+
+func (c generalClient) ExecOutputAs(ctx context.Context,
+	cname, user string, cmd []string, stdout, stderr io.Writer) error {
+	resp, err := c.ContainerExecCreate(ctx, cname, types.ExecConfig{
+		User:         user,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create the exec")
+	}
+	attached, err := c.ContainerExecAttach(ctx, resp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return errors.Wrap(err, "failed to attach the exec")
+	}
+	defer attached.Close()
+	go func() {
+		// Stop copying the output when the context is canceled.
+		<-ctx.Done()
+		attached.Close()
+	}()
+	if _, err := stdcopy.StdCopy(stdout, stderr, attached.Reader); err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "failed to copy the output")
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	inspect, err := c.ContainerExecInspect(ctx, resp.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect the exec")
+	}
+	if inspect.ExitCode != 0 {
+		return errors.Newf("command %v exited with code %d", cmd, inspect.ExitCode)
+	}
+	return nil
+}
+
+func (c generalClient) Logs(ctx context.Context, cname string,
+	opt types.ContainerLogsOptions, stdout, stderr io.Writer) error {
+	opt.ShowStdout, opt.ShowStderr = true, true
+	r, err := c.ContainerLogs(ctx, cname, opt)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the container logs")
+	}
+	defer r.Close()
+	// The container is created without a TTY, thus the logs are multiplexed.
+	if _, err := stdcopy.StdCopy(stdout, stderr, r); err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "failed to copy the container logs")
+	}
+	return nil
+}
+
+func (c generalClient) ReadFile(ctx context.Context, cname, path string) ([]byte, error) {
+	r, _, err := c.CopyFromContainer(ctx, cname, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// The content is a tar archive of the file.
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Newf("%s is not a file", path)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s in the container", path)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+func (c generalClient) RunCommand(ctx context.Context, image string, cmd []string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"image":   image,
+		"command": cmd,
+	})
+	config := &container.Config{
+		Image:      image,
+		User:       "envd",
+		Entrypoint: cmd,
+	}
+	resp, err := c.ContainerCreate(ctx, config, &container.HostConfig{}, nil, nil, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the container")
+	}
+	defer func() {
+		if err := c.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		}); err != nil {
+			logger.WithError(err).Debug("failed to remove the container")
+		}
+	}()
+
+	if err := c.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", errors.Wrap(err, "failed to start the container")
+	}
+	statusC, errC := c.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errC:
+		return "", errors.Wrap(err, "failed to wait for the container")
+	case status := <-statusC:
+		exitCode = status.StatusCode
+	}
+
+	out, err := c.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the container logs")
+	}
+	defer out.Close()
+	var stdout, stderr strings.Builder
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, out); err != nil {
+		return "", errors.Wrap(err, "failed to read the container logs")
+	}
+	logger.WithField("exit-code", exitCode).Debug("command finished")
+	if exitCode != 0 {
+		return "", errors.Newf("command exited with code %d: %s", exitCode, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
 func (c generalClient) Stats(ctx context.Context, cname string, statChan chan<- *Stats, done <-chan bool) (retErr error) {
 	errC := make(chan error, 1)
 	containerStats, err := c.ContainerStats(ctx, cname, true)
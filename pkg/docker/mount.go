@@ -0,0 +1,216 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/home"
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	envdtypes "github.com/tensorchord/envd/pkg/types"
+)
+
+// containerMount converts the mount of the environment name to the docker
+// mount. Copy-on-write mounts are overlay volumes of the local driver, the
+// upper dirs are kept in <cache>/cow/<name> until the environment is
+// destroyed.
+func containerMount(name string, m ir.MountInfo) (mount.Mount, error) {
+	if m.IsVolume() {
+		return mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   m.Source,
+			Target:   m.Destination,
+			ReadOnly: m.ReadOnly,
+		}, nil
+	}
+	// The daemon only accepts absolute paths.
+	source, err := hostPath(m.Source)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	if !m.CopyOnWrite {
+		return mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   source,
+			Target:   m.Destination,
+			ReadOnly: m.ReadOnly,
+		}, nil
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return mount.Mount{}, errors.Wrapf(err, "failed to stat %s", source)
+	}
+	if !info.IsDir() {
+		return mount.Mount{}, errors.Newf("copy-on-write mount %s is not a directory", source)
+	}
+
+	return cowMount(cowDir(name), name, source, info.Mode().Perm(), m)
+}
+
+// hostPath returns the absolute path of the bind mount source, `~` is
+// expanded to the home dir.
+func hostPath(source string) (string, error) {
+	path := source
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get the home dir")
+		}
+		path = filepath.Join(dir, strings.TrimPrefix(path, "~"))
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the absolute path of %s", source)
+	}
+	return path, nil
+}
+
+// checkCopyOnWrite returns an error if the runner cannot mount the overlay
+// of the host dirs. The upper and work dirs are on the host, thus they are
+// invisible to the daemon in the VM of Docker Desktop, and rootless daemons
+// cannot mount the overlay of the local driver.
+func checkCopyOnWrite(runner envdtypes.RunnerType, info types.Info) error {
+	if strings.Contains(info.OperatingSystem, "Docker Desktop") {
+		return errors.New("copy-on-write mounts are not supported by Docker Desktop, use a read-only mount instead")
+	}
+	for _, o := range info.SecurityOptions {
+		if strings.Contains(o, "name=rootless") {
+			return errors.Newf("copy-on-write mounts are not supported by rootless %s, use a read-only mount instead", runner)
+		}
+	}
+	return nil
+}
+
+// cowMount creates the upper dir of the copy-on-write mount in dir and
+// returns the overlay volume.
+func cowMount(dir, name, source string, perm os.FileMode, m ir.MountInfo) (mount.Mount, error) {
+	sum := sha256.Sum256([]byte(source + ":" + m.Destination))
+	id := hex.EncodeToString(sum[:])[:12]
+	dir = filepath.Join(dir, id)
+	upper, work := filepath.Join(dir, "upper"), filepath.Join(dir, "work")
+	for _, p := range []string{source, upper, work} {
+		// They cannot be escaped in the overlay options.
+		if strings.ContainsAny(p, ",:") {
+			return mount.Mount{}, errors.Newf("copy-on-write mount %s cannot contain ',' or ':'", p)
+		}
+	}
+	if err := os.MkdirAll(upper, 0755); err != nil {
+		return mount.Mount{}, errors.Wrap(err, "failed to create the upper dir")
+	}
+	if err := os.MkdirAll(work, 0755); err != nil {
+		return mount.Mount{}, errors.Wrap(err, "failed to create the work dir")
+	}
+	// The root of the overlay has the mode of the upper dir.
+	if err := os.Chmod(upper, perm); err != nil {
+		return mount.Mount{}, errors.Wrap(err, "failed to change the upper dir mode")
+	}
+
+	return mount.Mount{
+		Type:   mount.TypeVolume,
+		Source: fmt.Sprintf("envd-cow-%s-%s", name, id),
+		Target: m.Destination,
+		VolumeOptions: &mount.VolumeOptions{
+			Labels: map[string]string{
+				envdtypes.VolumeLabelCopyOnWrite:       name,
+				envdtypes.VolumeLabelCopyOnWriteSource: source,
+			},
+			DriverConfig: &mount.Driver{
+				Name: "local",
+				Options: map[string]string{
+					"type":   "overlay",
+					"device": "overlay",
+					"o": fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s",
+						source, upper, work),
+				},
+			},
+		},
+	}, nil
+}
+
+// copyOnWriteSource returns the lower dir of the copy-on-write volume.
+// Volumes created without the source label are resolved by their overlay
+// options.
+func copyOnWriteSource(v *types.Volume) string {
+	if source, ok := v.Labels[envdtypes.VolumeLabelCopyOnWriteSource]; ok {
+		return source
+	}
+	for _, o := range strings.Split(v.Options["o"], ",") {
+		if strings.HasPrefix(o, "lowerdir=") {
+			return strings.TrimPrefix(o, "lowerdir=")
+		}
+	}
+	return ""
+}
+
+// ListCopyOnWriteSources returns the lower dirs of the copy-on-write
+// volumes by the volume names.
+func (c generalClient) ListCopyOnWriteSources(ctx context.Context) (map[string]string, error) {
+	f := filters.NewArgs()
+	f.Add("label", envdtypes.VolumeLabelCopyOnWrite)
+	resp, err := c.VolumeList(ctx, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the copy-on-write volumes")
+	}
+	sources := make(map[string]string, len(resp.Volumes))
+	for _, v := range resp.Volumes {
+		if source := copyOnWriteSource(v); source != "" {
+			sources[v.Name] = source
+		}
+	}
+	return sources, nil
+}
+
+func cowDir(name string) string {
+	return filepath.Join(home.GetManager().CacheDir(), "cow", name)
+}
+
+// removeCopyOnWriteVolumes removes the copy-on-write volumes of the
+// environment and their upper dirs.
+func (c generalClient) removeCopyOnWriteVolumes(ctx context.Context, name string) error {
+	f := filters.NewArgs()
+	f.Add("label", fmt.Sprintf("%s=%s", envdtypes.VolumeLabelCopyOnWrite, name))
+	resp, err := c.VolumeList(ctx, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the copy-on-write volumes")
+	}
+	for _, v := range resp.Volumes {
+		logrus.Debugf("removing copy-on-write volume %s", v.Name)
+		if err := c.VolumeRemove(ctx, v.Name, false); err != nil {
+			return errors.Wrapf(err, "failed to remove volume %s", v.Name)
+		}
+	}
+	if len(resp.Volumes) == 0 {
+		return nil
+	}
+	// The work dirs are created by the overlay as root, they may only be
+	// removed by the user if they are empty.
+	if err := os.RemoveAll(cowDir(name)); err != nil {
+		logrus.Warnf("failed to remove the copy-on-write dir %s: %v", cowDir(name), err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+var _ = Describe("mount", func() {
+	It("should convert bind and volume mounts", func() {
+		m, err := containerMount("mnist", ir.MountInfo{
+			Source: "/data", Destination: "/home/envd/data", ReadOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m).To(Equal(mount.Mount{
+			Type: mount.TypeBind, Source: "/data", Target: "/home/envd/data", ReadOnly: true}))
+
+		m, err = containerMount("mnist", ir.MountInfo{
+			Source: "cache", Destination: "/cache", Type: ir.MountTypeVolume})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m).To(Equal(mount.Mount{Type: mount.TypeVolume, Source: "cache", Target: "/cache"}))
+
+		m, err = containerMount("mnist", ir.MountInfo{Source: "./data", Destination: "/data"})
+		Expect(err).NotTo(HaveOccurred())
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Source).To(Equal(filepath.Join(wd, "data")))
+
+		m, err = containerMount("mnist", ir.MountInfo{Source: "~/data", Destination: "/data"})
+		Expect(err).NotTo(HaveOccurred())
+		home, err := os.UserHomeDir()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Source).To(Equal(filepath.Join(home, "data")))
+	})
+
+	It("should reject copy-on-write mounts on Docker Desktop and rootless runners", func() {
+		Expect(checkCopyOnWrite(types.RunnerTypeDocker, dockertypes.Info{
+			OperatingSystem: "Ubuntu 22.04 LTS",
+			SecurityOptions: []string{"name=apparmor", "name=seccomp,profile=default"},
+		})).To(Succeed())
+		Expect(checkCopyOnWrite(types.RunnerTypeDocker, dockertypes.Info{
+			OperatingSystem: "Docker Desktop",
+		})).To(MatchError(ContainSubstring("Docker Desktop")))
+		Expect(checkCopyOnWrite(types.RunnerTypePodman, dockertypes.Info{
+			SecurityOptions: []string{"name=seccomp,profile=default", "name=rootless"},
+		})).To(MatchError(ContainSubstring("rootless podman")))
+	})
+
+	It("should mount an overlay for copy-on-write mounts", func() {
+		dir := GinkgoT().TempDir()
+		m, err := cowMount(dir, "mnist", "/data", 0750, ir.MountInfo{
+			Source: "/data", Destination: "/home/envd/data", CopyOnWrite: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Type).To(Equal(mount.TypeVolume))
+		Expect(m.Source).To(HavePrefix("envd-cow-mnist-"))
+		Expect(m.Target).To(Equal("/home/envd/data"))
+		Expect(m.VolumeOptions.Labels).To(HaveKeyWithValue(types.VolumeLabelCopyOnWrite, "mnist"))
+		Expect(m.VolumeOptions.Labels).To(HaveKeyWithValue(types.VolumeLabelCopyOnWriteSource, "/data"))
+
+		id := m.Source[len("envd-cow-mnist-"):]
+		upper := filepath.Join(dir, id, "upper")
+		Expect(m.VolumeOptions.DriverConfig.Options).To(Equal(map[string]string{
+			"type":   "overlay",
+			"device": "overlay",
+			"o": "lowerdir=/data,upperdir=" + upper +
+				",workdir=" + filepath.Join(dir, id, "work"),
+		}))
+		info, err := os.Stat(upper)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0750)))
+
+		_, err = cowMount(dir, "mnist", "/data,x", 0750, ir.MountInfo{
+			Source: "/data,x", Destination: "/data", CopyOnWrite: true})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should resolve the lower dir of copy-on-write volumes", func() {
+		Expect(copyOnWriteSource(&dockertypes.Volume{Labels: map[string]string{
+			types.VolumeLabelCopyOnWrite:       "mnist",
+			types.VolumeLabelCopyOnWriteSource: "/data",
+		}})).To(Equal("/data"))
+		Expect(copyOnWriteSource(&dockertypes.Volume{
+			Labels:  map[string]string{types.VolumeLabelCopyOnWrite: "mnist"},
+			Options: map[string]string{"o": "lowerdir=/data,upperdir=/upper,workdir=/work"},
+		})).To(Equal("/data"))
+		Expect(copyOnWriteSource(&dockertypes.Volume{})).To(BeEmpty())
+	})
+})
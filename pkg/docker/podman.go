@@ -0,0 +1,97 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cdiGPUKind is the kind of the NVIDIA GPUs in the CDI specs generated
+	// by `nvidia-ctk cdi generate`.
+	cdiGPUKind = "nvidia.com/gpu"
+)
+
+var (
+	// cdiSpecDirs are the directories of the container device interface specs.
+	// Refer to https://github.com/container-orchestrated-devices/container-device-interface
+	cdiSpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+)
+
+// podmanDefaultAddress returns the address of the podman API socket.
+// The rootless podman listens on the socket in XDG_RUNTIME_DIR.
+func podmanDefaultAddress() string {
+	if os.Geteuid() == 0 {
+		return "unix:///run/podman/podman.sock"
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Geteuid())
+	}
+	return "unix://" + filepath.Join(runtimeDir, "podman", "podman.sock")
+}
+
+// cdiGPUEnabled returns true if there is a CDI spec of the NVIDIA GPUs.
+func cdiGPUEnabled() (bool, error) {
+	for _, dir := range cdiSpecDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, err
+		}
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				logrus.Debugf("failed to read the CDI spec %s: %s", entry.Name(), err)
+				continue
+			}
+			if strings.Contains(string(data), cdiGPUKind) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// cdiDevices returns the CDI devices of the GPUs. All the GPUs are used
+// if count is not positive.
+func cdiDevices(count int) []container.DeviceMapping {
+	if count <= 0 {
+		return []container.DeviceMapping{{
+			PathOnHost:        fmt.Sprintf("%s=all", cdiGPUKind),
+			CgroupPermissions: "rwm",
+		}}
+	}
+	devices := make([]container.DeviceMapping, 0, count)
+	for i := 0; i < count; i++ {
+		devices = append(devices, container.DeviceMapping{
+			PathOnHost:        fmt.Sprintf("%s=%d", cdiGPUKind, i),
+			CgroupPermissions: "rwm",
+		})
+	}
+	return devices
+}
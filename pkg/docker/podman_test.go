@@ -0,0 +1,65 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("podman", func() {
+	It("should use the socket in XDG_RUNTIME_DIR for rootless podman", func() {
+		if os.Geteuid() == 0 {
+			Expect(podmanDefaultAddress()).To(Equal("unix:///run/podman/podman.sock"))
+			return
+		}
+		origin := os.Getenv("XDG_RUNTIME_DIR")
+		defer os.Setenv("XDG_RUNTIME_DIR", origin)
+		Expect(os.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")).To(Succeed())
+		Expect(podmanDefaultAddress()).To(Equal("unix:///run/user/1000/podman/podman.sock"))
+	})
+
+	It("should generate the CDI devices", func() {
+		devices := cdiDevices(-1)
+		Expect(devices).To(HaveLen(1))
+		Expect(devices[0].PathOnHost).To(Equal("nvidia.com/gpu=all"))
+
+		devices = cdiDevices(2)
+		Expect(devices).To(HaveLen(2))
+		Expect(devices[1].PathOnHost).To(Equal("nvidia.com/gpu=1"))
+	})
+
+	It("should detect the GPUs by the CDI specs", func() {
+		dir, err := os.MkdirTemp("", "envd-cdi")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		origin := cdiSpecDirs
+		cdiSpecDirs = []string{dir, filepath.Join(dir, "missing")}
+		defer func() { cdiSpecDirs = origin }()
+
+		enabled, err := cdiGPUEnabled()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enabled).To(BeFalse())
+
+		spec := []byte("cdiVersion: 0.5.0\nkind: nvidia.com/gpu\n")
+		Expect(os.WriteFile(filepath.Join(dir, "nvidia.yaml"), spec, 0644)).To(Succeed())
+		enabled, err = cdiGPUEnabled()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enabled).To(BeTrue())
+	})
+})
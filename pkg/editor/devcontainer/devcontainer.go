@@ -0,0 +1,257 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package devcontainer reads and writes the VS Code dev container config.
+// See https://containers.dev/implementors/json_reference/
+package devcontainer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DirName is the directory of the config in the workspace.
+	DirName = ".devcontainer"
+	// FileName is the name of the config.
+	FileName = "devcontainer.json"
+)
+
+// Config is the subset of devcontainer.json used by envd.
+type Config struct {
+	Name            string            `json:"name,omitempty"`
+	Image           string            `json:"image,omitempty"`
+	RemoteUser      string            `json:"remoteUser,omitempty"`
+	WorkspaceFolder string            `json:"workspaceFolder,omitempty"`
+	WorkspaceMount  string            `json:"workspaceMount,omitempty"`
+	OverrideCommand *bool             `json:"overrideCommand,omitempty"`
+	ForwardPorts    []Port            `json:"forwardPorts,omitempty"`
+	Mounts          []Mount           `json:"mounts,omitempty"`
+	ContainerEnv    map[string]string `json:"containerEnv,omitempty"`
+	RemoteEnv       map[string]string `json:"remoteEnv,omitempty"`
+	Customizations  *Customizations   `json:"customizations,omitempty"`
+	// Features are only recorded in the generated manifest as comments.
+	Features map[string]interface{} `json:"features,omitempty"`
+	// PostCreateCommand is a string, a list or a map of commands.
+	PostCreateCommand interface{} `json:"postCreateCommand,omitempty"`
+}
+
+type Customizations struct {
+	VSCode *VSCodeCustomizations `json:"vscode,omitempty"`
+}
+
+type VSCodeCustomizations struct {
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// Port is a forwarded port, either a port number or `host:port`.
+type Port struct {
+	Host string
+	Port int
+}
+
+func (p Port) MarshalJSON() ([]byte, error) {
+	if p.Host == "" {
+		return json.Marshal(p.Port)
+	}
+	return json.Marshal(p.Host + ":" + strconv.Itoa(p.Port))
+}
+
+func (p *Port) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.Port); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Newf("invalid forward port %s", string(data))
+	}
+	host, port, found := strings.Cut(s, ":")
+	if !found {
+		host, port = "", s
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return errors.Newf("invalid forward port %s", s)
+	}
+	p.Host, p.Port = host, n
+	return nil
+}
+
+// Mount is a mount of the container, written as
+// `source=...,target=...,type=bind[,readonly]` in devcontainer.json.
+type Mount struct {
+	Source   string `json:"source,omitempty"`
+	Target   string `json:"target"`
+	Type     string `json:"type"`
+	ReadOnly bool   `json:"readonly,omitempty"`
+}
+
+func (m Mount) String() string {
+	s := "source=" + m.Source + ",target=" + m.Target + ",type=" + m.Type
+	if m.ReadOnly {
+		s += ",readonly"
+	}
+	return s
+}
+
+func (m Mount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Mount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// The object form, e.g. {"source": "a", "target": "b", "type": "bind"}.
+		type mount Mount
+		return json.Unmarshal(data, (*mount)(m))
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch strings.TrimSpace(k) {
+		case "source", "src":
+			m.Source = v
+		case "target", "destination", "dst":
+			m.Target = v
+		case "type":
+			m.Type = v
+		case "readonly", "ro":
+			m.ReadOnly = v == "" || v == "true" || v == "1"
+		}
+	}
+	if m.Target == "" {
+		return errors.Newf("invalid mount %s: target is required", s)
+	}
+	return nil
+}
+
+// Extensions returns the VS Code extensions in the config.
+func (c Config) Extensions() []string {
+	if c.Customizations == nil || c.Customizations.VSCode == nil {
+		return nil
+	}
+	return c.Customizations.VSCode.Extensions
+}
+
+// Path returns the path of devcontainer.json in the workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, DirName, FileName)
+}
+
+// Load reads the config file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	return c, nil
+}
+
+// Parse parses the config, which is JSON with comments and trailing commas.
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(standardize(data), c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Marshal returns the indented config.
+func (c Config) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the dev container config")
+	}
+	return append(data, '\n'), nil
+}
+
+var trailingComma = regexp.MustCompile(`,(\s*[}\]])`)
+
+// standardize removes the comments and trailing commas out of the strings.
+func standardize(data []byte) []byte {
+	var out []byte
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		default:
+			out = append(out, c)
+		}
+	}
+	return removeTrailingCommas(out)
+}
+
+// removeTrailingCommas removes the commas before `}` and `]` which are
+// not in the strings.
+func removeTrailingCommas(data []byte) []byte {
+	var out []byte
+	inString, escaped := false, false
+	start := 0
+	for i, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				out = append(out, data[start:i+1]...)
+				start = i + 1
+			}
+			continue
+		}
+		if c == '"' {
+			out = append(out, trailingComma.ReplaceAll(data[start:i], []byte("$1"))...)
+			start = i
+			inString = true
+		}
+	}
+	return append(out, trailingComma.ReplaceAll(data[start:], []byte("$1"))...)
+}
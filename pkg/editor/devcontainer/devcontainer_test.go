@@ -0,0 +1,89 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devcontainer
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const config = `{
+	// The image of the dev container.
+	"name": "demo",
+	"image": "mcr.microsoft.com/devcontainers/python:3.10", /* python */
+	"forwardPorts": [8000, "db:5432",],
+	"mounts": [
+		"source=${localEnv:HOME}/data,target=/data,type=bind",
+		{"source": "cache", "target": "/cache", "type": "volume"},
+	],
+	"containerEnv": {"URL": "http://localhost//path"},
+	"customizations": {"vscode": {"extensions": ["ms-python.python@2022.8.0"]}},
+	"features": {"ghcr.io/devcontainers/features/node:1": {}},
+	"postCreateCommand": "pip install -r requirements.txt",
+}`
+
+var _ = Describe("devcontainer.json", func() {
+	It("should parse the config with comments and trailing commas", func() {
+		c, err := Parse([]byte(config))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Image).To(Equal("mcr.microsoft.com/devcontainers/python:3.10"))
+		Expect(c.ForwardPorts).To(Equal([]Port{{Port: 8000}, {Host: "db", Port: 5432}}))
+		Expect(c.Mounts).To(Equal([]Mount{
+			{Source: "${localEnv:HOME}/data", Target: "/data", Type: "bind"},
+			{Source: "cache", Target: "/cache", Type: "volume"},
+		}))
+		Expect(c.ContainerEnv).To(HaveKeyWithValue("URL", "http://localhost//path"))
+		Expect(c.Extensions()).To(Equal([]string{"ms-python.python@2022.8.0"}))
+	})
+
+	It("should marshal the ports and mounts in the short form", func() {
+		c := Config{
+			ForwardPorts: []Port{{Port: 8888}},
+			Mounts: []Mount{
+				{Source: "/data", Target: "/data", Type: "bind"},
+				{Source: "/models", Target: "/models", Type: "bind", ReadOnly: true},
+			},
+		}
+		data, err := c.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"source=/data,target=/data,type=bind"`))
+		Expect(string(data)).To(ContainSubstring(`"source=/models,target=/models,type=bind,readonly"`))
+		parsed, err := Parse(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*parsed).To(Equal(c))
+	})
+
+	It("should guess the language from the image", func() {
+		Expect(Language("mcr.microsoft.com/devcontainers/python:3.10")).To(Equal("python3.10"))
+		Expect(Language("rocker/r-ver:4.2")).To(Equal("r"))
+		Expect(Language("julia:1.8")).To(Equal("julia"))
+		Expect(Language("ubuntu:20.04")).To(Equal("python"))
+	})
+
+	It("should generate the manifest", func() {
+		c, err := Parse([]byte(config))
+		Expect(err).NotTo(HaveOccurred())
+		m := Manifest(*c, "")
+		Expect(m).To(ContainSubstring(`base(os="ubuntu20.04", language="python3.10")`))
+		Expect(m).To(ContainSubstring(`"ms-python.python-2022.8.0",`))
+		Expect(m).To(ContainSubstring(`"pip install -r requirements.txt",`))
+		Expect(m).To(ContainSubstring(`runtime.expose(envd_port=8000, host_port=8000)`))
+		Expect(m).To(ContainSubstring(`# skipped the forwarded port db:5432`))
+		Expect(m).To(ContainSubstring(`"URL": "http://localhost//path",`))
+		Expect(m).To(ContainSubstring(`io.mount(src="~/data", dest="/data")`))
+		Expect(m).To(ContainSubstring(`io.mount(src="cache", dest="/cache", type="volume")`))
+		Expect(m).To(ContainSubstring(`#   ghcr.io/devcontainers/features/node:1`))
+	})
+})
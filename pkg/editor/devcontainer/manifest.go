@@ -0,0 +1,172 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devcontainer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var pythonVersion = regexp.MustCompile(`python[^:/]*:(3\.\d+)`)
+
+// Language guesses the envd language from the dev container image.
+func Language(image string) string {
+	image = strings.ToLower(image)
+	switch {
+	case strings.Contains(image, "julia"):
+		return "julia"
+	case strings.Contains(image, "r-base"), strings.Contains(image, "rocker/"),
+		strings.Contains(image, "/r:"):
+		return "r"
+	}
+	if m := pythonVersion.FindStringSubmatch(image); m != nil {
+		return "python" + m[1]
+	}
+	return "python"
+}
+
+// Manifest generates a starter build.envd from the config. The language is
+// guessed from the image if it is empty.
+func Manifest(c Config, language string) string {
+	if language == "" {
+		language = Language(c.Image)
+	}
+
+	var sb strings.Builder
+	line := func(format string, args ...interface{}) {
+		sb.WriteString(fmt.Sprintf(format, args...))
+		sb.WriteString("\n")
+	}
+	line("# Generated from %s by `envd init --from-devcontainer`.", FileName)
+	if c.Image != "" {
+		line("# The dev container image was %s.", c.Image)
+	}
+	if len(c.Features) != 0 {
+		line("# The dev container features are not supported, please install")
+		line("# them with install.system_packages or run:")
+		for _, f := range sortedKeys(c.Features) {
+			line("#   %s", f)
+		}
+	}
+	line("def build():")
+	line("    base(os=\"ubuntu20.04\", language=%s)", strconv.Quote(language))
+
+	if extensions := c.Extensions(); len(extensions) != 0 {
+		line("    install.vscode_extensions([")
+		for _, e := range extensions {
+			// envd uses `publisher.extension-version` for the version.
+			line("        %s,", strconv.Quote(strings.Replace(e, "@", "-", 1)))
+		}
+		line("    ])")
+	}
+
+	if commands := postCreateCommands(c.PostCreateCommand); len(commands) != 0 {
+		line("    # postCreateCommand, it is run when building the image.")
+		line("    run(commands=[")
+		for _, cmd := range commands {
+			line("        %s,", strconv.Quote(cmd))
+		}
+		line("    ])")
+	}
+
+	for _, p := range c.ForwardPorts {
+		if p.Host != "" && p.Host != "localhost" {
+			line("    # skipped the forwarded port %s:%d of another service", p.Host, p.Port)
+			continue
+		}
+		line("    runtime.expose(envd_port=%d, host_port=%d)", p.Port, p.Port)
+	}
+
+	env := map[string]string{}
+	for k, v := range c.ContainerEnv {
+		env[k] = v
+	}
+	for k, v := range c.RemoteEnv {
+		env[k] = v
+	}
+	if len(env) != 0 {
+		keys := []string{}
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		line("    runtime.environ(env={")
+		for _, k := range keys {
+			line("        %s: %s,", strconv.Quote(k), strconv.Quote(env[k]))
+		}
+		line("    })")
+	}
+
+	for _, m := range c.Mounts {
+		opts := ""
+		if m.ReadOnly {
+			opts = ", readonly=True"
+		}
+		if m.Type == "volume" && m.Source != "" {
+			line("    io.mount(src=%s, dest=%s, type=\"volume\"%s)",
+				strconv.Quote(m.Source), strconv.Quote(m.Target), opts)
+			continue
+		}
+		source, ok := hostPath(m.Source)
+		if (m.Type != "bind" && m.Type != "") || !ok {
+			line("    # skipped the %s mount %s", m.Type, m.String())
+			continue
+		}
+		line("    io.mount(src=%s, dest=%s%s)", strconv.Quote(source), strconv.Quote(m.Target), opts)
+	}
+	return sb.String()
+}
+
+// hostPath replaces the home directory variables with `~`. It returns false
+// if the path has other variables, e.g. ${localWorkspaceFolder}.
+func hostPath(path string) (string, bool) {
+	for _, home := range []string{"${localEnv:HOME}", "${localEnv:USERPROFILE}"} {
+		path = strings.Replace(path, home, "~", 1)
+	}
+	return path, path != "" && !strings.Contains(path, "${")
+}
+
+func postCreateCommands(cmd interface{}) []string {
+	switch v := cmd.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		// The command is executed without a shell.
+		args := []string{}
+		for _, arg := range v {
+			args = append(args, fmt.Sprint(arg))
+		}
+		return []string{strings.Join(args, " ")}
+	case map[string]interface{}:
+		commands := []string{}
+		for _, k := range sortedKeys(v) {
+			commands = append(commands, postCreateCommands(v[k])...)
+		}
+		return commands
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/data"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+func (e generalEngine) ListDataset(ctx context.Context) ([]types.Dataset, error) {
+	datasets, err := data.ListDatasets()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list datasets")
+	}
+	ctrs, err := e.dockerCli.ListContainer(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list containers")
+	}
+	// Copy-on-write mounts are overlay volumes, the datasets are their
+	// lower dirs.
+	cowSources, err := e.dockerCli.ListCopyOnWriteSources(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the copy-on-write mounts")
+	}
+
+	for _, ctr := range ctrs {
+		env, err := types.NewEnvironment(ctr)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create env from the container")
+		}
+		running := ctr.State == "running"
+		var startedAt time.Time
+		for _, m := range ctr.Mounts {
+			source, ok := mountSource(m, cowSources)
+			if !ok {
+				continue
+			}
+			for i := range datasets {
+				d := &datasets[i]
+				if !isWithin(d.Path, source) {
+					continue
+				}
+				d.Mounts = append(d.Mounts, types.DatasetMount{
+					Environment: env.Name,
+					Destination: m.Destination,
+					Running:     running,
+				})
+				if running {
+					d.LastUsed = time.Now()
+					continue
+				}
+				if startedAt.IsZero() {
+					startedAt = e.containerStartedAt(ctx, ctr.ID)
+				}
+				if startedAt.After(d.LastUsed) {
+					d.LastUsed = startedAt
+				}
+			}
+		}
+	}
+	return datasets, nil
+}
+
+// containerStartedAt returns the last start time of the container, or the
+// zero time if it cannot be inspected.
+func (e generalEngine) containerStartedAt(ctx context.Context, id string) time.Time {
+	ctr, err := e.dockerCli.GetContainer(ctx, id)
+	if err != nil || ctr.State == nil {
+		logrus.Debugf("failed to inspect container %s: %v", id, err)
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, ctr.State.StartedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// mountSource returns the host dir of the bind or copy-on-write mount.
+func mountSource(m dockertypes.MountPoint, cowSources map[string]string) (string, bool) {
+	switch m.Type {
+	case mount.TypeBind:
+		return m.Source, true
+	case mount.TypeVolume:
+		source, ok := cowSources[m.Name]
+		return source, ok
+	}
+	return "", false
+}
+
+// isWithin returns true if path is dir or is inside dir.
+func isWithin(dir, path string) bool {
+	dir, path = filepath.Clean(dir), filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
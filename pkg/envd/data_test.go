@@ -0,0 +1,49 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envd
+
+import (
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("data", func() {
+	cowSources := map[string]string{"envd-cow-mnist-0123456789ab": "/cache/data/mnist"}
+
+	It("should use the source of bind mounts", func() {
+		source, ok := mountSource(dockertypes.MountPoint{
+			Type: mount.TypeBind, Source: "/cache/data/mnist/train"}, cowSources)
+		Expect(ok).To(BeTrue())
+		Expect(source).To(Equal("/cache/data/mnist/train"))
+	})
+
+	It("should use the lower dir of copy-on-write mounts", func() {
+		source, ok := mountSource(dockertypes.MountPoint{
+			Type:   mount.TypeVolume,
+			Name:   "envd-cow-mnist-0123456789ab",
+			Source: "/var/lib/docker/volumes/envd-cow-mnist-0123456789ab/_data",
+		}, cowSources)
+		Expect(ok).To(BeTrue())
+		Expect(isWithin("/cache/data/mnist", source)).To(BeTrue())
+	})
+
+	It("should skip other volumes", func() {
+		_, ok := mountSource(dockertypes.MountPoint{
+			Type: mount.TypeVolume, Name: "cache"}, cowSources)
+		Expect(ok).To(BeFalse())
+	})
+})
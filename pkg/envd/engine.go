@@ -18,9 +18,12 @@ import (
 	"context"
 
 	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 
+	"github.com/tensorchord/envd/pkg/config"
 	"github.com/tensorchord/envd/pkg/docker"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
 	"github.com/tensorchord/envd/pkg/types"
 )
 
@@ -33,7 +36,11 @@ type Engine interface {
 	ListEnvironment(ctx context.Context) ([]types.EnvdEnvironment, error)
 	ListEnvDependency(ctx context.Context, env string) (*types.Dependency, error)
 	ListEnvPortBinding(ctx context.Context, env string) ([]types.PortBinding, error)
+	ListEnvDaemonStatus(ctx context.Context, env string) ([]supervisor.Status, error)
 	GetInfo(ctx context.Context) (*types.EnvdInfo, error)
+
+	// ListDataset returns the datasets with the environments mounting them.
+	ListDataset(ctx context.Context) ([]types.Dataset, error)
 }
 
 type generalEngine struct {
@@ -153,6 +160,20 @@ func (e generalEngine) ListEnvPortBinding(ctx context.Context, env string) ([]ty
 	return ports, nil
 }
 
+// ListEnvDaemonStatus gets the status of the daemons supervised in the
+// environment. It returns nil if there is no supervised daemon.
+func (e generalEngine) ListEnvDaemonStatus(ctx context.Context, env string) ([]supervisor.Status, error) {
+	logrus.WithField("env", env).Debug("getting env daemon status")
+	data, err := e.dockerCli.ReadFile(ctx, env, config.ContainerSupervisorStatusPath)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read the daemon status")
+	}
+	return supervisor.ParseStatus(data)
+}
+
 func (e generalEngine) GetInfo(ctx context.Context) (*types.EnvdInfo, error) {
 	info, err := e.dockerCli.GetInfo(ctx)
 	if err != nil {
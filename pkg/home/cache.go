@@ -15,7 +15,6 @@
 package home
 
 import (
-	"encoding/gob"
 	"os"
 
 	"github.com/cockroachdb/errors"
@@ -34,75 +33,43 @@ type cacheManager interface {
 func (m *generalManager) initCache() error {
 	// Create $HOME/.cache/envd/
 	m.cacheDir = fileutil.DefaultCacheDir
-
-	cacheStatusFile, err := fileutil.CacheFile("cache.status")
-	if err != nil {
-		return errors.Wrap(err, "failed to get cache.status file path")
-	}
-	m.cacheStatusFile = cacheStatusFile
-	_, err = os.Stat(m.cacheStatusFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			logrus.WithField("filename", m.cacheStatusFile).Debug("Creating file")
-			file, err := os.Create(m.cacheStatusFile)
-			if err != nil {
-				return errors.Wrap(err, "failed to create file")
-			}
-			err = file.Close()
-			if err != nil {
-				return errors.Wrap(err, "failed to close file")
-			}
-			if err := m.dumpCacheStatus(); err != nil {
-				return errors.Wrap(err, "failed to dump cache status")
-			}
-		} else {
-			return errors.Wrap(err, "failed to stat file")
-		}
-	}
-
-	file, err := os.Open(m.cacheStatusFile)
-	if err != nil {
-		return errors.Wrap(err, "failed to open cache status file")
-	}
-	defer file.Close()
-	e := gob.NewDecoder(file)
-	if err := e.Decode(&m.cacheMap); err != nil {
-		return errors.Wrap(err, "failed to decode cache map")
+	if err := os.MkdirAll(m.cacheDir, os.ModeDir|0700); err != nil {
+		return errors.Wrap(err, "failed to create the cache dir")
 	}
 	return nil
 }
 
-func (m generalManager) MarkCache(key string, cached bool) error {
-	m.cacheMap[key] = cached
-	return m.dumpCacheStatus()
+func (m *generalManager) MarkCache(key string, cached bool) error {
+	return m.update(func(c *homeConfig) error {
+		if c.Cache == nil {
+			c.Cache = make(map[string]bool)
+		}
+		c.Cache[key] = cached
+		return nil
+	})
 }
 
-func (m generalManager) Cached(key string) bool {
+func (m *generalManager) Cached(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.cacheMap[key]
 }
 
-func (m *generalManager) dumpCacheStatus() error {
-	file, err := os.Create(m.cacheStatusFile)
-	if err != nil {
-		return errors.Wrap(err, "failed to create cache status file")
-	}
-	defer file.Close()
-
-	e := gob.NewEncoder(file)
-	if err := e.Encode(m.cacheMap); err != nil {
-		return errors.Wrap(err, "failed to encode cache map")
-	}
-	return nil
-}
-
-func (m generalManager) CacheDir() string {
+func (m *generalManager) CacheDir() string {
 	return m.cacheDir
 }
 
-func (m generalManager) CleanCache() error {
+func (m *generalManager) CleanCache() error {
 	if m.cacheDir == "" {
 		return nil
 	}
 	logrus.Debug("cleaning up host cache directory")
-	return os.RemoveAll(m.cacheDir)
+	if err := os.RemoveAll(m.cacheDir); err != nil {
+		return err
+	}
+	// The cached artifacts are removed with the dir.
+	return m.update(func(c *homeConfig) error {
+		c.Cache = nil
+		return nil
+	})
 }
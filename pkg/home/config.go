@@ -15,13 +15,45 @@
 package home
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
 	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/yaml"
 
+	"github.com/tensorchord/envd/pkg/types"
 	"github.com/tensorchord/envd/pkg/util/fileutil"
 )
 
+// configVersion is the version of config.yaml. Bump it and add a migration
+// when the schema changes.
+const configVersion = 2
+
+const configHeader = "# The envd config, edit it by hand or with `envd config set`.\n"
+
+// homeConfig is the schema of config.yaml.
+type homeConfig struct {
+	Version int `json:"version"`
+	// The fields of the embedded struct are promoted to the top level.
+	types.EnvdContext
+	// Cache records the artifacts which are already downloaded to the
+	// cache dir, e.g. oh-my-zsh and the vscode extensions.
+	Cache map[string]bool `json:"cache,omitempty"`
+}
+
 type configManager interface {
 	ConfigFile() string
+	// ConfigGet returns the value of the key in config.yaml, e.g.
+	// `current` or `contexts.default.builder_socket`.
+	ConfigGet(key string) (string, error)
+	// ConfigSet sets the value of the key in config.yaml.
+	ConfigSet(key, value string) error
+	// ConfigView returns the content of config.yaml.
+	ConfigView() (string, error)
 }
 
 func (m *generalManager) initConfig() error {
@@ -35,9 +67,274 @@ func (m *generalManager) initConfig() error {
 		return errors.Wrap(err, "failed to create config file")
 	}
 	m.configFile = config
-	return nil
+
+	// Load $HOME/.config/envd/config.yaml, it is created from the defaults
+	// and the gob files of the previous versions if it does not exist.
+	yamlConfigFile, err := fileutil.ConfigFile("config.yaml")
+	if err != nil {
+		return errors.Wrap(err, "failed to get config file")
+	}
+	m.yamlConfigFile = yamlConfigFile
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fileutil.WithLock(m.yamlConfigFile, func() error {
+		c, exists, err := m.readConfig()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			c = m.config()
+			c.Version = 0
+		}
+		// The migrations only run here, the gob files are renamed once the
+		// migrated config is written.
+		migrated, replaced, err := migrate(&c)
+		if err != nil {
+			return err
+		}
+		if !exists || migrated {
+			if err := m.writeConfig(c); err != nil {
+				return err
+			}
+			backupReplaced(replaced)
+		}
+		m.apply(c)
+		return nil
+	})
 }
 
-func (m generalManager) ConfigFile() string {
+func (m *generalManager) ConfigFile() string {
 	return m.configFile
 }
+
+// readConfig reads config.yaml, it returns false if the file does not exist.
+func (m *generalManager) readConfig() (homeConfig, bool, error) {
+	data, err := os.ReadFile(m.yamlConfigFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return homeConfig{}, false, nil
+		}
+		return homeConfig{}, false, errors.Wrap(err, "failed to read config file")
+	}
+	var c homeConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return homeConfig{}, false, errors.Wrapf(err, "failed to parse %s", m.yamlConfigFile)
+	}
+	if c.Version > configVersion {
+		return homeConfig{}, false, errors.Newf(
+			"config version %d is newer than %d, please upgrade envd", c.Version, configVersion)
+	}
+	return c, true, nil
+}
+
+func (m *generalManager) writeConfig(c homeConfig) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal config")
+	}
+	return fileutil.WriteFileAtomic(m.yamlConfigFile, append([]byte(configHeader), data...), 0644)
+}
+
+// config returns the in-memory config.
+func (m *generalManager) config() homeConfig {
+	return homeConfig{
+		Version:     configVersion,
+		EnvdContext: m.context,
+		Cache:       m.cacheMap,
+	}
+}
+
+// apply replaces the in-memory config.
+func (m *generalManager) apply(c homeConfig) {
+	// The contexts created by the previous versions do not have the runner.
+	for i := range c.Contexts {
+		if c.Contexts[i].Runner == "" {
+			c.Contexts[i].Runner = types.RunnerTypeDocker
+		}
+	}
+	m.context = c.EnvdContext
+	m.cacheMap = c.Cache
+	if m.cacheMap == nil {
+		m.cacheMap = make(map[string]bool)
+	}
+}
+
+// update reloads config.yaml, changes it with fn and writes it back while
+// holding the lock.
+func (m *generalManager) update(fn func(c *homeConfig) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fileutil.WithLock(m.yamlConfigFile, func() error {
+		c, exists, err := m.readConfig()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			c = m.config()
+		}
+		if err := fn(&c); err != nil {
+			return err
+		}
+		if err := m.writeConfig(c); err != nil {
+			return err
+		}
+		m.apply(c)
+		return nil
+	})
+}
+
+func (m *generalManager) ConfigView() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := yaml.Marshal(m.config())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal config")
+	}
+	return string(data), nil
+}
+
+func (m *generalManager) ConfigGet(key string) (string, error) {
+	m.mu.Lock()
+	tree, err := configTree(m.config())
+	m.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	v, _, err := lookupKey(tree, strings.Split(key, "."), false)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get %s", key)
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal config")
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func (m *generalManager) ConfigSet(key, value string) error {
+	parts := strings.Split(key, ".")
+	if parts[0] == "version" {
+		return errors.New("version cannot be set")
+	}
+	return m.update(func(c *homeConfig) error {
+		tree, err := configTree(*c)
+		if err != nil {
+			return err
+		}
+		v, set, err := lookupKey(tree, parts, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set %s", key)
+		}
+		if _, ok := v.(string); ok {
+			set(value)
+		} else {
+			// Parse the booleans and the numbers.
+			var parsed interface{}
+			if err := yaml.Unmarshal([]byte(value), &parsed); err != nil || parsed == nil {
+				parsed = value
+			}
+			set(parsed)
+		}
+
+		data, err := json.Marshal(tree)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal config")
+		}
+		var next homeConfig
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&next); err != nil {
+			return errors.Wrapf(err, "invalid value for %s", key)
+		}
+		if err := validateConfig(&next); err != nil {
+			return err
+		}
+		*c = next
+		return nil
+	})
+}
+
+// configTree converts the config to the generic JSON tree.
+func configTree(c homeConfig) (interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config")
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal config")
+	}
+	return tree, nil
+}
+
+// lookupKey returns the value of the key in the tree and the function to
+// set it. The items of the lists are looked up by their name or index. The
+// keys may contain dots, e.g. the cache keys, the longest existing key is
+// used. The missing keys are created if create is true.
+func lookupKey(tree interface{}, parts []string, create bool) (interface{}, func(interface{}), error) {
+	cur := tree
+	var set func(interface{})
+	for depth := 0; len(parts) != 0; depth++ {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			n := len(parts)
+			for ; n > 0; n-- {
+				if _, ok := node[strings.Join(parts[:n], ".")]; ok {
+					break
+				}
+			}
+			if n == 0 {
+				if !create {
+					return nil, nil, errors.Newf("key %s does not exist", strings.Join(parts, "."))
+				}
+				// The top-level keys do not contain dots, the others are
+				// created as a whole, e.g. cache.<key>.
+				n = len(parts)
+				if depth == 0 {
+					n = 1
+				}
+				k := strings.Join(parts[:n], ".")
+				node[k] = nil
+				if n < len(parts) {
+					node[k] = map[string]interface{}{}
+				}
+			}
+			k := strings.Join(parts[:n], ".")
+			cur, parts = node[k], parts[n:]
+			set = func(v interface{}) { node[k] = v }
+		case []interface{}:
+			idx := -1
+			for i, item := range node {
+				if obj, ok := item.(map[string]interface{}); ok && obj["name"] == parts[0] {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				i, err := strconv.Atoi(parts[0])
+				if err != nil || i < 0 || i >= len(node) {
+					return nil, nil, errors.Newf("item %s does not exist", parts[0])
+				}
+				idx = i
+			}
+			cur, parts = node[idx], parts[1:]
+			set = func(v interface{}) { node[idx] = v }
+		default:
+			return nil, nil, errors.Newf("key %s does not exist", strings.Join(parts, "."))
+		}
+	}
+	if set == nil {
+		return nil, nil, errors.New("key is required")
+	}
+	return cur, set, nil
+}
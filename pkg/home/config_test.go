@@ -0,0 +1,226 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package home
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tensorchord/envd/pkg/types"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+var _ = Describe("config.yaml", func() {
+	var configDir, cacheDir string
+	var m *generalManager
+
+	newManager := func() *generalManager {
+		return &generalManager{
+			cacheMap: make(map[string]bool),
+			context: types.EnvdContext{
+				Current: "default",
+				Contexts: []types.Context{{
+					Name:          "default",
+					Builder:       types.BuilderTypeDocker,
+					BuilderSocket: "envd_buildkitd",
+				}},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		configDir, cacheDir = fileutil.DefaultConfigDir, fileutil.DefaultCacheDir
+		fileutil.DefaultConfigDir = GinkgoT().TempDir()
+		fileutil.DefaultCacheDir = GinkgoT().TempDir()
+		m = newManager()
+	})
+	AfterEach(func() {
+		fileutil.DefaultConfigDir, fileutil.DefaultCacheDir = configDir, cacheDir
+	})
+
+	It("should migrate the gob files", func() {
+		writeGob := func(file string, v interface{}) {
+			f, err := os.Create(file)
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+			Expect(gob.NewEncoder(f).Encode(v)).To(Succeed())
+		}
+		contextFile := filepath.Join(fileutil.DefaultConfigDir, "contexts")
+		writeGob(contextFile, types.EnvdContext{
+			Current: "remote",
+			Contexts: []types.Context{
+				{Name: "default", Builder: types.BuilderTypeDocker, BuilderSocket: "envd_buildkitd"},
+				{Name: "remote", Builder: types.BuilderTypeTCP, BuilderSocket: "tcp://remote:8888"},
+			},
+		})
+		writeGob(filepath.Join(fileutil.DefaultCacheDir, "cache.status"), map[string]bool{"oh-my-zsh": true})
+
+		Expect(m.initConfig()).To(Succeed())
+		c, err := m.ContextGetCurrent()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.BuilderSocket).To(Equal("tcp://remote:8888"))
+		Expect(c.Runner).To(Equal(types.RunnerTypeDocker))
+		Expect(m.Cached("oh-my-zsh")).To(BeTrue())
+		Expect(contextFile).NotTo(BeAnExistingFile())
+		Expect(contextFile + ".bak").To(BeAnExistingFile())
+
+		data, err := os.ReadFile(filepath.Join(fileutil.DefaultConfigDir, "config.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("version: 2"))
+		Expect(string(data)).To(ContainSubstring("builder_socket: tcp://remote:8888"))
+
+		// The config is loaded from config.yaml afterwards.
+		m = newManager()
+		Expect(m.initConfig()).To(Succeed())
+		contexts, err := m.ContextList()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contexts.Current).To(Equal("remote"))
+		Expect(contexts.Contexts).To(HaveLen(2))
+	})
+
+	It("should only migrate the config when it is initialized", func() {
+		Expect(m.initConfig()).To(Succeed())
+		// A hand-edited config.yaml without the version.
+		Expect(os.WriteFile(filepath.Join(fileutil.DefaultConfigDir, "config.yaml"), []byte(`current: default
+contexts:
+- name: default
+  builder: docker-container
+  builder_socket: envd_buildkitd
+  runner: docker
+`), 0644)).To(Succeed())
+		contextFile := filepath.Join(fileutil.DefaultConfigDir, "contexts")
+		f, err := os.Create(contextFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gob.NewEncoder(f).Encode(types.EnvdContext{Current: "default"})).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		Expect(m.MarkCache("oh-my-zsh", true)).To(Succeed())
+		Expect(contextFile).To(BeAnExistingFile())
+		Expect(contextFile + ".bak").NotTo(BeAnExistingFile())
+	})
+
+	It("should replace the kubernetes runner with the kube-pod builder", func() {
+		Expect(os.WriteFile(filepath.Join(fileutil.DefaultConfigDir, "config.yaml"), []byte(`version: 1
+current: pod
+contexts:
+- name: default
+  builder: docker-container
+  builder_socket: envd_buildkitd
+  runner: docker
+- name: pod
+  builder: kube-pod
+  builder_socket: buildkitd
+  runner: kubernetes
+  runner_namespace: envd
+`), 0644)).To(Succeed())
+		Expect(m.initConfig()).To(Succeed())
+		c, err := m.ContextGetCurrent()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Runner).To(Equal(types.RunnerTypeDocker))
+		Expect(c.RunnerNamespace).To(Equal("envd"))
+		Expect(c.RunsOnKubernetes()).To(BeTrue())
+	})
+
+	It("should reject the config of a newer version", func() {
+		Expect(os.WriteFile(filepath.Join(fileutil.DefaultConfigDir, "config.yaml"),
+			[]byte("version: 100\n"), 0644)).To(Succeed())
+		Expect(m.initConfig()).To(MatchError(ContainSubstring("please upgrade envd")))
+	})
+
+	It("should get and set the values", func() {
+		Expect(m.initConfig()).To(Succeed())
+		Expect(m.ConfigGet("current")).To(Equal("default"))
+		Expect(m.ConfigGet("contexts.default.builder_socket")).To(Equal("envd_buildkitd"))
+		Expect(m.ConfigGet("contexts.0.runner")).To(Equal("docker"))
+		Expect(m.ConfigGet("version")).To(Equal("2"))
+		_, err := m.ConfigGet("contexts.missing.runner")
+		Expect(err).To(HaveOccurred())
+
+		Expect(m.ConfigSet("contexts.default.builder_socket", "tcp://localhost:8888")).To(Succeed())
+		Expect(m.ConfigSet("cache.vscode-ms-python.python", "true")).To(Succeed())
+		Expect(m.Cached("vscode-ms-python.python")).To(BeTrue())
+		Expect(m.ConfigGet("cache.vscode-ms-python.python")).To(Equal("true"))
+
+		Expect(m.ConfigSet("current", "missing")).To(MatchError(ContainSubstring("does not exist")))
+		Expect(m.ConfigSet("contexts.default.runner", "lxc")).To(MatchError(ContainSubstring("unknown runner type")))
+		Expect(m.ConfigSet("contexts.default.unknown", "a")).To(HaveOccurred())
+		Expect(m.ConfigSet("version", "2")).To(HaveOccurred())
+
+		// The changes are persisted.
+		m = newManager()
+		Expect(m.initConfig()).To(Succeed())
+		c, err := m.ContextGetCurrent()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.BuilderSocket).To(Equal("tcp://localhost:8888"))
+		Expect(c.Runner).To(Equal(types.RunnerTypeDocker))
+		view, err := m.ConfigView()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(view).To(ContainSubstring("vscode-ms-python.python: true"))
+	})
+
+	It("should not lose the changes of other processes", func() {
+		Expect(m.initConfig()).To(Succeed())
+		other := newManager()
+		Expect(other.initConfig()).To(Succeed())
+
+		Expect(m.ContextCreate(types.Context{Name: "a", Builder: types.BuilderTypeTCP}, false)).To(Succeed())
+		Expect(other.ContextCreate(types.Context{Name: "b", Builder: types.BuilderTypeTCP}, false)).To(Succeed())
+		contexts, err := other.ContextList()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contexts.Contexts).To(HaveLen(3))
+	})
+
+	It("should keep all the contexts created concurrently", func() {
+		Expect(m.initConfig()).To(Succeed())
+		n := 16
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				// Every manager stands for a separate envd process.
+				other := newManager()
+				if err := other.initConfig(); err != nil {
+					errs <- err
+					return
+				}
+				errs <- other.ContextCreate(types.Context{
+					Name:          fmt.Sprintf("ctx-%d", i),
+					Builder:       types.BuilderTypeTCP,
+					BuilderSocket: fmt.Sprintf("tcp://localhost:%d", 8000+i),
+				}, false)
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		m = newManager()
+		Expect(m.initConfig()).To(Succeed())
+		contexts, err := m.ContextList()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contexts.Contexts).To(HaveLen(n + 1))
+	})
+})
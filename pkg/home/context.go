@@ -15,145 +15,123 @@
 package home
 
 import (
-	"encoding/gob"
-	"os"
-
 	"github.com/cockroachdb/errors"
-	"github.com/sirupsen/logrus"
 
 	"github.com/tensorchord/envd/pkg/types"
-	"github.com/tensorchord/envd/pkg/util/fileutil"
 )
 
 type contextManager interface {
 	ContextFile() string
 	ContextList() (types.EnvdContext, error)
 	ContextUse(name string) error
-	ContextGetCurrent() (types.BuilderType, string, error)
-	ContextCreate(name string,
-		builder types.BuilderType, socket string, use bool) error
+	ContextGetCurrent() (*types.Context, error)
+	ContextCreate(ctx types.Context, use bool) error
 	ContextRemove(name string) error
 }
 
-func (m *generalManager) initContext() error {
-	contextFile, err := fileutil.ConfigFile("contexts")
-	if err != nil {
-		return errors.Wrap(err, "failed to get context file")
-	}
-	m.contextFile = contextFile
-
-	// Create default context.
-
-	_, err = os.Stat(m.contextFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			logrus.WithField("filename", m.contextFile).Debug("Creating file")
-			file, err := os.Create(m.contextFile)
-			if err != nil {
-				return errors.Wrap(err, "failed to create file")
-			}
-			err = file.Close()
-			if err != nil {
-				return errors.Wrap(err, "failed to close file")
-			}
-			if err := m.dumpContext(); err != nil {
-				return errors.Wrap(err, "failed to dump context")
-			}
-		} else {
-			return errors.Wrap(err, "failed to stat file")
-		}
-	}
-
-	file, err := os.Open(m.contextFile)
-	if err != nil {
-		return errors.Wrap(err, "failed to open context file")
-	}
-	defer file.Close()
-	e := gob.NewDecoder(file)
-	if err := e.Decode(&m.context); err != nil {
-		return errors.Wrap(err, "failed to decode context file")
-	}
-	return nil
-}
-
-func (m generalManager) ContextFile() string {
-	return m.contextFile
+// ContextFile returns the file which stores the contexts, config.yaml.
+func (m *generalManager) ContextFile() string {
+	return m.yamlConfigFile
 }
 
-func (m generalManager) ContextGetCurrent() (types.BuilderType, string, error) {
-	var driver types.BuilderType
-	var socket string
+func (m *generalManager) ContextGetCurrent() (*types.Context, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for _, c := range m.context.Contexts {
 		if m.context.Current == c.Name {
-			driver = c.Builder
-			socket = c.BuilderSocket
-			return driver, socket, nil
+			return &c, nil
 		}
 	}
-	return "", "", errors.New("no current context")
+	return nil, errors.New("no current context")
 }
 
-func (m *generalManager) ContextCreate(
-	name string, builder types.BuilderType, socket string, use bool) error {
-	for _, c := range m.context.Contexts {
-		if c.Name == name {
-			return errors.Newf("context \"%s\" already exists", name)
-		}
-	}
-	switch builder {
-	case types.BuilderTypeDocker, types.BuilderTypeKubernetes, types.BuilderTypeTCP:
-		m.context.Contexts = append(m.context.Contexts, types.Context{
-			Name:          name,
-			Builder:       builder,
-			BuilderSocket: socket,
-		})
-	default:
-		return errors.New("unknown builder type")
-	}
-	if use {
-		return m.ContextUse(name)
+func (m *generalManager) ContextCreate(ctx types.Context, use bool) error {
+	if err := validateContext(&ctx); err != nil {
+		return err
 	}
-	return m.dumpContext()
+	return m.update(func(c *homeConfig) error {
+		for _, existing := range c.Contexts {
+			if existing.Name == ctx.Name {
+				return errors.Newf("context \"%s\" already exists", ctx.Name)
+			}
+		}
+		c.Contexts = append(c.Contexts, ctx)
+		if use {
+			c.Current = ctx.Name
+		}
+		return nil
+	})
 }
 
 func (m *generalManager) ContextRemove(name string) error {
-	for i, c := range m.context.Contexts {
-		if c.Name == name {
-			if m.context.Current == name {
-				return errors.Newf("cannot remove current context \"%s\"", name)
+	return m.update(func(c *homeConfig) error {
+		for i, existing := range c.Contexts {
+			if existing.Name == name {
+				if c.Current == name {
+					return errors.Newf("cannot remove current context \"%s\"", name)
+				}
+				c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+				return nil
 			}
-			m.context.Contexts = append(
-				m.context.Contexts[:i], m.context.Contexts[i+1:]...)
-			return m.dumpContext()
 		}
-	}
-	return errors.Newf("cannot find context \"%s\"", name)
+		return errors.Newf("cannot find context \"%s\"", name)
+	})
 }
 
-func (m generalManager) ContextList() (types.EnvdContext, error) {
+func (m *generalManager) ContextList() (types.EnvdContext, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.context, nil
 }
 
 func (m *generalManager) ContextUse(name string) error {
-	for _, c := range m.context.Contexts {
-		if c.Name == name {
-			m.context.Current = name
-			return m.dumpContext()
+	return m.update(func(c *homeConfig) error {
+		for _, existing := range c.Contexts {
+			if existing.Name == name {
+				c.Current = name
+				return nil
+			}
 		}
-	}
-	return errors.Newf("context \"%s\" does not exist", name)
+		return errors.Newf("context \"%s\" does not exist", name)
+	})
 }
 
-func (m *generalManager) dumpContext() error {
-	file, err := os.Create(m.contextFile)
-	if err != nil {
-		return errors.Wrap(err, "failed to create cache status file")
+// validateContext checks the builder and the runner of the context, the
+// runner defaults to docker.
+func validateContext(ctx *types.Context) error {
+	if ctx.Name == "" {
+		return errors.New("context name is required")
+	}
+	switch ctx.Builder {
+	case types.BuilderTypeDocker, types.BuilderTypeKubernetes, types.BuilderTypeTCP:
+	default:
+		return errors.New("unknown builder type")
+	}
+	switch ctx.Runner {
+	case "":
+		ctx.Runner = types.RunnerTypeDocker
+	case types.RunnerTypeDocker, types.RunnerTypePodman:
+	default:
+		return errors.New("unknown runner type")
 	}
-	defer file.Close()
+	return nil
+}
 
-	e := gob.NewEncoder(file)
-	if err := e.Encode(m.context); err != nil {
-		return errors.Wrap(err, "failed to encode cache map")
+// validateConfig checks the contexts in the config.
+func validateConfig(c *homeConfig) error {
+	names := make(map[string]bool)
+	for i := range c.Contexts {
+		ctx := &c.Contexts[i]
+		if err := validateContext(ctx); err != nil {
+			return errors.Wrapf(err, "invalid context \"%s\"", ctx.Name)
+		}
+		if names[ctx.Name] {
+			return errors.Newf("context \"%s\" already exists", ctx.Name)
+		}
+		names[ctx.Name] = true
+	}
+	if !names[c.Current] {
+		return errors.Newf("context \"%s\" does not exist", c.Current)
 	}
 	return nil
 }
@@ -33,7 +33,11 @@ var _ = Describe("context test", func() {
 
 	Describe("create with use", Ordered, func() {
 		BeforeAll(func() {
-			err := GetManager().ContextCreate(testContext, testBuilder, testSocket, true)
+			err := GetManager().ContextCreate(types.Context{
+				Name:          testContext,
+				Builder:       testBuilder,
+				BuilderSocket: testSocket,
+			}, true)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -41,10 +45,11 @@ var _ = Describe("context test", func() {
 			contexts, err := GetManager().ContextList()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(contexts.Current).To(Equal(testContext))
-			builder, socket, err := GetManager().ContextGetCurrent()
+			c, err := GetManager().ContextGetCurrent()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(builder).To(Equal(testBuilder))
-			Expect(socket).To(Equal(testSocket))
+			Expect(c.Builder).To(Equal(testBuilder))
+			Expect(c.BuilderSocket).To(Equal(testSocket))
+			Expect(c.Runner).To(Equal(types.RunnerTypeDocker))
 		})
 
 		It("cannot delete the current context", func() {
@@ -63,12 +68,20 @@ var _ = Describe("context test", func() {
 
 	Describe("create without use", Ordered, func() {
 		BeforeAll(func() {
-			err := GetManager().ContextCreate(testContext, testBuilder, testSocket, false)
+			err := GetManager().ContextCreate(types.Context{
+				Name:          testContext,
+				Builder:       testBuilder,
+				BuilderSocket: testSocket,
+			}, false)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("should not be able to create the same context", func() {
-			err := GetManager().ContextCreate(testContext, testBuilder, testSocket, false)
+			err := GetManager().ContextCreate(types.Context{
+				Name:          testContext,
+				Builder:       testBuilder,
+				BuilderSocket: testSocket,
+			}, false)
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -32,11 +32,12 @@ type Manager interface {
 }
 
 type generalManager struct {
-	cacheDir        string
-	cacheStatusFile string
-	configFile      string
-	contextFile     string
+	cacheDir       string
+	configFile     string
+	yamlConfigFile string
 
+	// mu guards cacheMap and context, which are loaded from config.yaml.
+	mu sync.Mutex
 	// TODO(gaocegege): Abstract CacheManager.
 	cacheMap map[string]bool
 	context  types.EnvdContext
@@ -60,6 +61,7 @@ func Initialize() error {
 						Name:          "default",
 						Builder:       types.BuilderTypeDocker,
 						BuilderSocket: "envd_buildkitd",
+						Runner:        types.RunnerTypeDocker,
 					},
 				},
 			},
@@ -68,7 +70,11 @@ func Initialize() error {
 	return defaultManager.init()
 }
 
+// GetManager returns the manager, or nil if it is not initialized.
 func GetManager() Manager {
+	if defaultManager == nil {
+		return nil
+	}
 	return defaultManager
 }
 
@@ -77,10 +83,6 @@ func (m *generalManager) init() error {
 		return errors.Wrap(err, "failed to initialize config")
 	}
 
-	if err := m.initContext(); err != nil {
-		return errors.Wrap(err, "failed to initialize context")
-	}
-
 	if err := m.initCache(); err != nil {
 		return errors.Wrap(err, "failed to initialize cache")
 	}
@@ -90,12 +92,11 @@ func (m *generalManager) init() error {
 	}
 
 	m.logger = logrus.WithFields(logrus.Fields{
-		"cache-dir":    m.cacheDir,
-		"config-file":  m.configFile,
-		"cache-status": m.cacheStatusFile,
-		"context-file": m.contextFile,
-		"cache-map":    m.cacheMap,
-		"context":      m.context,
+		"cache-dir":        m.cacheDir,
+		"config-file":      m.configFile,
+		"yaml-config-file": m.yamlConfigFile,
+		"cache-map":        m.cacheMap,
+		"context":          m.context,
 	})
 
 	m.logger.Debug("home manager initialized")
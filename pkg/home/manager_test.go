@@ -15,7 +15,6 @@
 package home
 
 import (
-	"os"
 	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -45,17 +44,16 @@ var _ = Describe("home manager", func() {
 			m := GetManager()
 			Expect(m.CacheDir()).To(Equal(filepath.Join(fileutil.DefaultCacheDir)))
 			Expect(m.ConfigFile()).To(Equal(filepath.Join(fileutil.DefaultConfigDir, "config.envd")))
-			Expect(m.ContextFile()).To(Equal(filepath.Join(fileutil.DefaultConfigDir, "contexts")))
-			driver, socket, err := m.ContextGetCurrent()
+			Expect(m.ContextFile()).To(Equal(filepath.Join(fileutil.DefaultConfigDir, "config.yaml")))
+			c, err := m.ContextGetCurrent()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(driver).To(Equal(types.BuilderTypeDocker))
-			Expect(socket).To(Equal("envd_buildkitd"))
+			Expect(c.Builder).To(Equal(types.BuilderTypeDocker))
+			Expect(c.BuilderSocket).To(Equal("envd_buildkitd"))
 		})
 		It("should return the cache status", func() {
-			Expect(os.RemoveAll(filepath.Join(fileutil.DefaultCacheDir, "cache.status"))).NotTo(HaveOccurred())
 			Expect(Initialize()).NotTo(HaveOccurred())
 			m := GetManager()
-			m.(*generalManager).cacheMap = make(map[string]bool)
+			Expect(m.MarkCache("test", false)).To(Succeed())
 			Expect(m.Cached("test")).To(BeFalse())
 			Expect(m.MarkCache("test", true)).To(Succeed())
 			Expect(m.Cached("test")).To(BeTrue())
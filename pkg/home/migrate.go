@@ -0,0 +1,131 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package home
+
+import (
+	"encoding/gob"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/types"
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+// migrations upgrade config.yaml, migrations[i] upgrades the config from
+// version i to i+1. They return the files replaced by config.yaml, which are
+// renamed with the .bak suffix once config.yaml is written.
+var migrations = []func(c *homeConfig) ([]string, error){
+	migrateGob,
+	migrateKubernetesRunner,
+}
+
+// migrate upgrades the config to configVersion and returns true if it is
+// changed.
+func migrate(c *homeConfig) (bool, []string, error) {
+	migrated := false
+	replaced := []string{}
+	for c.Version < configVersion {
+		files, err := migrations[c.Version](c)
+		if err != nil {
+			return false, nil, errors.Wrapf(err, "failed to migrate config from version %d", c.Version)
+		}
+		replaced = append(replaced, files...)
+		c.Version++
+		migrated = true
+	}
+	return migrated, replaced, nil
+}
+
+// migrateGob reads the contexts and the cache status from the gob files
+// written by the previous versions.
+func migrateGob(c *homeConfig) ([]string, error) {
+	replaced := []string{}
+	contextFile, err := fileutil.ConfigFile("contexts")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get context file")
+	}
+	var ctx types.EnvdContext
+	if ok, err := decodeGob(contextFile, &ctx); err != nil {
+		return nil, err
+	} else if ok {
+		replaced = append(replaced, contextFile)
+		if len(ctx.Contexts) != 0 {
+			c.EnvdContext = ctx
+		}
+	}
+
+	cacheStatusFile, err := fileutil.CacheFile("cache.status")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cache.status file path")
+	}
+	cache := make(map[string]bool)
+	if ok, err := decodeGob(cacheStatusFile, &cache); err != nil {
+		return nil, err
+	} else if ok {
+		replaced = append(replaced, cacheStatusFile)
+		c.Cache = cache
+	}
+	return replaced, nil
+}
+
+// migrateKubernetesRunner replaces the kubernetes runner with the kube-pod
+// builder, whose environments run as pods.
+func migrateKubernetesRunner(c *homeConfig) ([]string, error) {
+	for i := range c.Contexts {
+		ctx := &c.Contexts[i]
+		if ctx.Runner != "kubernetes" {
+			continue
+		}
+		ctx.Runner = types.RunnerTypeDocker
+		if !ctx.RunsOnKubernetes() {
+			logrus.Warnf("the kubernetes runner of context \"%s\" is removed, "+
+				"create the context with `--builder kube-pod` to run the environments as pods", ctx.Name)
+		}
+	}
+	return nil, nil
+}
+
+// decodeGob decodes the gob file. It returns false if the file does not
+// exist or cannot be decoded.
+func decodeGob(file string, v interface{}) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to open %s", file)
+	}
+	err = gob.NewDecoder(f).Decode(v)
+	f.Close()
+	if err != nil {
+		logrus.Warnf("failed to decode %s, it is not migrated: %v", file, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// backupReplaced renames the files replaced by config.yaml to <file>.bak,
+// thus they are kept until config.yaml is written.
+func backupReplaced(files []string) {
+	for _, file := range files {
+		if err := os.Rename(file, file+".bak"); err != nil {
+			logrus.Warnf("failed to rename %s: %v", file, err)
+			continue
+		}
+		logrus.Infof("migrated %s to config.yaml", file)
+	}
+}
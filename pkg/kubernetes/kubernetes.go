@@ -0,0 +1,261 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes runs the envd environments as pods in Kubernetes.
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	"github.com/tensorchord/envd/pkg/lang/ir"
+)
+
+const (
+	localhost = "127.0.0.1"
+)
+
+var (
+	interval = 1 * time.Second
+)
+
+type Client interface {
+	// StartEnvd creates the pod and the service of the environment, and
+	// waits until the pod is ready. It returns the name of the pod.
+	StartEnvd(ctx context.Context, opt StartOptions) (string, error)
+	CleanEnvdIfExists(ctx context.Context, name string) error
+	WaitUntilReady(ctx context.Context, name string, timeout time.Duration) error
+	// PortForward forwards the local port to the pod until the context is done.
+	// The ready channel is closed when the forwarding is started.
+	PortForward(ctx context.Context, name string,
+		localPort, remotePort int, ready chan struct{}) error
+	// Namespace returns the namespace of the environments.
+	Namespace() string
+}
+
+// StartOptions are the options to start the environment.
+type StartOptions struct {
+	// Tag is the image of the environment. It must be pullable by the cluster.
+	Tag string
+	// Name is the name of the environment.
+	Name string
+	// BuildContextDir is the directory of the build context.
+	BuildContextDir string
+	// Entrypoint is the entrypoint of the environment.
+	Entrypoint []string
+	GPUEnabled bool
+	NumGPUs    int
+	Graph      ir.Graph
+	Timeout    time.Duration
+	// Detach returns once the pod is created instead of waiting until it
+	// is ready.
+	Detach bool
+}
+
+type generalClient struct {
+	kubernetes.Interface
+	config    *rest.Config
+	namespace string
+}
+
+// NewClient creates the client from the kubeconfig. The default loading
+// rules are used if kubeconfig is empty, and the namespace in the kubeconfig
+// is used if namespace is empty.
+func NewClient(kubeconfig, namespace string) (Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the kubeconfig")
+	}
+	if namespace == "" {
+		namespace, _, err = clientConfig.Namespace()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the namespace")
+		}
+	}
+	cli, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the kubernetes client")
+	}
+	return NewClientWithInterface(cli, config, namespace), nil
+}
+
+// NewClientWithInterface creates the client from the clientset, e.g.
+// the fake clientset in tests.
+func NewClientWithInterface(cli kubernetes.Interface,
+	config *rest.Config, namespace string) Client {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	return generalClient{
+		Interface: cli,
+		config:    config,
+		namespace: namespace,
+	}
+}
+
+func (c generalClient) Namespace() string {
+	return c.namespace
+}
+
+func (c generalClient) StartEnvd(ctx context.Context, opt StartOptions) (string, error) {
+	// The mounts are shared with the graph of the caller.
+	mounts := make([]ir.MountInfo, len(opt.Graph.Mount))
+	copy(mounts, opt.Graph.Mount)
+	for i := range mounts {
+		if err := mounts[i].InitDataSource(); err != nil {
+			return "", errors.Wrapf(err, "failed to fetch the data of mount %s", mounts[i].Destination)
+		}
+	}
+	opt.Graph.Mount = mounts
+
+	pod := podSpec(opt)
+	svc := serviceSpec(opt, pod)
+	logger := logrus.WithFields(logrus.Fields{
+		"namespace": c.namespace,
+		"pod":       pod.Name,
+		"image":     opt.Tag,
+	})
+
+	logger.Debug("creating the pod")
+	if _, err := c.CoreV1().Pods(c.namespace).Create(
+		ctx, pod, metav1.CreateOptions{}); err != nil {
+		return "", errors.Wrap(err, "failed to create the pod")
+	}
+	logger.Debug("creating the service")
+	if _, err := c.CoreV1().Services(c.namespace).Create(
+		ctx, svc, metav1.CreateOptions{}); err != nil {
+		return "", errors.Wrap(err, "failed to create the service")
+	}
+
+	if opt.Detach {
+		return pod.Name, nil
+	}
+	if err := c.WaitUntilReady(ctx, pod.Name, opt.Timeout); err != nil {
+		return "", errors.Wrap(err, "failed to wait until the pod is ready")
+	}
+	return pod.Name, nil
+}
+
+func (c generalClient) CleanEnvdIfExists(ctx context.Context, name string) error {
+	name = resourceName(name)
+	// Delete the pod immediately since the environment is recreated.
+	var gracePeriod int64
+	err := c.CoreV1().Pods(c.namespace).Delete(ctx, name, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete the pod")
+	}
+	err = c.CoreV1().Services(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete the service")
+	}
+
+	// Wait until the pod is deleted, or the new pod cannot be created.
+	return wait.PollImmediateUntil(interval, func() (bool, error) {
+		_, err := c.CoreV1().Pods(c.namespace).Get(ctx, name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}, ctx.Done())
+}
+
+func (c generalClient) WaitUntilReady(ctx context.Context,
+	name string, timeout time.Duration) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"namespace": c.namespace,
+		"pod":       name,
+	})
+	logger.Debug("waiting until the pod is ready")
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		pod, err := c.CoreV1().Pods(c.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			return false, errors.Newf("pod %s is %s", name, pod.Status.Phase)
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return errors.Newf("timeout %s: pod %s is not ready", timeout, name)
+	}
+	return err
+}
+
+func (c generalClient) PortForward(ctx context.Context, name string,
+	localPort, remotePort int, ready chan struct{}) error {
+	if c.config == nil {
+		return errors.New("the rest config is required to forward ports")
+	}
+	req := c.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(c.namespace).
+		Name(name).
+		SubResource("portforward")
+	transport, upgrader, err := spdy.RoundTripperFor(c.config)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the round tripper")
+	}
+	dialer := spdy.NewDialer(upgrader,
+		&http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	stop := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+	ports := []string{fmt.Sprintf("%d:%d", localPort, remotePort)}
+	fw, err := portforward.NewOnAddresses(dialer,
+		[]string{localhost}, ports, stop, ready, io.Discard, os.Stderr)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the port forwarder")
+	}
+	logrus.WithFields(logrus.Fields{
+		"pod":         name,
+		"local-port":  localPort,
+		"remote-port": remotePort,
+	}).Debug("forwarding the port")
+	return fw.ForwardPorts()
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+const testNamespace = "envd-test"
+
+// readyReactor marks the created pods ready.
+func readyReactor(action k8stesting.Action) (bool, runtime.Object, error) {
+	pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type:   corev1.PodReady,
+		Status: corev1.ConditionTrue,
+	}}
+	return false, pod, nil
+}
+
+var _ = Describe("kubernetes", func() {
+	var (
+		clientset *fake.Clientset
+		cli       Client
+		g         ir.Graph
+	)
+
+	BeforeEach(func() {
+		clientset = fake.NewSimpleClientset()
+		cli = NewClientWithInterface(clientset, nil, testNamespace)
+		interval = 10 * time.Millisecond
+		g = *ir.NewGraph()
+		g.JupyterConfig = &ir.JupyterConfig{}
+		g.RuntimeEnviron["FOO"] = "bar"
+		g.RuntimeExpose = []ir.ExposeItem{{EnvdPort: 6006, ServiceName: "tensorboard-service"}}
+		g.Mount = []ir.MountInfo{
+			{Source: "/data", Destination: "/home/envd/data"},
+			{Source: "/models", Destination: "/home/envd/models", CopyOnWrite: true},
+			{Source: "cache", Destination: "/home/envd/cache", Type: ir.MountTypeVolume, ReadOnly: true},
+		}
+	})
+
+	It("should convert the names", func() {
+		Expect(resourceName("My_Project.v2")).To(Equal("my-project-v2"))
+		Expect(resourceName("--")).To(Equal("envd"))
+		Expect(portName("tensorboard-service")).To(Equal("tensorboard-ser"))
+		Expect(portName("My  Web--UI")).To(Equal("my-web-ui"))
+		Expect(portName("8080")).To(BeEmpty())
+
+		used := map[string]bool{"ssh": true}
+		Expect(uniquePortName("web", used)).To(Equal("web"))
+		Expect(uniquePortName("web", used)).To(Equal("web-2"))
+		Expect(uniquePortName("ssh", used)).To(Equal("ssh-2"))
+		Expect(uniquePortName("tensorboard-ser", used)).To(Equal("tensorboard-ser"))
+		Expect(uniquePortName("tensorboard-ser", used)).To(Equal("tensorboard-s-2"))
+	})
+
+	It("should generate unique port names", func() {
+		g.RuntimeExpose = []ir.ExposeItem{
+			{EnvdPort: 6006, ServiceName: "tensorboard-service"},
+			{EnvdPort: 6007, ServiceName: "tensorboard-server"},
+			{EnvdPort: 8000, ServiceName: "jupyter"},
+			{EnvdPort: 8080},
+		}
+		pod := podSpec(StartOptions{Name: "mnist", Graph: g})
+		names := []string{}
+		for _, p := range pod.Spec.Containers[0].Ports {
+			names = append(names, p.Name)
+		}
+		Expect(names).To(Equal([]string{"ssh", "jupyter", "tensorboard-ser", "tensorboard-s-2", "jupyter-2", "port-8080"}))
+	})
+
+	It("should create the pod and the service", func() {
+		clientset.PrependReactor("create", "pods", readyReactor)
+		name, err := cli.StartEnvd(context.Background(), StartOptions{
+			Tag:             "docker.io/envd/mnist:dev",
+			Name:            "MNIST",
+			BuildContextDir: "/home/user/mnist",
+			Entrypoint:      []string{"envd-ssh"},
+			GPUEnabled:      true,
+			NumGPUs:         2,
+			Graph:           g,
+			Timeout:         time.Second,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("mnist"))
+
+		pod, err := clientset.CoreV1().Pods(testNamespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pod.Labels[types.ContainerLabelName]).To(Equal("mnist"))
+		Expect(pod.Annotations).To(HaveKey(types.ContainerLabelJupyterAddr))
+		container := pod.Spec.Containers[0]
+		Expect(container.Image).To(Equal("docker.io/envd/mnist:dev"))
+		Expect(container.Command).To(Equal([]string{"envd-ssh"}))
+		Expect(container.WorkingDir).To(Equal("/home/envd/mnist"))
+		Expect(container.Ports).To(HaveLen(3))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "FOO", Value: "bar"}))
+		Expect(container.VolumeMounts[0].MountPath).To(Equal("/home/envd/data"))
+		Expect(pod.Spec.Volumes[0].HostPath.Path).To(Equal("/data"))
+		Expect(container.VolumeMounts[0].ReadOnly).To(BeFalse())
+		Expect(container.VolumeMounts[1].ReadOnly).To(BeTrue())
+		Expect(pod.Spec.Volumes[2].PersistentVolumeClaim.ClaimName).To(Equal("cache"))
+		Expect(container.VolumeMounts[2].ReadOnly).To(BeTrue())
+		gpus := container.Resources.Limits[resourceGPU]
+		Expect(gpus.Value()).To(Equal(int64(2)))
+
+		svc, err := clientset.CoreV1().Services(testNamespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(svc.Spec.Selector).To(Equal(pod.Labels))
+		Expect(svc.Spec.Ports).To(HaveLen(3))
+	})
+
+	It("should fail if the pod is not ready", func() {
+		_, err := cli.StartEnvd(context.Background(), StartOptions{
+			Tag:     "envd/mnist:dev",
+			Name:    "mnist",
+			Graph:   g,
+			Timeout: 50 * time.Millisecond,
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("is not ready"))
+	})
+
+	It("should not wait for the pod if detached", func() {
+		name, err := cli.StartEnvd(context.Background(), StartOptions{
+			Tag:     "envd/mnist:dev",
+			Name:    "mnist",
+			Graph:   g,
+			Timeout: 50 * time.Millisecond,
+			Detach:  true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("mnist"))
+	})
+
+	It("should clean the environment", func() {
+		clientset.PrependReactor("create", "pods", readyReactor)
+		_, err := cli.StartEnvd(context.Background(), StartOptions{
+			Tag:     "envd/mnist:dev",
+			Name:    "mnist",
+			Graph:   g,
+			Timeout: time.Second,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cli.CleanEnvdIfExists(context.Background(), "mnist")).To(Succeed())
+		pods, err := clientset.CoreV1().Pods(testNamespace).List(
+			context.Background(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods.Items).To(BeEmpty())
+
+		// It is fine to clean the environment which does not exist.
+		Expect(cli.CleanEnvdIfExists(context.Background(), "mnist")).To(Succeed())
+	})
+})
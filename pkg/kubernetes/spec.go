@@ -0,0 +1,262 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+const (
+	containerName = "envd"
+	// resourceGPU is the resource name of the NVIDIA device plugin.
+	resourceGPU = "nvidia.com/gpu"
+
+	portNameSSH     = "ssh"
+	portNameJupyter = "jupyter"
+	portNameRStudio = "rstudio"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+	repeatedHyphens  = regexp.MustCompile(`-{2,}`)
+	letters          = regexp.MustCompile(`[a-z]`)
+)
+
+// maxPortNameLength is the max length of an IANA service name.
+const maxPortNameLength = 15
+
+// resourceName converts the environment name to a DNS-1123 label,
+// which is the name of the pod and the service.
+func resourceName(name string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = "envd"
+	}
+	return name
+}
+
+// portName converts the service name to an IANA service name, which has at
+// most 15 characters, at least one letter and no consecutive hyphens. It
+// returns an empty string if there is no such name.
+func portName(service string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(service), "-")
+	name = repeatedHyphens.ReplaceAllString(name, "-")
+	if len(name) > maxPortNameLength {
+		name = name[:maxPortNameLength]
+	}
+	name = strings.Trim(name, "-")
+	if !letters.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// uniquePortName returns the name, or the name with a numeric suffix if it
+// is already used, and marks it used.
+func uniquePortName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		suffix := fmt.Sprintf("-%d", i)
+		base := name
+		if len(base)+len(suffix) > maxPortNameLength {
+			base = strings.TrimRight(base[:maxPortNameLength-len(suffix)], "-")
+		}
+		unique = base + suffix
+	}
+	used[unique] = true
+	return unique
+}
+
+func podSpec(opt StartOptions) *corev1.Pod {
+	name := resourceName(opt.Name)
+	g := opt.Graph
+	workingDir := filepath.Join("/home/envd", filepath.Base(opt.BuildContextDir))
+
+	ports := []corev1.ContainerPort{{
+		Name:          portNameSSH,
+		ContainerPort: config.SSHPortInContainer,
+		Protocol:      corev1.ProtocolTCP,
+	}}
+	annotations := map[string]string{
+		types.ContainerLabelSSHPort: strconv.Itoa(config.SSHPortInContainer),
+	}
+	if g.JupyterConfig != nil {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          portNameJupyter,
+			ContainerPort: config.JupyterPortInContainer,
+			Protocol:      corev1.ProtocolTCP,
+		})
+		annotations[types.ContainerLabelJupyterAddr] =
+			fmt.Sprintf("http://%s:%d", name, config.JupyterPortInContainer)
+	}
+	if g.RStudioServerConfig != nil {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          portNameRStudio,
+			ContainerPort: config.RStudioServerPortInContainer,
+			Protocol:      corev1.ProtocolTCP,
+		})
+		annotations[types.ContainerLabelRStudioServerAddr] =
+			fmt.Sprintf("http://%s:%d", name, config.RStudioServerPortInContainer)
+	}
+	// The names of the ports must be unique, and the ones of the exposed
+	// ports are derived from the service names declared by the user.
+	used := map[string]bool{}
+	for _, p := range ports {
+		used[p.Name] = true
+	}
+	for _, item := range g.RuntimeExpose {
+		name := portName(item.ServiceName)
+		if name == "" {
+			name = fmt.Sprintf("port-%d", item.EnvdPort)
+		}
+		ports = append(ports, corev1.ContainerPort{
+			Name:          uniquePortName(name, used),
+			ContainerPort: int32(item.EnvdPort),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+
+	env := []corev1.EnvVar{}
+	for k, v := range g.RuntimeEnviron {
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
+	sort.Slice(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+
+	volumes := []corev1.Volume{}
+	mounts := []corev1.VolumeMount{}
+	for i, m := range g.Mount {
+		volume := fmt.Sprintf("mount-%d", i)
+		source := corev1.VolumeSource{}
+		if m.IsVolume() {
+			// Named volumes are the persistent volume claims in the namespace.
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: m.Source,
+				ReadOnly:  m.ReadOnly,
+			}
+		} else {
+			// The mount source is the path on the node, not the local machine.
+			logrus.Warnf("mounting %s of the node since the environment runs in kubernetes", m.Source)
+			source.HostPath = &corev1.HostPathVolumeSource{Path: m.Source}
+		}
+		readOnly := m.ReadOnly
+		if m.CopyOnWrite {
+			logrus.Warnf("copy-on-write mounts are not supported in kubernetes, mounting %s read-only", m.Source)
+			readOnly = true
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name:         volume,
+			VolumeSource: source,
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      volume,
+			MountPath: m.Destination,
+			ReadOnly:  readOnly,
+		})
+	}
+
+	resources := corev1.ResourceRequirements{}
+	if opt.GPUEnabled {
+		numGPUs := int64(opt.NumGPUs)
+		if numGPUs <= 0 {
+			numGPUs = 1
+		}
+		resources.Limits = corev1.ResourceList{
+			resourceGPU: *resource.NewQuantity(numGPUs, resource.DecimalSI),
+		}
+	}
+
+	// envd-ssh runs the sessions as the users only if it is root.
+	var securityContext *corev1.SecurityContext
+	if len(g.Users) != 0 {
+		root := int64(0)
+		securityContext = &corev1.SecurityContext{RunAsUser: &root}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				types.ContainerLabelName: name,
+			},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:            containerName,
+				Image:           opt.Tag,
+				Command:         opt.Entrypoint,
+				WorkingDir:      workingDir,
+				Ports:           ports,
+				Env:             env,
+				VolumeMounts:    mounts,
+				Resources:       resources,
+				SecurityContext: securityContext,
+				ReadinessProbe: &corev1.Probe{
+					Handler: corev1.Handler{
+						TCPSocket: &corev1.TCPSocketAction{
+							Port: intstr.FromInt(config.SSHPortInContainer),
+						},
+					},
+					PeriodSeconds: 1,
+				},
+			}},
+			Volumes:       volumes,
+			RestartPolicy: corev1.RestartPolicyAlways,
+		},
+	}
+}
+
+func serviceSpec(opt StartOptions, pod *corev1.Pod) *corev1.Service {
+	ports := []corev1.ServicePort{}
+	for _, p := range pod.Spec.Containers[0].Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Port:       p.ContainerPort,
+			TargetPort: intstr.FromInt(int(p.ContainerPort)),
+			Protocol:   p.Protocol,
+		})
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   pod.Name,
+			Labels: pod.Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: pod.Labels,
+			Ports:    ports,
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+}
@@ -43,6 +43,9 @@ var Module = &starlarkstruct.Module{
 			ruleJuliaPackageServer, ruleFuncJuliaPackageServer),
 		"rstudio_server": starlark.NewBuiltin(ruleRStudioServer, ruleFuncRStudioServer),
 		"entrypoint":     starlark.NewBuiltin(ruleEntrypoint, ruleFuncEntrypoint),
+		"users":          starlark.NewBuiltin(ruleUsers, ruleFuncUsers),
+		"secret":         starlark.NewBuiltin(ruleSecret, ruleFuncSecret),
+		"ordered_build":  starlark.NewBuiltin(ruleOrderedBuild, ruleFuncOrderedBuild),
 	},
 }
 
@@ -217,3 +220,59 @@ func ruleFuncEntrypoint(thread *starlark.Thread, _ *starlark.Builtin,
 	ir.Entrypoint(argList)
 	return starlark.None, nil
 }
+
+func ruleFuncUsers(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var names *starlark.List
+
+	if err := starlark.UnpackArgs(ruleUsers, args, kwargs, "names", &names); err != nil {
+		return nil, err
+	}
+
+	nameList := []string{}
+	for i := 0; i < names.Len(); i++ {
+		name, ok := starlark.AsString(names.Index(i))
+		if !ok {
+			return nil, errors.Newf("%s: names must be a list of strings", ruleUsers)
+		}
+		nameList = append(nameList, name)
+	}
+
+	logger.Debugf("rule `%s` is invoked, names=%v", ruleUsers, nameList)
+	if err := ir.Users(nameList); err != nil {
+		return nil, err
+	}
+	return starlark.None, nil
+}
+
+func ruleFuncSecret(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id, target, env starlark.String
+
+	if err := starlark.UnpackArgs(ruleSecret, args, kwargs,
+		"id", &id, "target?", &target, "env?", &env); err != nil {
+		return nil, err
+	}
+
+	idStr := id.GoString()
+	targetStr := target.GoString()
+	envStr := env.GoString()
+
+	logger.Debugf("rule `%s` is invoked, id=%s, target=%s, env=%s",
+		ruleSecret, idStr, targetStr, envStr)
+	if err := ir.Secret(idStr, targetStr, envStr); err != nil {
+		return nil, err
+	}
+	return starlark.None, nil
+}
+
+func ruleFuncOrderedBuild(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(ruleOrderedBuild, args, kwargs); err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("rule `%s` is invoked", ruleOrderedBuild)
+	ir.OrderedBuild()
+	return starlark.None, nil
+}
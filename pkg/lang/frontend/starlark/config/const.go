@@ -24,4 +24,7 @@ const (
 	ruleJuliaPackageServer = "config.julia_pkg_server"
 	ruleRStudioServer      = "config.rstudio_server"
 	ruleEntrypoint         = "config.entrypoint"
+	ruleUsers              = "config.users"
+	ruleSecret             = "config.secret"
+	ruleOrderedBuild       = "config.ordered_build"
 )
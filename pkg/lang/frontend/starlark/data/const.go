@@ -16,6 +16,9 @@ package data
 
 const (
 	ruleEnvdManagedDataSource = "data.envd"
+	ruleLocalDataSource       = "data.local"
+	ruleS3DataSource          = "data.s3"
+	ruleHTTPDataSource        = "data.http"
 	huggingFaceDatasetPath    = "~/.cache/huggingface/datasets"
 	dglFaceDatasetPath        = "~/.dgl"
 )
@@ -20,6 +20,7 @@ import (
 	"go.starlark.net/starlarkstruct"
 
 	envddata "github.com/tensorchord/envd/pkg/data"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/builtin"
 )
 
 var (
@@ -29,7 +30,10 @@ var (
 var Module = &starlarkstruct.Module{
 	Name: "data",
 	Members: starlark.StringDict{
-		"envd": starlark.NewBuiltin(ruleEnvdManagedDataSource, ruleValueEnvdManagedDataSource),
+		"envd":  starlark.NewBuiltin(ruleEnvdManagedDataSource, ruleValueEnvdManagedDataSource),
+		"local": starlark.NewBuiltin(ruleLocalDataSource, ruleValueLocalDataSource),
+		"s3":    starlark.NewBuiltin(ruleS3DataSource, ruleValueS3DataSource),
+		"http":  starlark.NewBuiltin(ruleHTTPDataSource, ruleValueHTTPDataSource),
 		"path": &starlarkstruct.Module{
 			Name: "path",
 			Members: starlark.StringDict{
@@ -52,3 +56,48 @@ func ruleValueEnvdManagedDataSource(thread *starlark.Thread, _ *starlark.Builtin
 
 	return NewDataSourceValue(envddata.NewEnvdManagedDataSource(name.String())), nil
 }
+
+func ruleValueLocalDataSource(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path starlark.String
+
+	if err := starlark.UnpackArgs(ruleLocalDataSource, args, kwargs,
+		"path", &path); err != nil {
+		return nil, err
+	}
+	logger.Debugf("rule `%s` is invoked, path=%s",
+		ruleLocalDataSource, path)
+
+	buildContextDir, _ := starlark.Universe[builtin.BuildContextDir].(starlark.String)
+	return NewDataSourceValue(envddata.NewLocalDataSource(
+		buildContextDir.GoString(), path.GoString())), nil
+}
+
+func ruleValueS3DataSource(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var bucket, prefix, endpoint starlark.String
+
+	if err := starlark.UnpackArgs(ruleS3DataSource, args, kwargs,
+		"bucket", &bucket, "prefix?", &prefix, "endpoint?", &endpoint); err != nil {
+		return nil, err
+	}
+	logger.Debugf("rule `%s` is invoked, bucket=%s, prefix=%s, endpoint=%s",
+		ruleS3DataSource, bucket, prefix, endpoint)
+
+	return NewDataSourceValue(envddata.NewS3DataSource(
+		bucket.GoString(), prefix.GoString(), endpoint.GoString())), nil
+}
+
+func ruleValueHTTPDataSource(thread *starlark.Thread, _ *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url, sha256 starlark.String
+
+	if err := starlark.UnpackArgs(ruleHTTPDataSource, args, kwargs,
+		"url", &url, "sha256?", &sha256); err != nil {
+		return nil, err
+	}
+	logger.Debugf("rule `%s` is invoked, url=%s, sha256=%s",
+		ruleHTTPDataSource, url, sha256)
+
+	return NewDataSourceValue(envddata.NewHTTPDataSource(url.GoString(), sha256.GoString())), nil
+}
@@ -15,8 +15,13 @@
 package starlark
 
 import (
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/tensorchord/envd/pkg/lang/ir"
 )
 
 var _ = Describe("Starlark", func() {
@@ -26,4 +31,18 @@ var _ = Describe("Starlark", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(hash).To(Equal("6beb7c4921722246"))
 	})
+
+	It("should fetch the mounted data sources when the environment starts", func() {
+		dir := GinkgoT().TempDir()
+		ir.DefaultGraph = ir.NewGraph()
+		_, err := NewInterpreter(dir).Eval(`io.mount(src=data.local("./data"), dest="/data")`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ir.DefaultGraph.Mount).To(HaveLen(1))
+		m := ir.DefaultGraph.Mount[0]
+		Expect(m.Source).To(BeEmpty())
+
+		Expect(os.Mkdir(filepath.Join(dir, "data"), 0755)).To(Succeed())
+		Expect(m.InitDataSource()).To(Succeed())
+		Expect(m.Source).To(Equal(filepath.Join(dir, "data")))
+	})
 })
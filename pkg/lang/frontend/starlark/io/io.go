@@ -44,25 +44,29 @@ var Module = &starlarkstruct.Module{
 func ruleFuncMount(thread *starlark.Thread, _ *starlark.Builtin,
 	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var source starlark.Value
-	var destination starlark.String
+	var destination, mode, mountType starlark.String
+	var readonly bool
 
 	if err := starlark.UnpackArgs(ruleMount, args, kwargs,
-		"src?", &source, "dest?", &destination); err != nil {
+		"src?", &source, "dest?", &destination, "readonly?", &readonly,
+		"mode?", &mode, "type?", &mountType); err != nil {
 		return nil, err
 	}
 
 	var sourceStr string
-	var err error
-
-	if v, ok := source.(*data.DataSourceValue); ok {
-		err = v.Init()
-		if err != nil {
-			return starlark.None, err
-		}
-		sourceStr, err = v.GetHostDir()
-		if err != nil {
-			return starlark.None, err
+	var dataSource ir.DataSource
+
+	typ := ir.MountType(mountType.GoString())
+	if typ == ir.MountTypeVolume {
+		// The source is the name of the volume.
+		vs, ok := source.(starlark.String)
+		if !ok {
+			return starlark.None, errors.New("the source of a volume mount must be the volume name")
 		}
+		sourceStr = vs.GoString()
+	} else if v, ok := source.(*data.DataSourceValue); ok {
+		// It is fetched when the environment starts.
+		dataSource = v
 	} else if vs, ok := source.(starlark.String); ok {
 		sourceStr = vs.GoString()
 	} else {
@@ -71,16 +75,19 @@ func ruleFuncMount(thread *starlark.Thread, _ *starlark.Builtin,
 
 	destinationStr := destination.GoString()
 
-	logger.Debugf("rule `%s` is invoked, src=%s, dest=%s",
-		ruleMount, sourceStr, destinationStr)
+	logger.Debugf("rule `%s` is invoked, src=%s, dest=%s, readonly=%t, mode=%s, type=%s",
+		ruleMount, sourceStr, destinationStr, readonly, mode.GoString(), typ)
 
-	// Expand source directory based on host user
+	// Expand source directory based on host user, the source of a volume
+	// mount is the volume name.
 	usr, _ := user.Current()
 	dir := usr.HomeDir
-	if sourceStr == "~" {
-		sourceStr = dir
-	} else if strings.HasPrefix(sourceStr, "~/") {
-		sourceStr = filepath.Join(dir, sourceStr[2:])
+	if typ != ir.MountTypeVolume {
+		if sourceStr == "~" {
+			sourceStr = dir
+		} else if strings.HasPrefix(sourceStr, "~/") {
+			sourceStr = filepath.Join(dir, sourceStr[2:])
+		}
 	}
 	// Expand dest directory based on container user envd
 	dir = "/home/envd/"
@@ -89,8 +96,20 @@ func ruleFuncMount(thread *starlark.Thread, _ *starlark.Builtin,
 	} else if strings.HasPrefix(destinationStr, "~/") {
 		destinationStr = filepath.Join(dir, destinationStr[2:])
 	}
-	ir.Mount(sourceStr, destinationStr)
 
+	m := ir.MountInfo{
+		Source:      sourceStr,
+		Destination: destinationStr,
+		Type:        typ,
+		ReadOnly:    readonly,
+		DataSource:  dataSource,
+	}
+	if err := m.SetMode(mode.GoString()); err != nil {
+		return starlark.None, err
+	}
+	if err := ir.Mount(m); err != nil {
+		return starlark.None, err
+	}
 	return starlark.None, nil
 }
 
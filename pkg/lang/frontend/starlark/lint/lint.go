@@ -0,0 +1,368 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint statically checks the envd manifests without executing them.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/builtin"
+	starlarkconfig "github.com/tensorchord/envd/pkg/lang/frontend/starlark/config"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/data"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/install"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/io"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/runtime"
+	"github.com/tensorchord/envd/pkg/lang/frontend/starlark/universe"
+)
+
+// Issue is a problem found in the manifest.
+type Issue struct {
+	Filename string `json:"filename"`
+	Line     int32  `json:"line"`
+	Col      int32  `json:"col"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", i.Filename, i.Line, i.Col, i.Message, i.Check)
+}
+
+var modules = map[string]*starlarkstruct.Module{
+	"install": install.Module,
+	"config":  starlarkconfig.Module,
+	"io":      io.Module,
+	"runtime": runtime.Module,
+	"data":    data.Module,
+}
+
+// packageRules are the rules whose `name` argument is a list of packages.
+var packageRules = map[string]bool{
+	"install.python_packages": true,
+	"install.system_packages": true,
+	"install.r_packages":      true,
+	"install.julia_packages":  true,
+	"install.conda_packages":  true,
+}
+
+// reservedPorts are the ports used by envd in the container.
+var reservedPorts = map[int]string{
+	config.SSHPortInContainer:           "ssh",
+	config.JupyterPortInContainer:       "jupyter",
+	config.RStudioServerPortInContainer: "rstudio",
+}
+
+type linter struct {
+	filename        string
+	buildContextDir string
+	issues          []Issue
+
+	packages  map[string]map[string]syntax.Position
+	envdPorts map[int]syntax.Position
+	hostPorts map[int]syntax.Position
+	language  *string
+	jupyter   []syntax.Position
+}
+
+// LintFile checks the manifest file. The relative paths in the manifest are
+// resolved against buildContextDir.
+func LintFile(filename, buildContextDir string) ([]Issue, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+	return Lint(filename, src, buildContextDir)
+}
+
+// Lint checks the source of the manifest.
+func Lint(filename string, src interface{}, buildContextDir string) ([]Issue, error) {
+	l := &linter{
+		filename:        filename,
+		buildContextDir: buildContextDir,
+		packages:        make(map[string]map[string]syntax.Position),
+		envdPorts:       make(map[int]syntax.Position),
+		hostPorts:       make(map[int]syntax.Position),
+	}
+
+	f, err := syntax.Parse(filename, src, 0)
+	if err != nil {
+		var syntaxErr syntax.Error
+		if errors.As(err, &syntaxErr) {
+			l.report(syntaxErr.Pos, "syntax", syntaxErr.Msg)
+			return l.issues, nil
+		}
+		return nil, errors.Wrap(err, "failed to parse the manifest")
+	}
+
+	l.resolve(f)
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok {
+			l.checkCall(call)
+		}
+		return true
+	})
+	l.checkJupyter()
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+		return l.issues[i].Col < l.issues[j].Col
+	})
+	logrus.WithFields(logrus.Fields{
+		"file":   filename,
+		"issues": len(l.issues),
+	}).Debug("lint the manifest")
+	return l.issues, nil
+}
+
+func (l *linter) report(pos syntax.Position, check, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{
+		Filename: l.filename,
+		Line:     pos.Line,
+		Col:      pos.Col,
+		Check:    check,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// resolve reports the undefined names, e.g. a misspelled rule.
+func (l *linter) resolve(f *syntax.File) {
+	universe.RegisterEnvdRules()
+	isPredeclared := func(name string) bool {
+		_, ok := modules[name]
+		return ok
+	}
+	isUniversal := func(name string) bool {
+		return starlark.Universe.Has(name) || name == builtin.BuildContextDir
+	}
+	if err := resolve.File(f, isPredeclared, isUniversal); err != nil {
+		var errs resolve.ErrorList
+		if !errors.As(err, &errs) {
+			return
+		}
+		for _, e := range errs {
+			if strings.HasPrefix(e.Msg, "undefined: ") {
+				l.report(e.Pos, "unknown-rule", "unknown rule or name %s",
+					strings.TrimPrefix(e.Msg, "undefined: "))
+			} else {
+				l.report(e.Pos, "syntax", e.Msg)
+			}
+		}
+	}
+}
+
+func (l *linter) checkCall(call *syntax.CallExpr) {
+	name, pos, ok := ruleName(call)
+	if !ok {
+		return
+	}
+	if module, member, dotted := strings.Cut(name, "."); dotted {
+		if m, exists := modules[module]; exists && !m.Members.Has(member) {
+			l.report(pos, "unknown-rule", "unknown rule %s", name)
+			return
+		}
+	}
+
+	switch {
+	case packageRules[name]:
+		l.checkPackages(name, call)
+	case name == "base":
+		if lang, ok := stringArg(call, 1, "language"); ok {
+			l.language = &lang
+		}
+	case name == "config.jupyter":
+		start, _ := call.Span()
+		l.jupyter = append(l.jupyter, start)
+	case name == "runtime.expose":
+		l.checkExpose(call)
+	case name == "io.mount":
+		l.checkMount(call)
+	}
+}
+
+func (l *linter) checkPackages(rule string, call *syntax.CallExpr) {
+	arg := argument(call, 0, "name")
+	if arg == nil {
+		return
+	}
+	list, ok := arg.(*syntax.ListExpr)
+	if !ok {
+		// Identifiers, calls and comprehensions may produce a list.
+		if isLiteral(arg) {
+			start, _ := arg.Span()
+			l.report(start, "invalid-argument", "%s expects a list of packages for name", rule)
+		}
+		return
+	}
+
+	kind := strings.TrimPrefix(rule, "install.")
+	if kind == "python_packages" || kind == "conda_packages" {
+		// conda and pip share the same python environment.
+		kind = "python"
+	}
+	if _, ok := l.packages[kind]; !ok {
+		l.packages[kind] = make(map[string]syntax.Position)
+	}
+	for _, e := range list.List {
+		lit, ok := e.(*syntax.Literal)
+		if !ok || lit.Token != syntax.STRING {
+			continue
+		}
+		pkg := lit.Value.(string)
+		if prev, exists := l.packages[kind][pkg]; exists {
+			l.report(lit.TokenPos, "duplicate-package",
+				"package %s is already declared at %d:%d", pkg, prev.Line, prev.Col)
+			continue
+		}
+		l.packages[kind][pkg] = lit.TokenPos
+	}
+}
+
+func (l *linter) checkJupyter() {
+	if l.language == nil || strings.HasPrefix(*l.language, "python") {
+		return
+	}
+	for _, pos := range l.jupyter {
+		l.report(pos, "unsupported-rule",
+			"config.jupyter is not supported with language %s", *l.language)
+	}
+}
+
+func (l *linter) checkExpose(call *syntax.CallExpr) {
+	if port, pos, ok := intArg(call, 0, "envd_port"); ok {
+		if service, reserved := reservedPorts[port]; reserved {
+			l.report(pos, "port-conflict", "envd_port %d is reserved for %s", port, service)
+		} else if prev, exists := l.envdPorts[port]; exists {
+			l.report(pos, "port-conflict",
+				"envd_port %d is already exposed at %d:%d", port, prev.Line, prev.Col)
+		} else {
+			l.envdPorts[port] = pos
+		}
+	}
+	if port, pos, ok := intArg(call, 1, "host_port"); ok && port != 0 {
+		if prev, exists := l.hostPorts[port]; exists {
+			l.report(pos, "port-conflict",
+				"host_port %d is already used at %d:%d", port, prev.Line, prev.Col)
+		} else {
+			l.hostPorts[port] = pos
+		}
+	}
+}
+
+func (l *linter) checkMount(call *syntax.CallExpr) {
+	if typ, ok := stringArg(call, 4, "type"); ok && typ == "volume" {
+		// The source is the name of the volume.
+		return
+	}
+	arg := argument(call, 0, "src")
+	lit, ok := arg.(*syntax.Literal)
+	if !ok || lit.Token != syntax.STRING {
+		// Data sources are created on demand.
+		return
+	}
+	src := lit.Value.(string)
+	path := expandHome(src)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.buildContextDir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			l.report(lit.TokenPos, "mount-source", "mount source %s does not exist", src)
+		} else {
+			l.report(lit.TokenPos, "mount-source", "failed to stat mount source %s: %s", src, err)
+		}
+	}
+}
+
+// ruleName returns the name of the called rule, e.g. `install.cuda`.
+func ruleName(call *syntax.CallExpr) (string, syntax.Position, bool) {
+	switch fn := call.Fn.(type) {
+	case *syntax.Ident:
+		return fn.Name, fn.NamePos, true
+	case *syntax.DotExpr:
+		if x, ok := fn.X.(*syntax.Ident); ok {
+			return x.Name + "." + fn.Name.Name, x.NamePos, true
+		}
+	}
+	return "", syntax.Position{}, false
+}
+
+// argument returns the argument by the position or the keyword.
+func argument(call *syntax.CallExpr, index int, name string) syntax.Expr {
+	positional := 0
+	for _, arg := range call.Args {
+		if bin, ok := arg.(*syntax.BinaryExpr); ok && bin.Op == syntax.EQ {
+			if key, ok := bin.X.(*syntax.Ident); ok && key.Name == name {
+				return bin.Y
+			}
+			continue
+		}
+		if positional == index {
+			return arg
+		}
+		positional++
+	}
+	return nil
+}
+
+func stringArg(call *syntax.CallExpr, index int, name string) (string, bool) {
+	lit, ok := argument(call, index, name).(*syntax.Literal)
+	if !ok || lit.Token != syntax.STRING {
+		return "", false
+	}
+	return lit.Value.(string), true
+}
+
+func intArg(call *syntax.CallExpr, index int, name string) (int, syntax.Position, bool) {
+	lit, ok := argument(call, index, name).(*syntax.Literal)
+	if !ok || lit.Token != syntax.INT {
+		return 0, syntax.Position{}, false
+	}
+	v, ok := lit.Value.(int64)
+	return int(v), lit.TokenPos, ok
+}
+
+func isLiteral(e syntax.Expr) bool {
+	switch e.(type) {
+	case *syntax.Literal, *syntax.DictExpr, *syntax.TupleExpr:
+		return true
+	}
+	return false
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(usr.HomeDir, strings.TrimPrefix(path, "~"))
+}
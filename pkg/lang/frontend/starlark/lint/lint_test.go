@@ -0,0 +1,124 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func checks(issues []Issue) []string {
+	res := []string{}
+	for _, issue := range issues {
+		res = append(res, issue.Check)
+	}
+	return res
+}
+
+var _ = Describe("lint", func() {
+	It("should not report a valid manifest", func() {
+		src := `
+def build():
+    base(os="ubuntu20.04", language="python3")
+    install.python_packages(name=["numpy"])
+    config.jupyter()
+    runtime.expose(envd_port=6006, host_port=6006)
+    io.mount(src=".", dest="/data")
+`
+		issues, err := Lint("build.envd", src, os.TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(BeEmpty())
+	})
+
+	It("should report the syntax error", func() {
+		issues, err := Lint("build.envd", "def build(:\n", ".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"syntax"}))
+		Expect(issues[0].Line).To(Equal(int32(1)))
+	})
+
+	It("should report the unknown rules", func() {
+		src := `
+def build():
+    bse(os="ubuntu20.04")
+    install.python_package(name=["numpy"])
+`
+		issues, err := Lint("build.envd", src, ".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"unknown-rule", "unknown-rule"}))
+		Expect(issues[0].String()).To(Equal("build.envd:3:5: unknown rule or name bse (unknown-rule)"))
+		Expect(issues[1].Line).To(Equal(int32(4)))
+	})
+
+	It("should report the invalid and duplicate packages", func() {
+		src := `
+def build():
+    install.python_packages("numpy")
+    install.python_packages(name=["torch"])
+    install.python_packages(name=["torch", "numpy"])
+    install.system_packages(name=["torch"])
+    install.conda_packages(name=["numpy"])
+    install.conda_packages(name=["pytorch"])
+`
+		issues, err := Lint("build.envd", src, ".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"invalid-argument", "duplicate-package", "duplicate-package"}))
+		Expect(issues[1].Line).To(Equal(int32(5)))
+		Expect(issues[2].Line).To(Equal(int32(7)))
+	})
+
+	It("should report jupyter in a non-python environment", func() {
+		src := `
+def build():
+    config.jupyter()
+    base(os="ubuntu20.04", language="r")
+`
+		issues, err := Lint("build.envd", src, ".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"unsupported-rule"}))
+	})
+
+	It("should report the port conflicts", func() {
+		src := `
+def build():
+    runtime.expose(envd_port=2222)
+    runtime.expose(envd_port=6006, host_port=8000)
+    runtime.expose(6006, 8000)
+`
+		issues, err := Lint("build.envd", src, ".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"port-conflict", "port-conflict", "port-conflict"}))
+	})
+
+	It("should report the missing mount sources", func() {
+		dir, err := os.MkdirTemp("", "envd-lint")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.Mkdir(dir+"/data", 0755)).To(Succeed())
+		src := `
+def build():
+    io.mount(src="data", dest="/data")
+    io.mount("missing", "/missing")
+    io.mount(src=data.envd("mnist"), dest="/mnist")
+    io.mount(src="cache", dest="/cache", type="volume")
+`
+		issues, err := Lint("build.envd", src, dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks(issues)).To(Equal([]string{"mount-source"}))
+		Expect(issues[0].Line).To(Equal(int32(4)))
+	})
+})
@@ -14,9 +14,17 @@
 
 package runtime
 
+import "time"
+
 const (
 	ruleCommand = "runtime.command"
 	ruleExpose  = "runtime.expose"
 	ruleDaemon  = "runtime.daemon"
 	ruleEnviron = "runtime.environ"
 )
+
+const (
+	defaultHealthcheckInterval = 10 * time.Second
+	defaultHealthcheckTimeout  = 5 * time.Second
+	defaultHealthcheckRetries  = 3
+)
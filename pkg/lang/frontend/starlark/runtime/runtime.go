@@ -15,12 +15,15 @@
 package runtime
 
 import (
+	"time"
+
 	"github.com/cockroachdb/errors"
 	"github.com/sirupsen/logrus"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 
 	"github.com/tensorchord/envd/pkg/lang/ir"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
 )
 
 var (
@@ -65,32 +68,124 @@ func ruleFuncCommand(thread *starlark.Thread, _ *starlark.Builtin,
 
 func ruleFuncDaemon(thread *starlark.Thread, _ *starlark.Builtin,
 	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var commands *starlark.List
+	var (
+		commands    *starlark.List
+		restart     = starlark.String("")
+		healthcheck *starlark.Dict
+	)
 
-	if err := starlark.UnpackArgs(ruleDaemon, args, kwargs, "commands", &commands); err != nil {
+	if err := starlark.UnpackArgs(ruleDaemon, args, kwargs, "commands", &commands,
+		"restart?", &restart, "healthcheck?", &healthcheck); err != nil {
 		return nil, err
 	}
 
-	commandList := [][]string{}
+	policy, err := supervisor.ParseRestartPolicy(restart.GoString())
+	if err != nil {
+		return nil, err
+	}
+	hc, err := parseHealthcheck(healthcheck)
+	if err != nil {
+		return nil, err
+	}
+
+	daemons := []ir.DaemonConfig{}
 	if commands != nil {
 		for i := 0; i < commands.Len(); i++ {
 			args, ok := commands.Index(i).(*starlark.List)
 			if !ok {
 				return nil, errors.Newf("invalid daemon commands (%s)", commands.Index(i).String())
 			}
-			argList := []string{}
-			for j := 0; j < args.Len(); j++ {
-				argList = append(argList, args.Index(j).(starlark.String).GoString())
+			argList, err := stringList(args)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid daemon commands (%s)", args.String())
 			}
-			commandList = append(commandList, argList)
+			daemons = append(daemons, ir.DaemonConfig{
+				Commands:    argList,
+				Restart:     string(policy),
+				Healthcheck: hc,
+			})
 		}
 
-		logger.Debugf("rule `%s` is invoked, commands=%v", ruleDaemon, commandList)
-		ir.RuntimeDaemon(commandList)
+		logger.Debugf("rule `%s` is invoked, daemons=%v", ruleDaemon, daemons)
+		ir.RuntimeDaemon(daemons)
 	}
 	return starlark.None, nil
 }
 
+// parseHealthcheck parses the healthcheck of the daemon, e.g.
+// {"command": ["curl", "-f", "localhost:8501"], "interval": "10s",
+// "timeout": "5s", "retries": 3}.
+func parseHealthcheck(dict *starlark.Dict) (*ir.HealthcheckConfig, error) {
+	if dict == nil {
+		return nil, nil
+	}
+	hc := &ir.HealthcheckConfig{
+		Interval: defaultHealthcheckInterval,
+		Timeout:  defaultHealthcheckTimeout,
+		Retries:  defaultHealthcheckRetries,
+	}
+	for _, item := range dict.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return nil, errors.Newf("invalid healthcheck key %s", item[0].String())
+		}
+		value := item[1]
+		switch key {
+		case "command":
+			switch v := value.(type) {
+			case starlark.String:
+				hc.Commands = []string{v.GoString()}
+			case *starlark.List:
+				commands, err := stringList(v)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid healthcheck command")
+				}
+				hc.Commands = commands
+			default:
+				return nil, errors.Newf("invalid healthcheck command %s", value.String())
+			}
+		case "interval", "timeout":
+			s, ok := starlark.AsString(value)
+			if !ok {
+				return nil, errors.Newf("healthcheck %s must be a duration string, e.g. \"10s\"", key)
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return nil, errors.Newf("invalid healthcheck %s %s", key, s)
+			}
+			if key == "interval" {
+				hc.Interval = d
+			} else {
+				hc.Timeout = d
+			}
+		case "retries":
+			var retries int
+			if err := starlark.AsInt(value, &retries); err != nil || retries < 1 {
+				return nil, errors.Newf("healthcheck retries must be a positive integer")
+			}
+			hc.Retries = retries
+		default:
+			return nil, errors.Newf("unknown healthcheck key %s", key)
+		}
+	}
+	if len(hc.Commands) == 0 {
+		return nil, errors.New("healthcheck command is required")
+	}
+	return hc, nil
+}
+
+func stringList(list *starlark.List) ([]string, error) {
+	res := []string{}
+	for i := 0; i < list.Len(); i++ {
+		s, ok := starlark.AsString(list.Index(i))
+		if !ok {
+			return nil, errors.Newf("%s is not a string", list.Index(i).String())
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
 func ruleFuncExpose(thread *starlark.Thread, _ *starlark.Builtin,
 	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
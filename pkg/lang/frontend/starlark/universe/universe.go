@@ -17,6 +17,7 @@ package universe
 import (
 	"fmt"
 
+	"github.com/cockroachdb/errors"
 	"github.com/sirupsen/logrus"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -64,10 +65,11 @@ func ruleFuncBase(thread *starlark.Thread, _ *starlark.Builtin,
 
 func ruleFuncRun(thread *starlark.Thread, _ *starlark.Builtin,
 	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var commands *starlark.List
+	var commands, secrets *starlark.List
+	var mountSSH bool
 
-	if err := starlark.UnpackArgs(ruleRun,
-		args, kwargs, "commands?", &commands); err != nil {
+	if err := starlark.UnpackArgs(ruleRun, args, kwargs,
+		"commands?", &commands, "secrets?", &secrets, "mount_ssh?", &mountSSH); err != nil {
 		return nil, err
 	}
 
@@ -77,9 +79,20 @@ func ruleFuncRun(thread *starlark.Thread, _ *starlark.Builtin,
 			goCommands = append(goCommands, commands.Index(i).(starlark.String).GoString())
 		}
 	}
+	goSecrets := []string{}
+	if secrets != nil {
+		for i := 0; i < secrets.Len(); i++ {
+			secret, ok := starlark.AsString(secrets.Index(i))
+			if !ok {
+				return nil, errors.Newf("%s: secrets must be a list of strings", ruleRun)
+			}
+			goSecrets = append(goSecrets, secret)
+		}
+	}
 
-	logger.Debugf("rule `%s` is invoked, commands=%v", ruleRun, goCommands)
-	if err := ir.Run(goCommands); err != nil {
+	logger.Debugf("rule `%s` is invoked, commands=%v, secrets=%v, mount_ssh=%t",
+		ruleRun, goCommands, goSecrets, mountSSH)
+	if err := ir.Run(goCommands, goSecrets, mountSSH); err != nil {
 		return nil, err
 	}
 
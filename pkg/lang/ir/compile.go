@@ -19,11 +19,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/moby/buildkit/client/llb"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
@@ -70,29 +69,39 @@ func NumGPUs() int {
 	return DefaultGraph.NumGPUs
 }
 
-func Compile(ctx context.Context, envName string, pub string) (*llb.Definition, error) {
-	w, err := compileui.New(ctx, os.Stdout, "auto")
+// Compile compiles the default graph for every platform, the definitions
+// are returned in the same order as the platforms.
+func Compile(ctx context.Context, envName string, pub string, progressMode string,
+	platforms []ocispecs.Platform) ([]*llb.Definition, error) {
+	w, err := compileui.New(ctx, os.Stdout, progressMode)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create compileui")
 	}
 	DefaultGraph.Writer = w
 	DefaultGraph.EnvironmentName = envName
 	DefaultGraph.PublicKeyPath = pub
+	defer w.Finish()
 
 	uid, gid, err := getUIDGID()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get uid/gid")
 	}
-	state, err := DefaultGraph.Compile(uid, gid)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to compile")
-	}
-	// TODO(gaocegege): Support multi platform.
-	def, err := state.Marshal(ctx, llb.LinuxAmd64)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal the llb definition")
+	defs := []*llb.Definition{}
+	for i := range platforms {
+		p := platforms[i]
+		g := *DefaultGraph
+		g.platform = &p
+		state, err := g.Compile(uid, gid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile for %s/%s", p.OS, p.Architecture)
+		}
+		def, err := state.Marshal(ctx, llb.Platform(p))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal the llb definition")
+		}
+		defs = append(defs, def)
 	}
-	return def, nil
+	return defs, nil
 }
 
 func Labels() (map[string]string, error) {
@@ -140,6 +149,11 @@ func (g Graph) Labels() (map[string]string, error) {
 		}
 	}
 	labels[types.ImageLabelVendor] = types.ImageVendorEnvd
+	str, err = json.Marshal(g.RuleDigests())
+	if err != nil {
+		return nil, err
+	}
+	labels[types.ImageLabelRules] = string(str)
 
 	return labels, nil
 }
@@ -191,41 +205,20 @@ func (g Graph) GetEntrypoint(buildContextDir string) ([]string, error) {
 		return g.Entrypoint, nil
 	}
 
+	// The spec is passed in the entrypoint since the working directory of
+	// jupyter depends on the build context.
+	spec, err := g.supervisorSpecJSON(buildContextDir)
+	if err != nil {
+		return nil, err
+	}
 	ep := []string{
 		"tini",
 		"--",
-		"bash",
-		"-c",
-	}
-
-	template := `set -e
-/var/envd/bin/envd-ssh --authorized-keys %s --port %d --shell %s &
-%s
-wait -n`
-
-	// Generate jupyter and rstudio server commands.
-	var customCmd strings.Builder
-	workingDir := filepath.Join("/home/envd", filepath.Base(buildContextDir))
-	if g.RuntimeDaemon != nil {
-		for _, command := range g.RuntimeDaemon {
-			customCmd.WriteString(fmt.Sprintf("%s &\n", strings.Join(command, " ")))
-		}
-	}
-	if g.JupyterConfig != nil {
-		jupyterCmd := g.generateJupyterCommand(workingDir)
-		customCmd.WriteString(strings.Join(jupyterCmd, " "))
-		customCmd.WriteString("\n")
+		config.ContainerEnvdSSHPath,
+		"supervise",
+		"--spec-json",
+		spec,
 	}
-	if g.RStudioServerConfig != nil {
-		rstudioCmd := g.generateRStudioCommand(workingDir)
-		customCmd.WriteString(strings.Join(rstudioCmd, " "))
-		customCmd.WriteString("\n")
-	}
-
-	cmd := fmt.Sprintf(template,
-		config.ContainerAuthorizedKeysPath,
-		config.SSHPortInContainer, g.Shell, customCmd.String())
-	ep = append(ep, cmd)
 
 	logrus.WithField("entrypoint", ep).Debug("generate entrypoint")
 	return ep, nil
@@ -241,6 +234,15 @@ func (g Graph) Compile(uid, gid int) (llb.State, error) {
 		"gid": g.gid,
 	}).Debug("compile LLB")
 
+	if err := g.checkSecrets(); err != nil {
+		return llb.State{}, err
+	}
+
+	// Packages declared before the first run or copy are installed in parallel
+	// in the language stage, the rest are compiled in order.
+	leading, ordered := splitOperations(g.buildOperations())
+	g = g.withPackages(leading)
+
 	// TODO(gaocegege): Support more OS and langs.
 	base, err := g.compileBase()
 	if err != nil {
@@ -275,13 +277,10 @@ func (g Graph) Compile(uid, gid int) (llb.State, error) {
 	}
 
 	prompt := g.compilePrompt(merged)
-	copy := g.compileCopy(prompt)
-	// TODO(gaocegege): Support order-based exec.
-	run := g.compileRun(copy)
-	finalStage, err := g.compileGit(run)
+	orderedStage := g.compileOperations(prompt, ordered)
+	finalStage, err := g.compileGit(orderedStage)
 	if err != nil {
 		return llb.State{}, errors.Wrap(err, "failed to compile git")
 	}
-	g.Writer.Finish()
 	return finalStage, nil
 }
@@ -60,33 +60,32 @@ func (g Graph) compileCondaPackages(root llb.State) llb.State {
 		0755, llb.WithParents(true), llb.WithUIDGID(g.uid, g.gid)),
 		llb.WithCustomName("[internal] setting conda cache mount permissions"))
 
-	// Compose the package install command.
-	var sb strings.Builder
-	if len(g.CondaConfig.AdditionalChannels) == 0 {
-		sb.WriteString("/opt/conda/bin/conda install -n envd")
-
-	} else {
-		sb.WriteString("/opt/conda/bin/conda install -n envd")
-		for _, channel := range g.CondaConfig.AdditionalChannels {
-			sb.WriteString(fmt.Sprintf(" -c %s", channel))
-		}
-	}
-
-	for _, pkg := range g.CondaConfig.CondaPackages {
-		sb.WriteString(fmt.Sprintf(" %s", pkg))
-	}
-
-	cmd := sb.String()
+	cmd := g.condaInstallCommand()
 	root = llb.User("envd")(root)
 
-	run := root.
-		Run(llb.Shlex(cmd), llb.WithCustomNamef("conda install %s",
-			strings.Join(g.CondaPackages, " ")))
+	opts := append([]llb.RunOption{
+		llb.Shlex(cmd),
+		llb.WithCustomNamef("conda install %s", strings.Join(g.CondaPackages, " ")),
+	}, g.packageSecrets()...)
+	run := root.Run(opts...)
 	run.AddMount(cacheDir, cache,
 		llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared), llb.SourcePath("/cache-conda"))
 	return run.Root()
 }
 
+// condaInstallCommand returns the command to install the conda packages.
+func (g Graph) condaInstallCommand() string {
+	var sb strings.Builder
+	sb.WriteString("/opt/conda/bin/conda install -n envd")
+	for _, channel := range g.CondaConfig.AdditionalChannels {
+		sb.WriteString(fmt.Sprintf(" -c %s", channel))
+	}
+	for _, pkg := range g.pinnedCondaPackages() {
+		sb.WriteString(fmt.Sprintf(" %s", pkg))
+	}
+	return sb.String()
+}
+
 func (g Graph) compileCondaEnvironment(root llb.State) (llb.State, error) {
 	root = llb.User("envd")(root)
 
@@ -133,7 +132,13 @@ func (g Graph) compileCondaEnvironment(root llb.State) (llb.State, error) {
 }
 
 func (g Graph) installConda(root llb.State) (llb.State, error) {
+	arch, ok := condaArchs[g.targetPlatform().Architecture]
+	if !ok {
+		return llb.State{}, errors.Newf("conda is not available for %s", g.targetPlatform().Architecture)
+	}
+	// The installer is picked by the target platform rather than `uname -m`.
 	run := root.AddEnv("CONDA_VERSION", condaVersionDefault).
+		AddEnv("CONDA_ARCH", arch).
 		File(llb.Mkdir("/opt/conda", 0755, llb.WithParents(true)),
 			llb.WithCustomName("[internal] create conda directory")).
 		Run(llb.Shlex(fmt.Sprintf("bash -c '%s'", installCondaBash)),
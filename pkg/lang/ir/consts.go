@@ -24,6 +24,9 @@ const (
 	defaultConfigDir   = "/home/envd/.config"
 	starshipConfigPath = "/home/envd/.config/starship.toml"
 
+	// runPath is the PATH of the commands declared by `run`.
+	runPath = "$PATH:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/opt/conda/bin:/usr/local/julia/bin:/opt/conda/envs/envd/bin"
+
 	aptSourceFilePath = "/etc/apt/sources.list"
 	pypiIndexFilePath = "/etc/pip.conf"
 
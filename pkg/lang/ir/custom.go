@@ -51,9 +51,11 @@ func (g Graph) compileCustomPyPIPackages(root llb.State) llb.State {
 	// Refer to https://github.com/moby/buildkit/blob/31054718bf775bf32d1376fe1f3611985f837584/frontend/dockerfile/dockerfile2llb/convert_runmount.go#L46
 	cache := root.File(llb.Mkdir("/cache", 0755, llb.WithParents(true)),
 		llb.WithCustomName("[internal] settings pip cache mount permissions"))
-	run := root.
-		Run(llb.Shlex(cmd), llb.WithCustomNamef("pip install %s",
-			strings.Join(g.PyPIPackages, " ")))
+	opts := append([]llb.RunOption{
+		llb.Shlex(cmd),
+		llb.WithCustomNamef("pip install %s", strings.Join(g.PyPIPackages, " ")),
+	}, g.packageSecrets()...)
+	run := root.Run(opts...)
 	run.AddMount(cacheDir, cache,
 		llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared),
 		llb.SourcePath("/cache"))
@@ -65,20 +67,14 @@ func (g Graph) compileCustomSystemPackages(root llb.State) llb.State {
 		return root
 	}
 
-	// Compose the package install command.
-	var sb strings.Builder
-	sb.WriteString("apt-get update && apt-get install -y --no-install-recommends")
-
-	for _, pkg := range g.SystemPackages {
-		sb.WriteString(fmt.Sprintf(" %s", pkg))
-	}
-
 	cacheDir := "/var/cache/apt"
 	cacheLibDir := "/var/lib/apt"
 
-	run := root.Run(llb.Shlex(fmt.Sprintf("bash -c \"%s\"", sb.String())),
-		llb.WithCustomNamef("apt-get install %s",
-			strings.Join(g.SystemPackages, " ")))
+	opts := append([]llb.RunOption{
+		llb.Shlex(fmt.Sprintf("bash -c \"%s\"", aptInstallCommand(g.SystemPackages, false))),
+		llb.WithCustomNamef("apt-get install %s", strings.Join(g.SystemPackages, " ")),
+	}, g.packageSecrets()...)
+	run := root.Run(opts...)
 	run.AddMount(cacheDir, llb.Scratch(),
 		llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared))
 	run.AddMount(cacheLibDir, llb.Scratch(),
@@ -0,0 +1,96 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/editor/devcontainer"
+)
+
+// DevContainer generates the dev container config from the default graph.
+func DevContainer(buildContextDir, image string) devcontainer.Config {
+	return DefaultGraph.DevContainer(buildContextDir, image)
+}
+
+// DevContainer generates the dev container config, which runs the image
+// built by envd in VS Code.
+func (g Graph) DevContainer(buildContextDir, image string) devcontainer.Config {
+	g.EnvironmentName = filepath.Base(buildContextDir)
+	workingDir := g.getWorkingDir()
+	c := devcontainer.Config{
+		Name:            g.EnvironmentName,
+		Image:           image,
+		WorkspaceFolder: workingDir,
+		WorkspaceMount: devcontainer.Mount{
+			Source: "${localWorkspaceFolder}",
+			Target: workingDir,
+			Type:   "bind",
+		}.String(),
+	}
+	if g.Image == nil {
+		// Keep the entrypoint, which starts envd-ssh and the daemons.
+		overrideCommand := false
+		c.RemoteUser = "envd"
+		c.OverrideCommand = &overrideCommand
+	}
+
+	if len(g.VSCodePlugins) != 0 {
+		extensions := []string{}
+		for _, p := range g.VSCodePlugins {
+			e := p.Publisher + "." + p.Extension
+			if p.Version != nil {
+				e += "@" + *p.Version
+			}
+			extensions = append(extensions, e)
+		}
+		c.Customizations = &devcontainer.Customizations{
+			VSCode: &devcontainer.VSCodeCustomizations{Extensions: extensions},
+		}
+	}
+	for _, item := range g.RuntimeExpose {
+		c.ForwardPorts = append(c.ForwardPorts, devcontainer.Port{Port: item.EnvdPort})
+	}
+	for _, m := range g.Mount {
+		if m.DataSource != nil {
+			logrus.Warnf("the %s mounted to %s is fetched by envd up, it is not mounted in devcontainer.json",
+				m.DataSource.Type(), m.Destination)
+			continue
+		}
+		mount := devcontainer.Mount{
+			Source:   m.Source,
+			Target:   m.Destination,
+			Type:     string(MountTypeBind),
+			ReadOnly: m.ReadOnly,
+		}
+		if m.IsVolume() {
+			mount.Type = string(MountTypeVolume)
+		}
+		if m.CopyOnWrite {
+			logrus.Warnf("copy-on-write mounts are not supported in devcontainer.json, mounting %s read-only", m.Source)
+			mount.ReadOnly = true
+		}
+		c.Mounts = append(c.Mounts, mount)
+	}
+	if len(g.RuntimeEnviron) != 0 {
+		c.ContainerEnv = make(map[string]string)
+		for k, v := range g.RuntimeEnviron {
+			c.ContainerEnv[k] = v
+		}
+	}
+	return c
+}
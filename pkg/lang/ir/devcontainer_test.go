@@ -0,0 +1,51 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"testing"
+
+	"github.com/tensorchord/envd/pkg/editor/vscode"
+)
+
+func TestDevContainer(t *testing.T) {
+	g := NewGraph()
+	version := "2022.8.0"
+	g.VSCodePlugins = []vscode.Plugin{{Publisher: "ms-python", Extension: "python", Version: &version}}
+	g.RuntimeExpose = []ExposeItem{{EnvdPort: 8000, HostPort: 8000}}
+	g.Mount = []MountInfo{
+		{Source: "/data", Destination: "/home/envd/data"},
+		{Source: "/models", Destination: "/home/envd/models", CopyOnWrite: true},
+	}
+	g.RuntimeEnviron["A"] = "b"
+
+	c := g.DevContainer("/home/user/mnist", "mnist:dev")
+	if c.Image != "mnist:dev" || c.RemoteUser != "envd" || c.WorkspaceFolder != "/home/envd/mnist" {
+		t.Errorf("unexpected dev container config %+v", c)
+	}
+	if e := c.Extensions(); len(e) != 1 || e[0] != "ms-python.python@2022.8.0" {
+		t.Errorf("expected the extension ms-python.python@2022.8.0, got %v", e)
+	}
+	if len(c.ForwardPorts) != 1 || c.ForwardPorts[0].Port != 8000 {
+		t.Errorf("expected the forwarded port 8000, got %v", c.ForwardPorts)
+	}
+	if len(c.Mounts) != 2 || c.Mounts[0].String() != "source=/data,target=/home/envd/data,type=bind" ||
+		c.Mounts[1].String() != "source=/models,target=/home/envd/models,type=bind,readonly" {
+		t.Errorf("unexpected mounts %v", c.Mounts)
+	}
+	if c.ContainerEnv["A"] != "b" {
+		t.Errorf("expected the environment variable A=b, got %v", c.ContainerEnv)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+// ruleBase is the pseudo rule of the base image, the language and the
+// mirrors, whose change invalidates all the steps.
+const ruleBase = "base"
+
+var operationRules = map[OperationKind]string{
+	OperationKindSystemPackage: "install.system_packages",
+	OperationKindPyPIPackage:   "install.python_packages",
+	OperationKindCondaPackage:  "install.conda_packages",
+	OperationKindRPackage:      "install.r_packages",
+	OperationKindJuliaPackage:  "install.julia_packages",
+	OperationKindCopy:          "io.copy",
+	OperationKindRun:           "run",
+}
+
+// Rule returns the name of the build.envd rule of the operation.
+func (o Operation) Rule() string {
+	return operationRules[o.Kind]
+}
+
+// RuleDigests returns the digests of the base and the operations in the
+// declared order. They are saved in the image labels, thus the next build
+// of the same tag knows which rule invalidates the cache.
+func (g Graph) RuleDigests() []types.RuleDigest {
+	base := struct {
+		OS                 string
+		Language           Language
+		Image              *string
+		CUDA               *string
+		CUDNN              *string
+		UbuntuAPTSource    *string
+		CRANMirrorURL      *string
+		JuliaPackageServer *string
+		PyPIIndexURL       *string
+		PyPIExtraIndexURL  *string
+	}{
+		g.OS, g.Language, g.Image, g.CUDA, g.CUDNN, g.UbuntuAPTSource,
+		g.CRANMirrorURL, g.JuliaPackageServer, g.PyPIIndexURL, g.PyPIExtraIndexURL,
+	}
+	digests := []types.RuleDigest{{Rule: ruleBase, Digest: digestOf(base)}}
+	for _, op := range g.operations() {
+		digests = append(digests, types.RuleDigest{
+			Rule:   op.Rule(),
+			Digest: digestOf(op),
+		})
+	}
+	return digests
+}
+
+func digestOf(v interface{}) string {
+	// The values are plain structs of strings, which never fail to marshal.
+	data, _ := json.Marshal(v)
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// RuleDigests returns the rule digests of the default graph.
+func RuleDigests() []types.RuleDigest {
+	return DefaultGraph.RuleDigests()
+}
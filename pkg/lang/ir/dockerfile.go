@@ -0,0 +1,412 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/config"
+)
+
+const (
+	dockerfileUIDArg  = "ENVD_UID"
+	dockerfileGIDArg  = "ENVD_GID"
+	dockerfileKeysArg = "ENVD_AUTHORIZED_KEYS"
+)
+
+// dockerfile writes the instructions of a Dockerfile.
+type dockerfile struct {
+	sb       strings.Builder
+	warnings int
+}
+
+func (d *dockerfile) line(format string, args ...interface{}) {
+	d.sb.WriteString(fmt.Sprintf(format, args...))
+	d.sb.WriteString("\n")
+}
+
+func (d *dockerfile) comment(format string, args ...interface{}) {
+	d.line("# "+format, args...)
+}
+
+// warn records the steps which cannot be translated to the Dockerfile.
+func (d *dockerfile) warn(format string, args ...interface{}) {
+	d.warnings++
+	d.comment("WARNING: "+format, args...)
+}
+
+func (d *dockerfile) stage(image, name string) {
+	if d.sb.Len() != 0 {
+		d.line("")
+	}
+	d.line("FROM %s AS %s", image, name)
+}
+
+// run uses the shell form if possible, since it is easier to read.
+func (d *dockerfile) run(cmd string) {
+	if strings.Contains(cmd, "\n") {
+		d.bash(cmd)
+		return
+	}
+	d.line("RUN %s", cmd)
+}
+
+// bash runs the script with bash in the exec form. The arguments are
+// available as $1, $2, ... in the script.
+func (d *dockerfile) bash(script string, args ...string) {
+	cmd := []string{"bash", "-c", script}
+	if len(args) != 0 {
+		cmd = append(cmd, "bash")
+		cmd = append(cmd, args...)
+	}
+	d.line("RUN %s", execForm(cmd))
+}
+
+// writeFile writes the content to the file in the image. The content is
+// passed as an argument to avoid quoting it in the shell.
+func (d *dockerfile) writeFile(file, content string) {
+	d.bash(`mkdir -p "$(dirname "$1")" && printf '%s' "$2" > "$1"`, file, content)
+}
+
+func (d *dockerfile) String() string {
+	return d.sb.String()
+}
+
+func execForm(args []string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Encoding a string slice never fails.
+	_ = enc.Encode(args)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Dockerfile generates a standalone Dockerfile from the default graph.
+func Dockerfile(buildContextDir string) (string, error) {
+	return DefaultGraph.Dockerfile(buildContextDir)
+}
+
+// Dockerfile generates a standalone Dockerfile which builds the same image
+// as Compile. The steps relying on the envd build cache or the local files
+// out of the build context are kept as warning comments.
+func (g Graph) Dockerfile(buildContextDir string) (string, error) {
+	g.EnvironmentName = filepath.Base(buildContextDir)
+	// The labels are generated from the declared packages.
+	labels, err := g.Labels()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get labels")
+	}
+	leading, ordered := splitOperations(g.buildOperations())
+	g = g.withPackages(leading)
+	if g.Image == nil {
+		if err := g.compileJupyter(); err != nil {
+			return "", errors.Wrap(err, "failed to compile jupyter")
+		}
+	}
+
+	d := &dockerfile{}
+	d.line("# syntax=docker/dockerfile:1")
+	d.comment("Generated by `envd export` from the envd manifest.")
+	d.comment("Build it in the build context: docker build -t %s .", g.EnvironmentName)
+
+	if err := g.dockerfileBase(d); err != nil {
+		return "", err
+	}
+	d.stage("base", "envd")
+	if g.Image == nil {
+		d.line("USER envd")
+		if err := g.dockerfileLanguage(d); err != nil {
+			return "", err
+		}
+		g.dockerfilePrompt(d)
+	} else {
+		g.dockerfilePackages(d)
+	}
+	g.dockerfileOperations(d, ordered)
+	if g.GitConfig != nil {
+		d.writeFile("/home/envd/.gitconfig", fmt.Sprintf(templateGitConfig,
+			g.GitConfig.Email, g.GitConfig.Name, g.GitConfig.Editor))
+	}
+	if err := g.dockerfileConfig(d, buildContextDir, labels); err != nil {
+		return "", err
+	}
+
+	logrus.WithField("warnings", d.warnings).Debug("generate dockerfile")
+	return d.String(), nil
+}
+
+// dockerfileBase writes the base stage, which is built as root.
+func (g Graph) dockerfileBase(d *dockerfile) error {
+	d.stage(g.baseImage(), "base")
+	if g.Image == nil {
+		uid := 1000
+		if g.CUDA == nil && g.CUDNN == nil && g.Language.Name == "r" {
+			// r-base image already has UID 1000.
+			uid = 1001
+		}
+		d.line("ARG %s=%d", dockerfileUIDArg, uid)
+		d.line("ARG %s=%d", dockerfileGIDArg, 1001)
+		d.line("ENV CONDA_VERSION=%s", condaVersionDefault)
+		d.run("mkdir -p /opt/conda")
+		d.bash(installCondaBash)
+		d.run(fmt.Sprintf("groupadd -g ${%s} envd && "+
+			"useradd -p \"\" -u ${%s} -g envd -s /bin/sh -m envd && "+
+			"adduser envd sudo && "+
+			"chown -R envd:envd /usr/local/lib && "+
+			"chown -R envd:envd /opt/conda", dockerfileGIDArg, dockerfileUIDArg))
+		for _, c := range g.usersCommands() {
+			d.run(c)
+		}
+	}
+	if g.UbuntuAPTSource != nil {
+		d.writeFile(aptSourceFilePath, *g.UbuntuAPTSource)
+	}
+	if g.PyPIIndexURL != nil && (g.Image != nil || g.Language.Name == "python") {
+		d.writeFile(pypiIndexFilePath, g.pypiConfig())
+	}
+	if g.Image != nil {
+		return nil
+	}
+	if constraints, ok := g.pipConstraints(); ok && g.Language.Name == "python" {
+		d.writeFile(pipConstraintsFilePath, constraints)
+	}
+
+	d.comment("envd injects the public key of the host, pass it with")
+	d.comment("--build-arg %s=\"$(cat <public key>)\" to use envd-ssh.", dockerfileKeysArg)
+	d.line("ARG %s", dockerfileKeysArg)
+	// Only root can modify the keys if envd-ssh runs the sessions as the users.
+	owner := "envd:envd"
+	if len(g.Users) != 0 {
+		owner = "root:root"
+	}
+	d.run(fmt.Sprintf("mkdir -p /var/envd && "+
+		"if [ -n \"${%s}\" ]; then echo \"${%s} envd\" > %s; fi && "+
+		"chown -R %s /var/envd",
+		dockerfileKeysArg, dockerfileKeysArg, config.ContainerAuthorizedKeysPath, owner))
+	return nil
+}
+
+// dockerfileLanguage writes the language stage of the envd images.
+func (g Graph) dockerfileLanguage(d *dockerfile) error {
+	if g.Shell == shellZSH {
+		d.warn("oh-my-zsh is installed from the envd cache, it is not translated.")
+	}
+	if g.Language.Name == "python" {
+		if g.CondaConfig != nil && g.CondaConfig.CondaChannel != nil {
+			d.writeFile(condarc, *g.CondaConfig.CondaChannel)
+		}
+		pythonVersion, err := g.getAppropriatePythonVersion()
+		if err != nil {
+			return errors.Wrap(err, "failed to get python version")
+		}
+		d.warn("the conda cache mount /opt/conda/pkgs is not translated.")
+		d.run("/opt/conda/bin/conda init bash")
+		d.run(fmt.Sprintf("/opt/conda/bin/conda create -n envd python=%s", pythonVersion))
+		switch g.Shell {
+		case shellBASH:
+			d.run(`echo "source /opt/conda/bin/activate envd" >> /home/envd/.bashrc`)
+		case shellZSH:
+			d.run(fmt.Sprintf("/opt/conda/bin/conda init %s", g.Shell))
+			d.run(`echo "source /opt/conda/bin/activate envd" >> /home/envd/.zshrc`)
+		}
+	}
+
+	g.dockerfilePackages(d)
+
+	for _, p := range g.VSCodePlugins {
+		d.warn("the VS Code extension %s is installed from the envd cache, it is not translated.", p)
+	}
+	if g.Language.Name == "python" {
+		// Set the system default python to envd's python.
+		envdPrefix := "/opt/conda/envs/envd/bin"
+		d.line("USER root")
+		for _, bin := range []string{"python", "python3", "pip", "pip3"} {
+			d.run(fmt.Sprintf("update-alternatives --install /usr/bin/%s %s %s/%s 1",
+				bin, bin, envdPrefix, bin))
+		}
+		d.line("USER envd")
+	}
+	return nil
+}
+
+// dockerfilePackages installs the packages in the graph one by one.
+func (g Graph) dockerfilePackages(d *dockerfile) {
+	if len(g.SystemPackages) != 0 {
+		d.warn("the apt cache mounts /var/cache/apt and /var/lib/apt are not translated.")
+		d.run(aptInstallCommand(g.SystemPackages, g.Image == nil))
+	}
+	if g.Image != nil {
+		if len(g.PyPIPackages) != 0 {
+			d.warn("the pip cache mount /home/root/.cache is not translated.")
+			d.run("pip install " + strings.Join(g.PyPIPackages, " "))
+		}
+		return
+	}
+
+	switch g.Language.Name {
+	case "python":
+		if g.CondaEnabled() && len(g.CondaConfig.CondaPackages) != 0 {
+			d.run(g.condaInstallCommand())
+		}
+		if len(g.PyPIPackages) == 0 && g.RequirementsFile == nil {
+			return
+		}
+		d.warn("the pip cache mount /home/envd/.cache is not translated.")
+		_, constraints := g.pipConstraints()
+		if len(g.PyPIPackages) != 0 {
+			d.run(pipInstallCommand(constraints, g.PyPIPackages...))
+		}
+		if g.RequirementsFile != nil {
+			requirements := path.Join(g.getWorkingDir(), *g.RequirementsFile)
+			d.line("COPY --chown=envd:envd %s %s", *g.RequirementsFile, requirements)
+			d.run(pipInstallCommand(constraints, "-r", requirements))
+		}
+	case "r":
+		if len(g.RPackages) != 0 {
+			d.run(g.rInstallCommand())
+		}
+	case "julia":
+		if len(g.JuliaPackages) != 0 {
+			if g.JuliaPackageServer != nil {
+				d.line("ENV JULIA_PKG_SERVER=%s", strconv.Quote(*g.JuliaPackageServer))
+			}
+			d.run(g.juliaInstallCommand())
+		}
+	}
+}
+
+func (g Graph) dockerfilePrompt(d *dockerfile) {
+	d.writeFile(starshipConfigPath, starshipConfig)
+	d.run(`echo 'eval "$(starship init bash)"' >> /home/envd/.bashrc`)
+	if g.Shell == shellZSH {
+		d.run(`echo 'eval "$(starship init zsh)"' >> /home/envd/.zshrc`)
+	}
+}
+
+// dockerfileOperations writes the operations in the declared order.
+func (g Graph) dockerfileOperations(d *dockerfile, ops []Operation) {
+	chown := ""
+	if g.Image == nil {
+		chown = "--chown=envd:envd "
+	}
+	pathSet, contextCopied := false, false
+	for _, group := range groupOperations(ops) {
+		switch group[0].Kind {
+		case OperationKindCopy:
+			d.line("COPY %s%s %s", chown, group[0].Copy.Source, group[0].Copy.Destination)
+		case OperationKindRun:
+			commands := []string{}
+			for _, op := range group {
+				commands = append(commands, op.Commands...)
+			}
+			if !pathSet {
+				d.line("ENV PATH=%s", runPath)
+				pathSet = true
+			}
+			if len(commands) == 1 {
+				d.bash(commands[0])
+				continue
+			}
+			if !contextCopied {
+				d.comment("envd mounts the build context for the commands, it is copied here.")
+				d.line("COPY %s. %s", chown, g.getWorkingDir())
+				d.line("WORKDIR %s", g.getWorkingDir())
+				contextCopied = true
+			}
+			d.bash("set -euo pipefail\n" + strings.Join(commands, "\n") + "\n")
+		default:
+			g.withPackages(group).dockerfilePackages(d)
+		}
+	}
+}
+
+// dockerfileConfig writes the image config, e.g. ports and entrypoint.
+func (g Graph) dockerfileConfig(d *dockerfile, buildContextDir string, labels map[string]string) error {
+	ports, err := g.ExposedPorts()
+	if err != nil {
+		return errors.Wrap(err, "failed to get exposed ports")
+	}
+	exposed := []string{}
+	for port := range ports {
+		exposed = append(exposed, port)
+	}
+	sort.Strings(exposed)
+	for _, port := range exposed {
+		d.line("EXPOSE %s", port)
+	}
+	for _, k := range sortedKeys(g.RuntimeEnviron) {
+		d.line("ENV %s=%s", k, strconv.Quote(g.RuntimeEnviron[k]))
+	}
+	for _, k := range sortedKeys(labels) {
+		d.line("LABEL %s=%s", k, strconv.Quote(labels[k]))
+	}
+	for _, m := range g.Mount {
+		if m.DataSource != nil {
+			d.warn("the %s mounted to %s is fetched by envd up, mount its dir with `docker run -v <dir>:%s`.",
+				m.DataSource.Type(), m.Destination, m.Destination)
+			continue
+		}
+		if m.CopyOnWrite {
+			d.warn("%s is mounted copy-on-write to %s at runtime, which docker run does not support, use `docker run -v %s:%s:ro` to protect it.",
+				m.Source, m.Destination, m.Source, m.Destination)
+			continue
+		}
+		suffix := ""
+		if m.ReadOnly {
+			suffix = ":ro"
+		}
+		d.warn("%s is mounted to %s at runtime, use `docker run -v %s:%s%s`.",
+			m.Source, m.Destination, m.Source, m.Destination, suffix)
+	}
+	if len(g.Secrets) != 0 {
+		d.warn("the secrets %s are not mounted, add `--mount=type=secret,id=<id>` to the RUN instructions which need them.",
+			strings.Join(g.SecretIDs(), ", "))
+	}
+	if g.SSHRequired() {
+		d.warn("the ssh agent is not mounted, add `--mount=type=ssh` to the RUN instructions which need it.")
+	}
+	if len(g.Users) != 0 {
+		d.warn("envd-ssh runs the sessions as %s only if it is root, use `docker run --user root`.",
+			strings.Join(g.Users, ", "))
+	}
+
+	ep, err := g.GetEntrypoint(buildContextDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the entrypoint")
+	}
+	if len(ep) != 0 {
+		d.line("ENTRYPOINT %s", execForm(ep))
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tensorchord/envd/pkg/editor/vscode"
+)
+
+func TestDockerfilePython(t *testing.T) {
+	g := NewGraph()
+	g.SystemPackages = []string{"curl"}
+	g.PyPIPackages = []string{"numpy"}
+	g.VSCodePlugins = []vscode.Plugin{{Publisher: "ms-python", Extension: "python"}}
+	g.JupyterConfig = &JupyterConfig{}
+	g.Copy = []CopyInfo{{Source: "data", Destination: "/data"}}
+	g.Exec = []string{"echo 1", "echo 2"}
+	g.Operations = []Operation{
+		{Kind: OperationKindSystemPackage, Packages: []string{"curl"}},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"numpy"}},
+		{Kind: OperationKindCopy, Copy: &g.Copy[0]},
+		{Kind: OperationKindRun, Commands: g.Exec},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"torch"}},
+	}
+	g.OrderedBuild = true
+	g.GitConfig = &GitConfig{Name: "envd", Email: "envd@tensorchord.ai", Editor: "vim"}
+
+	d, err := g.Dockerfile("/home/user/mnist")
+	if err != nil {
+		t.Fatalf("failed to generate the dockerfile: %v", err)
+	}
+	expected := []string{
+		"FROM base AS envd",
+		"RUN /opt/conda/bin/conda create -n envd python=3.9",
+		"RUN sudo apt-get update && sudo apt-get install -y --no-install-recommends curl",
+		"RUN /opt/conda/envs/envd/bin/python -m pip install numpy jupyter",
+		"# WARNING: the VS Code extension ms-python.python",
+		"COPY --chown=envd:envd data /data",
+		"COPY --chown=envd:envd . /home/envd/mnist",
+		`RUN ["bash","-c","set -euo pipefail\necho 1\necho 2\n"]`,
+		"RUN /opt/conda/envs/envd/bin/python -m pip install torch",
+		"/home/envd/.gitconfig",
+		"EXPOSE 2222/tcp",
+		"EXPOSE 8888/tcp",
+		`LABEL ai.tensorchord.envd.pypi.packages="[\"numpy\"]"`,
+		`ENTRYPOINT ["tini","--","/var/envd/bin/envd-ssh","supervise","--spec-json",`,
+	}
+	last := 0
+	for _, e := range expected {
+		i := strings.Index(d[last:], e)
+		if i < 0 {
+			t.Fatalf("expected %q after offset %d in the dockerfile:\n%s", e, last, d)
+		}
+		last += i
+	}
+}
+
+func TestDockerfileCustomImage(t *testing.T) {
+	g := NewGraph()
+	image := "ubuntu:22.04"
+	g.Image = &image
+	g.SystemPackages = []string{"curl"}
+	g.Entrypoint = []string{"sleep", "infinity"}
+
+	d, err := g.Dockerfile("/home/user/custom")
+	if err != nil {
+		t.Fatalf("failed to generate the dockerfile: %v", err)
+	}
+	for _, e := range []string{
+		"FROM ubuntu:22.04 AS base",
+		"RUN apt-get update && apt-get install -y --no-install-recommends curl",
+		`ENTRYPOINT ["sleep","infinity"]`,
+	} {
+		if !strings.Contains(d, e) {
+			t.Errorf("expected %q in the dockerfile:\n%s", e, d)
+		}
+	}
+	if strings.Contains(d, "useradd") || strings.Contains(d, "EXPOSE") {
+		t.Errorf("expected no envd user or ports for the custom image:\n%s", d)
+	}
+}
@@ -1,5 +1,5 @@
 set -x && \
-UNAME_M="$(uname -m)" && \
+UNAME_M="${CONDA_ARCH:-$(uname -m)}" && \
 if [ "${UNAME_M}" = "x86_64" ]; then \
 	MINICONDA_URL="https://repo.anaconda.com/miniconda/Miniconda3-${CONDA_VERSION}-Linux-x86_64.sh"; \
 	SHA256SUM="4ee9c3aa53329cd7a63b49877c0babb49b19b7e5af29807b793a76bdb1d362b4"; \
@@ -40,23 +40,44 @@ func Base(os, language, image string) error {
 func PyPIPackage(deps []string, requirementsFile string) error {
 	DefaultGraph.PyPIPackages = append(DefaultGraph.PyPIPackages, deps...)
 
+	op := Operation{
+		Kind:     OperationKindPyPIPackage,
+		Packages: deps,
+	}
 	if requirementsFile != "" {
 		DefaultGraph.RequirementsFile = &requirementsFile
+		op.RequirementsFile = &requirementsFile
 	}
-
+	DefaultGraph.Operations = append(DefaultGraph.Operations, op)
 	return nil
 }
 
 func RPackage(deps []string) {
 	DefaultGraph.RPackages = append(DefaultGraph.RPackages, deps...)
+	DefaultGraph.Operations = append(DefaultGraph.Operations, Operation{
+		Kind:     OperationKindRPackage,
+		Packages: deps,
+	})
 }
 
 func JuliaPackage(deps []string) {
 	DefaultGraph.JuliaPackages = append(DefaultGraph.JuliaPackages, deps...)
+	DefaultGraph.Operations = append(DefaultGraph.Operations, Operation{
+		Kind:     OperationKindJuliaPackage,
+		Packages: deps,
+	})
 }
 
 func SystemPackage(deps []string) {
 	DefaultGraph.SystemPackages = append(DefaultGraph.SystemPackages, deps...)
+	DefaultGraph.Operations = append(DefaultGraph.Operations, Operation{
+		Kind:     OperationKindSystemPackage,
+		Packages: deps,
+	})
+}
+
+func OrderedBuild() {
+	DefaultGraph.OrderedBuild = true
 }
 
 func GPU(numGPUs int) {
@@ -137,9 +158,54 @@ func RStudioServer() error {
 	return nil
 }
 
-func Run(commands []string) error {
-	// TODO(gaocegege): Support order-based exec.
+// Users creates the users in the image, thus the keys annotated with them
+// in the authorized_keys file run the sessions as them.
+func Users(names []string) error {
+	for _, name := range names {
+		if !userNameRegexp.MatchString(name) {
+			return errors.Newf("invalid user name %s", name)
+		}
+		if reservedUserNames[name] {
+			return errors.Newf("the user name %s is reserved", name)
+		}
+		for _, u := range DefaultGraph.Users {
+			if u == name {
+				return errors.Newf("the user %s is declared more than once", name)
+			}
+		}
+		DefaultGraph.Users = append(DefaultGraph.Users, name)
+	}
+	return nil
+}
+
+func Run(commands []string, secrets []string, ssh bool) error {
 	DefaultGraph.Exec = append(DefaultGraph.Exec, commands...)
+	DefaultGraph.Operations = append(DefaultGraph.Operations, Operation{
+		Kind:     OperationKindRun,
+		Commands: commands,
+		Secrets:  secrets,
+		SSH:      ssh,
+	})
+	return nil
+}
+
+func Secret(id, target, env string) error {
+	if id == "" {
+		return errors.New("secret id is required")
+	}
+	for _, s := range DefaultGraph.Secrets {
+		if s.ID == id {
+			return errors.Newf("secret %s is declared more than once", id)
+		}
+	}
+	if target == "" && env == "" {
+		target = defaultSecretTarget(id)
+	}
+	DefaultGraph.Secrets = append(DefaultGraph.Secrets, SecretInfo{
+		ID:     id,
+		Target: target,
+		Env:    env,
+	})
 	return nil
 }
 
@@ -171,7 +237,12 @@ func CondaPackage(deps []string, channel []string, envFile *string) error {
 	}
 	DefaultGraph.CondaConfig.CondaPackages = append(
 		DefaultGraph.CondaConfig.CondaPackages, deps...)
+	condaOp := Operation{
+		Kind:     OperationKindCondaPackage,
+		Packages: deps,
+	}
 
+	var pipOp *Operation
 	if envFile != nil {
 		parsed, err := parser.ParseCondaEnvYaml(*envFile)
 		if err != nil {
@@ -179,6 +250,17 @@ func CondaPackage(deps []string, channel []string, envFile *string) error {
 		}
 		DefaultGraph.CondaConfig.CondaPackages = append(DefaultGraph.CondaConfig.CondaPackages, parsed.CondaPackages...)
 		DefaultGraph.PyPIPackages = append(DefaultGraph.PyPIPackages, parsed.PipPackages...)
+		condaOp.Packages = append(condaOp.Packages, parsed.CondaPackages...)
+		if len(parsed.PipPackages) != 0 {
+			pipOp = &Operation{
+				Kind:     OperationKindPyPIPackage,
+				Packages: parsed.PipPackages,
+			}
+		}
+	}
+	DefaultGraph.Operations = append(DefaultGraph.Operations, condaOp)
+	if pipOp != nil {
+		DefaultGraph.Operations = append(DefaultGraph.Operations, *pipOp)
 	}
 	if len(channel) != 0 {
 		DefaultGraph.CondaConfig.AdditionalChannels = append(
@@ -188,17 +270,23 @@ func CondaPackage(deps []string, channel []string, envFile *string) error {
 }
 
 func Copy(src, dest string) {
-	DefaultGraph.Copy = append(DefaultGraph.Copy, CopyInfo{
+	info := CopyInfo{
 		Source:      src,
 		Destination: dest,
+	}
+	DefaultGraph.Copy = append(DefaultGraph.Copy, info)
+	DefaultGraph.Operations = append(DefaultGraph.Operations, Operation{
+		Kind: OperationKindCopy,
+		Copy: &info,
 	})
 }
 
-func Mount(src, dest string) {
-	DefaultGraph.Mount = append(DefaultGraph.Mount, MountInfo{
-		Source:      src,
-		Destination: dest,
-	})
+func Mount(m MountInfo) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	DefaultGraph.Mount = append(DefaultGraph.Mount, m)
+	return nil
 }
 
 func Entrypoint(args []string) {
@@ -211,8 +299,11 @@ func RuntimeCommands(commands map[string]string) {
 	}
 }
 
-func RuntimeDaemon(commands [][]string) {
-	DefaultGraph.RuntimeDaemon = append(DefaultGraph.RuntimeDaemon, commands...)
+func RuntimeDaemon(daemons []DaemonConfig) {
+	for _, d := range daemons {
+		d.Name = daemonName(d.Commands, DefaultGraph.RuntimeDaemon)
+		DefaultGraph.RuntimeDaemon = append(DefaultGraph.RuntimeDaemon, d)
+	}
 }
 
 func RuntimeExpose(envdPort, hostPort int, serviceName string) error {
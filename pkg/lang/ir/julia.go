@@ -21,6 +21,8 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/moby/buildkit/client/llb"
 	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/lockfile"
 )
 
 func (g Graph) compileJulia(aptStage llb.State) (llb.State, error) {
@@ -73,20 +75,8 @@ func (g Graph) installJuliaPackages(root llb.State) llb.State {
 		return root
 	}
 
-	var sb strings.Builder
-
-	sb.WriteString(`/usr/local/julia/bin/julia -e 'using Pkg; Pkg.add([`)
-	for i, pkg := range g.JuliaPackages {
-		sb.WriteString(fmt.Sprintf(`"%s"`, pkg))
-		if i != len(g.JuliaPackages)-1 {
-			sb.WriteString(", ")
-		}
-	}
-
-	sb.WriteString(`])'`)
-
 	// TODO(gaocegege): Support cache.
-	cmd := sb.String()
+	cmd := g.juliaInstallCommand()
 	logrus.Debug("install julia packages: ", cmd)
 	root = llb.User("envd")(root)
 	if g.JuliaPackageServer != nil {
@@ -98,3 +88,33 @@ func (g Graph) installJuliaPackages(root llb.State) llb.State {
 
 	return run.Root()
 }
+
+// juliaInstallCommand returns the command to install the julia packages.
+func (g Graph) juliaInstallCommand() string {
+	var sb strings.Builder
+
+	sb.WriteString(`/usr/local/julia/bin/julia -e 'using Pkg; Pkg.add([`)
+	if g.Lockfile != nil && len(g.Lockfile.Julia) != 0 {
+		// Pkg.add does not accept the mixed names and specs.
+		for i, pkg := range g.JuliaPackages {
+			if version, ok := lockfile.Pinned(g.Lockfile.Julia, pkg); ok {
+				sb.WriteString(fmt.Sprintf(`PackageSpec(name="%s", version="%s")`, pkg, version))
+			} else {
+				sb.WriteString(fmt.Sprintf(`PackageSpec(name="%s")`, pkg))
+			}
+			if i != len(g.JuliaPackages)-1 {
+				sb.WriteString(", ")
+			}
+		}
+	} else {
+		for i, pkg := range g.JuliaPackages {
+			sb.WriteString(fmt.Sprintf(`"%s"`, pkg))
+			if i != len(g.JuliaPackages)-1 {
+				sb.WriteString(", ")
+			}
+		}
+	}
+
+	sb.WriteString(`])'`)
+	return sb.String()
+}
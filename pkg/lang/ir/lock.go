@@ -0,0 +1,119 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/tensorchord/envd/pkg/lockfile"
+)
+
+const pipConstraintsFilePath = "/etc/envd/pip-constraints.txt"
+
+// Lockfile sets the lockfile to pin the versions of the packages.
+func Lockfile(f *lockfile.File) {
+	DefaultGraph.Lockfile = f
+}
+
+// Requirements returns the packages declared in the manifest.
+func Requirements(buildContextDir string) (lockfile.Requirements, error) {
+	return DefaultGraph.Requirements(buildContextDir)
+}
+
+func (g Graph) Requirements(buildContextDir string) (lockfile.Requirements, error) {
+	r := lockfile.Requirements{
+		Python: g.PyPIPackages,
+		R:      g.RPackages,
+		Julia:  g.JuliaPackages,
+	}
+	if g.CondaEnabled() {
+		r.Conda = g.CondaConfig.CondaPackages
+	}
+	if g.RequirementsFile != nil {
+		data, err := os.ReadFile(filepath.Join(buildContextDir, *g.RequirementsFile))
+		if err != nil {
+			return r, errors.Wrap(err, "failed to read the requirements file")
+		}
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		r.RequirementsFile = g.RequirementsFile
+		r.Digest = &digest
+	}
+	return r, nil
+}
+
+// compilePipConstraints writes the locked python packages to the image.
+// It returns false if there are no locked python packages.
+func (g Graph) compilePipConstraints(root llb.State) (llb.State, bool) {
+	constraints, ok := g.pipConstraints()
+	if !ok {
+		return root, false
+	}
+	root = root.
+		File(llb.Mkdir(filepath.Dir(pipConstraintsFilePath), 0755, llb.WithParents(true)),
+			llb.WithCustomName("[internal] setting pip constraints")).
+		File(llb.Mkfile(pipConstraintsFilePath, 0644, []byte(constraints)),
+			llb.WithCustomName("[internal] setting pip constraints"))
+	return root, true
+}
+
+// pipConstraints returns the content of the pip constraints file.
+// It returns false if there are no locked python packages.
+func (g Graph) pipConstraints() (string, bool) {
+	if g.Lockfile == nil || len(g.Lockfile.Python) == 0 {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, pkg := range g.Lockfile.Python {
+		sb.WriteString(fmt.Sprintf("%s==%s\n", pkg.Name, pkg.Version))
+	}
+	return sb.String(), true
+}
+
+// pinnedCondaPackages returns the conda packages with the locked versions.
+func (g Graph) pinnedCondaPackages() []string {
+	if g.Lockfile == nil {
+		return g.CondaConfig.CondaPackages
+	}
+	pkgs := []string{}
+	for _, pkg := range g.CondaConfig.CondaPackages {
+		if version, ok := lockfile.Pinned(g.Lockfile.Conda, pkg); ok {
+			pkg = fmt.Sprintf("%s=%s", lockfile.PackageName(pkg), version)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// pinnedPackages returns the packages in the lockfile, and the packages
+// which are not locked.
+func pinnedPackages(locked []lockfile.Package, declared []string) ([]lockfile.Package, []string) {
+	pinned := []lockfile.Package{}
+	unpinned := []string{}
+	for _, pkg := range declared {
+		if version, ok := lockfile.Pinned(locked, pkg); ok {
+			pinned = append(pinned, lockfile.Package{Name: lockfile.PackageName(pkg), Version: version})
+		} else {
+			unpinned = append(unpinned, pkg)
+		}
+	}
+	return pinned, unpinned
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+type MountType string
+
+const (
+	MountTypeBind MountType = "bind"
+	// MountTypeVolume mounts a named docker volume, the source is the name.
+	MountTypeVolume MountType = "volume"
+)
+
+// Mount modes accepted by io.mount and the --volume flag.
+const (
+	MountModeReadWrite   = "rw"
+	MountModeReadOnly    = "ro"
+	MountModeCopyOnWrite = "cow"
+)
+
+// DataSource is the data source of a mount, e.g. data.s3. It is fetched
+// when the environment starts instead of when build.envd is interpreted.
+type DataSource interface {
+	Init() error
+	GetHostDir() (string, error)
+	Type() string
+}
+
+// InitDataSource fetches the data source of the mount, if any, and sets
+// the source to its dir on the host.
+func (m *MountInfo) InitDataSource() error {
+	if m.DataSource == nil {
+		return nil
+	}
+	if err := m.DataSource.Init(); err != nil {
+		return err
+	}
+	dir, err := m.DataSource.GetHostDir()
+	if err != nil {
+		return err
+	}
+	m.Source = dir
+	return nil
+}
+
+// IsVolume returns true if the source is a named docker volume.
+func (m MountInfo) IsVolume() bool {
+	return m.Type == MountTypeVolume
+}
+
+// SetMode sets the options of the mount from the mode.
+func (m *MountInfo) SetMode(mode string) error {
+	switch mode {
+	case "", MountModeReadWrite:
+	case MountModeReadOnly:
+		m.ReadOnly = true
+	case MountModeCopyOnWrite:
+		m.CopyOnWrite = true
+	default:
+		return errors.Newf("invalid mount mode %s, expected one of rw, ro and cow", mode)
+	}
+	return nil
+}
+
+func (m MountInfo) validate() error {
+	if (m.Source == "" && m.DataSource == nil) || m.Destination == "" {
+		return errors.Newf("invalid mount %s:%s, both the source and the destination are required",
+			m.Source, m.Destination)
+	}
+	switch m.Type {
+	case "", MountTypeBind, MountTypeVolume:
+	default:
+		return errors.Newf("invalid mount type %s, expected bind or volume", m.Type)
+	}
+	if m.ReadOnly && m.CopyOnWrite {
+		return errors.New("a mount cannot be both read-only and copy-on-write")
+	}
+	if m.IsVolume() && m.CopyOnWrite {
+		return errors.New("copy-on-write is only supported for bind mounts")
+	}
+	return nil
+}
+
+// ParseVolume parses the --volume flag, `SRC:DEST[:rw|ro|cow]`. As in
+// `docker run -v`, the source is the name of a volume if it is not a path.
+func ParseVolume(s string) (MountInfo, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return MountInfo{}, errors.Newf("invalid volume %s, expected SRC:DEST[:rw|ro|cow]", s)
+	}
+	m := MountInfo{Source: parts[0], Destination: parts[1], Type: MountTypeBind}
+	if !strings.ContainsAny(m.Source, `/\`) &&
+		!strings.HasPrefix(m.Source, ".") && !strings.HasPrefix(m.Source, "~") {
+		m.Type = MountTypeVolume
+	}
+	if len(parts) == 3 {
+		if err := m.SetMode(parts[2]); err != nil {
+			return MountInfo{}, errors.Wrapf(err, "invalid volume %s", s)
+		}
+	}
+	if err := m.validate(); err != nil {
+		return MountInfo{}, errors.Wrapf(err, "invalid volume %s", s)
+	}
+	return m, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import "testing"
+
+func TestParseVolume(t *testing.T) {
+	tcs := []struct {
+		volume   string
+		expected MountInfo
+	}{
+		{"/data:/home/envd/data", MountInfo{Source: "/data", Destination: "/home/envd/data", Type: MountTypeBind}},
+		{"./data:/data:ro", MountInfo{Source: "./data", Destination: "/data", Type: MountTypeBind, ReadOnly: true}},
+		{"~/data:/data:cow", MountInfo{Source: "~/data", Destination: "/data", Type: MountTypeBind, CopyOnWrite: true}},
+		{"cache:/cache:rw", MountInfo{Source: "cache", Destination: "/cache", Type: MountTypeVolume}},
+	}
+	for _, tc := range tcs {
+		m, err := ParseVolume(tc.volume)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", tc.volume, err)
+			continue
+		}
+		if m != tc.expected {
+			t.Errorf("expected %+v for %s, got %+v", tc.expected, tc.volume, m)
+		}
+	}
+
+	for _, volume := range []string{"/data", "/data:/data:rx", "cache:/cache:cow", ":/data", "a:b:ro:rw"} {
+		if _, err := ParseVolume(volume); err == nil {
+			t.Errorf("expected an error for %s", volume)
+		}
+	}
+}
+
+func TestMountValidate(t *testing.T) {
+	m := MountInfo{Source: "/data", Destination: "/data"}
+	if err := m.SetMode(MountModeCopyOnWrite); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.validate(); err != nil {
+		t.Errorf("expected a valid copy-on-write mount, got %v", err)
+	}
+	m.ReadOnly = true
+	if err := m.validate(); err == nil {
+		t.Errorf("expected an error for a read-only copy-on-write mount")
+	}
+	if err := m.SetMode("shared"); err == nil {
+		t.Errorf("expected an error for an invalid mode")
+	}
+	m = MountInfo{Source: "/data", Destination: "/data", Type: "tmpfs"}
+	if err := m.validate(); err == nil {
+		t.Errorf("expected an error for an invalid type")
+	}
+}
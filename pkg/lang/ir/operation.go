@@ -0,0 +1,240 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"github.com/moby/buildkit/client/llb"
+	"github.com/sirupsen/logrus"
+)
+
+// ordered returns true if the operation may depend on the result of
+// the operations declared before it.
+func (o Operation) ordered() bool {
+	return o.Kind == OperationKindRun || o.Kind == OperationKindCopy
+}
+
+// operations returns the ordered operations of the graph. If the graph is
+// not built by the rules (e.g. in tests), the operations are generated from
+// the aggregated fields in the fixed order: packages, copy and run.
+func (g Graph) operations() []Operation {
+	if len(g.Operations) != 0 {
+		return g.Operations
+	}
+	ops := []Operation{}
+	if len(g.SystemPackages) != 0 {
+		ops = append(ops, Operation{Kind: OperationKindSystemPackage, Packages: g.SystemPackages})
+	}
+	if len(g.PyPIPackages) != 0 || g.RequirementsFile != nil {
+		ops = append(ops, Operation{Kind: OperationKindPyPIPackage,
+			Packages: g.PyPIPackages, RequirementsFile: g.RequirementsFile})
+	}
+	if g.CondaEnabled() && len(g.CondaConfig.CondaPackages) != 0 {
+		ops = append(ops, Operation{Kind: OperationKindCondaPackage, Packages: g.CondaConfig.CondaPackages})
+	}
+	if len(g.RPackages) != 0 {
+		ops = append(ops, Operation{Kind: OperationKindRPackage, Packages: g.RPackages})
+	}
+	if len(g.JuliaPackages) != 0 {
+		ops = append(ops, Operation{Kind: OperationKindJuliaPackage, Packages: g.JuliaPackages})
+	}
+	for i := range g.Copy {
+		ops = append(ops, Operation{Kind: OperationKindCopy, Copy: &g.Copy[i]})
+	}
+	if len(g.Exec) != 0 {
+		ops = append(ops, Operation{Kind: OperationKindRun, Commands: g.Exec})
+	}
+	return ops
+}
+
+// buildOperations returns the operations to compile. The declared order is
+// kept only if the ordered build is enabled, thus the existing manifests,
+// e.g. a run declared before the packages it uses, build the same way.
+func (g Graph) buildOperations() []Operation {
+	ops := g.operations()
+	if g.OrderedBuild {
+		return ops
+	}
+	return fixedOrder(ops)
+}
+
+// fixedOrder sorts the operations in the fixed order: packages, copy and run.
+// The operations of the same kind keep the declared order.
+func fixedOrder(ops []Operation) []Operation {
+	packages, copies, runs := []Operation{}, []Operation{}, []Operation{}
+	for _, op := range ops {
+		switch op.Kind {
+		case OperationKindCopy:
+			copies = append(copies, op)
+		case OperationKindRun:
+			runs = append(runs, op)
+		default:
+			packages = append(packages, op)
+		}
+	}
+	return append(append(packages, copies...), runs...)
+}
+
+// splitOperations splits the operations into the package operations declared
+// before the first run or copy, which are installed in parallel in the
+// language stage, and the rest, which are compiled in the declared order.
+func splitOperations(ops []Operation) ([]Operation, []Operation) {
+	for i, op := range ops {
+		if op.ordered() {
+			return ops[:i], ops[i:]
+		}
+	}
+	return ops, nil
+}
+
+// groupOperations groups the consecutive operations which can be compiled
+// together. Consecutive runs are merged into one step, consecutive package
+// operations are installed in parallel and every copy is a step of its own.
+func groupOperations(ops []Operation) [][]Operation {
+	groups := [][]Operation{}
+	for _, op := range ops {
+		n := len(groups)
+		if n != 0 && sameGroup(groups[n-1][0], op) {
+			groups[n-1] = append(groups[n-1], op)
+			continue
+		}
+		groups = append(groups, []Operation{op})
+	}
+	return groups
+}
+
+func sameGroup(a, b Operation) bool {
+	switch {
+	case a.Kind == OperationKindCopy || b.Kind == OperationKindCopy:
+		return false
+	case a.Kind == OperationKindRun || b.Kind == OperationKindRun:
+		// The secrets and the ssh agent are mounted into all the commands
+		// of the step, thus only the runs which mount the same are merged.
+		return a.Kind == b.Kind && a.SSH == b.SSH && sameSecrets(a.Secrets, b.Secrets)
+	default:
+		return true
+	}
+}
+
+// sameSecrets returns true if a and b are the same set of secret IDs.
+func sameSecrets(a, b []string) bool {
+	setA, setB := map[string]bool{}, map[string]bool{}
+	for _, id := range a {
+		setA[id] = true
+	}
+	for _, id := range b {
+		if !setA[id] {
+			return false
+		}
+		setB[id] = true
+	}
+	return len(setA) == len(setB)
+}
+
+// withPackages returns a copy of the graph, whose packages are
+// the ones declared in the given operations.
+func (g Graph) withPackages(ops []Operation) Graph {
+	g.SystemPackages = []string{}
+	g.PyPIPackages = []string{}
+	g.RPackages = []string{}
+	g.JuliaPackages = []string{}
+	g.RequirementsFile = nil
+	if g.CondaConfig != nil {
+		conda := *g.CondaConfig
+		conda.CondaPackages = []string{}
+		g.CondaConfig = &conda
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case OperationKindSystemPackage:
+			g.SystemPackages = append(g.SystemPackages, op.Packages...)
+		case OperationKindPyPIPackage:
+			g.PyPIPackages = append(g.PyPIPackages, op.Packages...)
+			if op.RequirementsFile != nil {
+				g.RequirementsFile = op.RequirementsFile
+			}
+		case OperationKindCondaPackage:
+			if g.CondaConfig == nil {
+				g.CondaConfig = &CondaConfig{}
+			}
+			g.CondaConfig.CondaPackages = append(g.CondaConfig.CondaPackages, op.Packages...)
+		case OperationKindRPackage:
+			g.RPackages = append(g.RPackages, op.Packages...)
+		case OperationKindJuliaPackage:
+			g.JuliaPackages = append(g.JuliaPackages, op.Packages...)
+		}
+	}
+	return g
+}
+
+// compileOperations compiles the operations in the declared order.
+func (g Graph) compileOperations(root llb.State, ops []Operation) llb.State {
+	for _, group := range groupOperations(ops) {
+		switch group[0].Kind {
+		case OperationKindCopy:
+			root = g.compileCopy(root, *group[0].Copy)
+		case OperationKindRun:
+			commands := []string{}
+			for _, op := range group {
+				commands = append(commands, op.Commands...)
+			}
+			root = g.compileRun(root, commands, group[0].Secrets, group[0].SSH)
+		default:
+			root = g.compilePackageOperations(root, group)
+		}
+	}
+	return root
+}
+
+// compilePackageOperations installs the packages declared after a run or copy.
+// Packages of different kinds are still installed in parallel.
+func (g Graph) compilePackageOperations(root llb.State, ops []Operation) llb.State {
+	view := g.withPackages(ops)
+	logrus.WithField("operations", len(ops)).Debug("compile ordered package operations")
+	if g.Image != nil {
+		return view.compileCustomPyPIPackages(view.compileCustomSystemPackages(root))
+	}
+
+	stages := []llb.State{root}
+	if len(view.SystemPackages) != 0 {
+		stages = append(stages, llb.Diff(root, view.compileSystemPackages(root),
+			llb.WithCustomName("install system packages")))
+	}
+	switch g.Language.Name {
+	case "python":
+		if view.CondaEnabled() && len(view.CondaConfig.CondaPackages) != 0 {
+			stages = append(stages, llb.Diff(root, view.compileCondaPackages(root),
+				llb.WithCustomName("install conda packages")))
+		}
+		if len(view.PyPIPackages) != 0 || view.RequirementsFile != nil {
+			stages = append(stages, llb.Diff(root, view.compilePyPIPackages(root),
+				llb.WithCustomName("install PyPI packages")))
+		}
+	case "r":
+		if len(view.RPackages) != 0 {
+			stages = append(stages, llb.Diff(root, view.installRPackages(root),
+				llb.WithCustomName("install R packages")))
+		}
+	case "julia":
+		if len(view.JuliaPackages) != 0 {
+			stages = append(stages, llb.Diff(root, view.installJuliaPackages(root),
+				llb.WithCustomName("install julia packages")))
+		}
+	}
+	if len(stages) == 1 {
+		return root
+	}
+	return llb.Merge(stages, llb.WithCustomName("merging packages into one"))
+}
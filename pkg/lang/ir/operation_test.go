@@ -0,0 +1,247 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/spf13/viper"
+
+	"github.com/tensorchord/envd/pkg/flag"
+)
+
+func TestSplitOperations(t *testing.T) {
+	ops := []Operation{
+		{Kind: OperationKindSystemPackage, Packages: []string{"curl"}},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"numpy"}},
+		{Kind: OperationKindRun, Commands: []string{"echo 1"}},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"torch"}},
+	}
+	leading, ordered := splitOperations(ops)
+	if len(leading) != 2 {
+		t.Errorf("expected 2 leading operations, got %d", len(leading))
+	}
+	if len(ordered) != 2 || ordered[0].Kind != OperationKindRun {
+		t.Errorf("expected the ordered operations to start with run, got %v", ordered)
+	}
+
+	leading, ordered = splitOperations(ops[:2])
+	if len(leading) != 2 || len(ordered) != 0 {
+		t.Errorf("expected no ordered operations, got %v", ordered)
+	}
+}
+
+func TestGroupOperations(t *testing.T) {
+	copyInfo := CopyInfo{Source: "a", Destination: "b"}
+	ops := []Operation{
+		{Kind: OperationKindRun, Commands: []string{"echo 1"}},
+		{Kind: OperationKindRun, Commands: []string{"echo 2"}},
+		{Kind: OperationKindCopy, Copy: &copyInfo},
+		{Kind: OperationKindCopy, Copy: &copyInfo},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"numpy"}},
+		{Kind: OperationKindSystemPackage, Packages: []string{"curl"}},
+		{Kind: OperationKindRun, Commands: []string{"echo 3"}},
+		// Runs with different secrets are not merged.
+		{Kind: OperationKindRun, Commands: []string{"echo 4"}, Secrets: []string{"token"}},
+		{Kind: OperationKindRun, Commands: []string{"echo 5"}, Secrets: []string{"token"}},
+		{Kind: OperationKindRun, Commands: []string{"echo 6"}},
+		// Neither are the runs with different ssh settings.
+		{Kind: OperationKindRun, Commands: []string{"echo 7"}, SSH: true},
+		{Kind: OperationKindRun, Commands: []string{"echo 8"}, SSH: true},
+	}
+	groups := groupOperations(ops)
+	expected := []int{2, 1, 1, 2, 1, 2, 1, 2}
+	if len(groups) != len(expected) {
+		t.Fatalf("expected %d groups, got %d", len(expected), len(groups))
+	}
+	for i, group := range groups {
+		if len(group) != expected[i] {
+			t.Errorf("expected group %d to have %d operations, got %d", i, expected[i], len(group))
+		}
+	}
+}
+
+func TestSameSecrets(t *testing.T) {
+	tcs := []struct {
+		a, b     []string
+		expected bool
+	}{
+		{nil, []string{}, true},
+		{[]string{"a", "b"}, []string{"b", "a"}, true},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a", "b"}, []string{"a"}, false},
+	}
+	for _, tc := range tcs {
+		if sameSecrets(tc.a, tc.b) != tc.expected {
+			t.Errorf("expected sameSecrets(%v, %v) to be %t", tc.a, tc.b, tc.expected)
+		}
+	}
+}
+
+func TestWithPackages(t *testing.T) {
+	requirements := "requirements.txt"
+	g := Graph{
+		PyPIPackages: []string{"numpy", "torch"},
+		CondaConfig: &CondaConfig{
+			CondaPackages: []string{"pytorch"},
+		},
+	}
+	view := g.withPackages([]Operation{
+		{Kind: OperationKindPyPIPackage, Packages: []string{"torch"}, RequirementsFile: &requirements},
+	})
+	if !equal(view.PyPIPackages, []string{"torch"}) {
+		t.Errorf("expected PyPI packages [torch], got %v", view.PyPIPackages)
+	}
+	if view.RequirementsFile == nil || *view.RequirementsFile != requirements {
+		t.Errorf("expected the requirements file to be set")
+	}
+	if len(view.CondaConfig.CondaPackages) != 0 {
+		t.Errorf("expected no conda packages, got %v", view.CondaConfig.CondaPackages)
+	}
+	if len(g.CondaConfig.CondaPackages) != 1 {
+		t.Errorf("the original graph should not be changed")
+	}
+}
+
+func TestOperationsFallback(t *testing.T) {
+	g := Graph{
+		PyPIPackages: []string{"numpy"},
+		Exec:         []string{"echo 1"},
+		Copy:         []CopyInfo{{Source: "a", Destination: "b"}},
+	}
+	ops := g.operations()
+	expected := []OperationKind{
+		OperationKindPyPIPackage, OperationKindCopy, OperationKindRun,
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("expected %d operations, got %d", len(expected), len(ops))
+	}
+	for i, op := range ops {
+		if op.Kind != expected[i] {
+			t.Errorf("expected operation %d to be %s, got %s", i, expected[i], op.Kind)
+		}
+	}
+}
+
+func TestRuleDigests(t *testing.T) {
+	g := NewGraph()
+	g.Operations = []Operation{
+		{Kind: OperationKindSystemPackage, Packages: []string{"curl"}},
+		{Kind: OperationKindPyPIPackage, Packages: []string{"numpy"}},
+	}
+	digests := g.RuleDigests()
+	rules := []string{"base", "install.system_packages", "install.python_packages"}
+	if len(digests) != len(rules) {
+		t.Fatalf("expected %d digests, got %v", len(rules), digests)
+	}
+	for i, d := range digests {
+		if d.Rule != rules[i] {
+			t.Errorf("expected rule %s, got %s", rules[i], d.Rule)
+		}
+	}
+
+	g.Operations[1].Packages = []string{"numpy", "torch"}
+	changed := g.RuleDigests()
+	if changed[1].Digest != digests[1].Digest {
+		t.Errorf("expected the digest of the system packages to be unchanged")
+	}
+	if changed[2].Digest == digests[2].Digest {
+		t.Errorf("expected the digest of the python packages to change")
+	}
+}
+
+// execDependsOn compiles the graph and returns true if the exec whose
+// arguments contain a depends on the exec whose arguments contain b.
+func execDependsOn(t *testing.T, g *Graph, a, b string) bool {
+	key := filepath.Join(t.TempDir(), "id_rsa.pub")
+	if err := os.WriteFile(key, []byte("ssh-rsa AAAA"), 0644); err != nil {
+		t.Fatalf("failed to write the public key: %v", err)
+	}
+	defer func(path string) { DefaultGraph.PublicKeyPath = path }(DefaultGraph.PublicKeyPath)
+	DefaultGraph.PublicKeyPath = key
+	defer viper.Set(flag.FlagDockerOrganization, viper.GetString(flag.FlagDockerOrganization))
+	viper.Set(flag.FlagDockerOrganization, "tensorchord")
+
+	state, err := g.Compile(1000, 1000)
+	if err != nil {
+		t.Fatalf("failed to compile the graph: %v", err)
+	}
+	def, err := state.Marshal(context.Background(), llb.LinuxAmd64)
+	if err != nil {
+		t.Fatalf("failed to marshal the LLB: %v", err)
+	}
+	ops := map[digest.Digest]*pb.Op{}
+	find := func(s string) digest.Digest {
+		for dgst, op := range ops {
+			if exec := op.GetExec(); exec != nil &&
+				strings.Contains(strings.Join(exec.Meta.Args, " "), s) {
+				return dgst
+			}
+		}
+		t.Fatalf("no exec runs %q", s)
+		return ""
+	}
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := op.Unmarshal(dt); err != nil {
+			t.Fatalf("failed to unmarshal the op: %v", err)
+		}
+		ops[digest.FromBytes(dt)] = &op
+	}
+
+	target := find(b)
+	visited := map[digest.Digest]bool{}
+	var walk func(dgst digest.Digest) bool
+	walk = func(dgst digest.Digest) bool {
+		if visited[dgst] {
+			return false
+		}
+		visited[dgst] = true
+		for _, input := range ops[dgst].Inputs {
+			if input.Digest == target || walk(input.Digest) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(find(a))
+}
+
+func TestCompileFixedOrder(t *testing.T) {
+	// run(["python -c 'import numpy'"])
+	// install.python_packages(["numpy"])
+	g := NewGraph()
+	g.PyPIPackages = []string{"numpy"}
+	g.Exec = []string{"python -c 'import numpy'"}
+	g.Operations = []Operation{
+		{Kind: OperationKindRun, Commands: g.Exec},
+		{Kind: OperationKindPyPIPackage, Packages: g.PyPIPackages},
+	}
+	if !execDependsOn(t, g, "import numpy", "pip install numpy") {
+		t.Errorf("expected the packages to be installed before the run")
+	}
+
+	g.OrderedBuild = true
+	if !execDependsOn(t, g, "pip install numpy", "import numpy") {
+		t.Errorf("expected the packages to be installed after the run in the ordered build")
+	}
+}
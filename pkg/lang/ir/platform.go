@@ -0,0 +1,91 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// DefaultPlatform is the platform of the environment if --platform is not
+// specified.
+var DefaultPlatform = ocispecs.Platform{OS: "linux", Architecture: "amd64"}
+
+// condaArchs are the architectures of the Miniconda installers, which are
+// the same as `uname -m`.
+var condaArchs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// ValidatePlatform returns an error if the environment cannot be built for
+// the platform.
+func ValidatePlatform(p ocispecs.Platform) error {
+	if p.OS != "linux" {
+		return errors.Newf("unsupported os %s, only linux is supported", p.OS)
+	}
+	if p.Architecture != "amd64" && p.Architecture != "arm64" {
+		return errors.Newf("unsupported architecture %s, only amd64 and arm64 are supported",
+			p.Architecture)
+	}
+	return nil
+}
+
+// targetPlatform returns the platform which the graph is compiled for.
+func (g Graph) targetPlatform() ocispecs.Platform {
+	if g.platform == nil {
+		return DefaultPlatform
+	}
+	return *g.platform
+}
+
+// baseImageArchitectures returns the architectures which the base image of
+// the graph is published for, see base-images/build.sh.
+func (g Graph) baseImageArchitectures() []string {
+	if g.CUDA != nil || g.CUDNN != nil {
+		return []string{"amd64", "arm64"}
+	}
+	switch g.Language.Name {
+	case "r":
+		return []string{"amd64"}
+	default:
+		return []string{"amd64", "arm64"}
+	}
+}
+
+// checkPlatform returns an error if the base image of the graph is not
+// published for the platform.
+func (g Graph) checkPlatform() error {
+	p := g.targetPlatform()
+	if err := ValidatePlatform(p); err != nil {
+		return err
+	}
+	// The custom image is resolved from the manifest list by BuildKit.
+	if g.Image != nil {
+		return nil
+	}
+	archs := g.baseImageArchitectures()
+	for _, arch := range archs {
+		if arch == p.Architecture {
+			return nil
+		}
+	}
+	return errors.Newf("the base image %s is only available for linux/%s, not %s/%s",
+		g.baseImage(), strings.Join(archs, ", linux/"), p.OS, p.Architecture)
+}
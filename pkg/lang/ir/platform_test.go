@@ -0,0 +1,85 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCheckPlatform(t *testing.T) {
+	arm64 := ocispecs.Platform{OS: "linux", Architecture: "arm64"}
+	g := NewGraph()
+	g.platform = &arm64
+	if err := g.checkPlatform(); err != nil {
+		t.Errorf("expected arm64 to be supported, got %v", err)
+	}
+
+	cuda, cudnn := "11.6", "8"
+	g.CUDA, g.CUDNN = &cuda, &cudnn
+	if err := g.checkPlatform(); err != nil {
+		t.Errorf("expected CUDA on arm64 to be supported, got %v", err)
+	}
+
+	g = NewGraph()
+	g.Language.Name = "r"
+	if err := g.checkPlatform(); err != nil {
+		t.Errorf("expected R on the default platform to be supported, got %v", err)
+	}
+	g.platform = &arm64
+	if err := g.checkPlatform(); err == nil {
+		t.Errorf("expected an error for R on arm64")
+	}
+
+	g.Language.Name = "julia"
+	if err := g.checkPlatform(); err != nil {
+		t.Errorf("expected julia on arm64 to be supported, got %v", err)
+	}
+}
+
+func TestInstallCondaArch(t *testing.T) {
+	arm64 := ocispecs.Platform{OS: "linux", Architecture: "arm64"}
+	g := NewGraph()
+	g.platform = &arm64
+	root, err := g.installConda(llb.Image("ubuntu:20.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := root.Marshal(context.Background(), llb.Platform(arm64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := op.Unmarshal(dt); err != nil {
+			t.Fatal(err)
+		}
+		if op.Platform != nil && op.Platform.Architecture != "arm64" {
+			t.Errorf("expected the arm64 platform, got %s", op.Platform.Architecture)
+		}
+		if e := op.GetExec(); e != nil {
+			found = found || strings.Contains(strings.Join(e.Meta.Env, " "), "CONDA_ARCH=aarch64")
+		}
+	}
+	if !found {
+		t.Errorf("expected the aarch64 installer")
+	}
+}
@@ -126,22 +126,20 @@ func (g Graph) compilePyPIPackages(root llb.State) llb.State {
 	cache := root.File(llb.Mkdir("/cache",
 		0755, llb.WithParents(true), llb.WithUIDGID(g.uid, g.gid)), llb.WithCustomName("[internal] setting pip cache mount permissions"))
 
-	if len(g.PyPIPackages) != 0 {
-		// Compose the package install command.
-		var sb strings.Builder
-		// Always use the conda's pip.
-		sb.WriteString("/opt/conda/envs/envd/bin/python -m pip install")
-		for _, pkg := range g.PyPIPackages {
-			sb.WriteString(fmt.Sprintf(" %s", pkg))
-		}
+	// Install the locked versions if envd.lock exists.
+	stage, constraints := g.compilePipConstraints(root)
+	root = stage
 
-		cmd := sb.String()
+	if len(g.PyPIPackages) != 0 {
+		cmd := pipInstallCommand(constraints, g.PyPIPackages...)
 		logrus.WithField("command", cmd).
 			Debug("Configure pip install statements")
 		root = llb.User("envd")(root)
-		run := root.
-			Run(llb.Shlex(sb.String()), llb.WithCustomNamef("pip install %s",
-				strings.Join(g.PyPIPackages, " ")))
+		opts := append([]llb.RunOption{
+			llb.Shlex(cmd),
+			llb.WithCustomNamef("pip install %s", strings.Join(g.PyPIPackages, " ")),
+		}, g.packageSecrets()...)
+		run := root.Run(opts...)
 		// Refer to https://github.com/moby/buildkit/blob/31054718bf775bf32d1376fe1f3611985f837584/frontend/dockerfile/dockerfile2llb/convert_runmount.go#L46
 		run.AddMount(cacheDir, cache,
 			llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared), llb.SourcePath("/cache"))
@@ -149,17 +147,15 @@ func (g Graph) compilePyPIPackages(root llb.State) llb.State {
 	}
 
 	if g.RequirementsFile != nil {
-		// Compose the package install command.
-		var sb strings.Builder
-		sb.WriteString("/opt/conda/envs/envd/bin/python -m pip install -r ")
-		sb.WriteString(*g.RequirementsFile)
-		cmd := sb.String()
+		cmd := pipInstallCommand(constraints, "-r", *g.RequirementsFile)
 		logrus.WithField("command", cmd).
 			Debug("Configure pip install requirements statements")
 		root = root.Dir(g.getWorkingDir())
-		run := root.
-			Run(llb.Shlex(cmd), llb.WithCustomNamef("pip install %s",
-				strings.Join(g.PyPIPackages, " ")))
+		opts := append([]llb.RunOption{
+			llb.Shlex(cmd),
+			llb.WithCustomNamef("pip install %s", strings.Join(g.PyPIPackages, " ")),
+		}, g.packageSecrets()...)
+		run := root.Run(opts...)
 		run.AddMount(cacheDir, cache,
 			llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared), llb.SourcePath("/cache"))
 		run.AddMount(g.getWorkingDir(),
@@ -169,15 +165,36 @@ func (g Graph) compilePyPIPackages(root llb.State) llb.State {
 	return root
 }
 
+// pipInstallCommand returns the command to install the python packages.
+// It always uses the conda's pip.
+func pipInstallCommand(constraints bool, args ...string) string {
+	var sb strings.Builder
+	sb.WriteString("/opt/conda/envs/envd/bin/python -m pip install")
+	if constraints {
+		sb.WriteString(" -c " + pipConstraintsFilePath)
+	}
+	for _, arg := range args {
+		sb.WriteString(fmt.Sprintf(" %s", arg))
+	}
+	return sb.String()
+}
+
+// pypiConfig returns the content of the pip config file.
+func (g Graph) pypiConfig() string {
+	var extraIndex string
+	if g.PyPIExtraIndexURL != nil {
+		extraIndex = "extra-index-url=" + *g.PyPIExtraIndexURL
+	}
+	return fmt.Sprintf(pypiConfigTemplate, *g.PyPIIndexURL, extraIndex)
+}
+
 func (g Graph) compilePyPIIndex(root llb.State) llb.State {
 	if g.PyPIIndexURL != nil {
 		logrus.WithField("index", *g.PyPIIndexURL).Debug("using custom PyPI index")
-		var extraIndex string
 		if g.PyPIExtraIndexURL != nil {
 			logrus.WithField("index", *g.PyPIIndexURL).Debug("using extra PyPI index")
-			extraIndex = "extra-index-url=" + *g.PyPIExtraIndexURL
 		}
-		content := fmt.Sprintf(pypiConfigTemplate, *g.PyPIIndexURL, extraIndex)
+		content := g.pypiConfig()
 		pypiMirror := root.
 			File(llb.Mkdir(filepath.Dir(pypiIndexFilePath),
 				0755, llb.WithParents(true), llb.WithUIDGID(g.uid, g.gid)),
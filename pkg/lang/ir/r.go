@@ -20,6 +20,8 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/moby/buildkit/client/llb"
+
+	"github.com/tensorchord/envd/pkg/lockfile"
 )
 
 func (g Graph) compileRLang(aptStage llb.State) (llb.State, error) {
@@ -72,24 +74,47 @@ func (g Graph) installRPackages(root llb.State) llb.State {
 	if len(g.RPackages) == 0 {
 		return root
 	}
+
+	// TODO(terrytangyuan): Support cache.
+	cmd := g.rInstallCommand()
+	root = llb.User("envd")(root)
+	run := root.Run(llb.Shlex(cmd), llb.WithCustomNamef("install R packages"))
+	return run.Root()
+}
+
+// rInstallCommand returns the command to install the R packages.
+func (g Graph) rInstallCommand() string {
 	// TODO(terrytangyuan): Support different CRAN mirrors
 	var sb strings.Builder
 	mirrorURL := "https://cran.rstudio.com"
 	if g.CRANMirrorURL != nil {
 		mirrorURL = *g.CRANMirrorURL
 	}
-	sb.WriteString(fmt.Sprintf(`R -e 'options(repos = c(CRAN = "%s")); install.packages(c(`, mirrorURL))
-	for i, pkg := range g.RPackages {
-		sb.WriteString(fmt.Sprintf(`"%s"`, pkg))
-		if i != len(g.RPackages)-1 {
-			sb.WriteString(", ")
+	pkgs := g.RPackages
+	pinned := []lockfile.Package{}
+	if g.Lockfile != nil {
+		pinned, pkgs = pinnedPackages(g.Lockfile.R, g.RPackages)
+	}
+	sb.WriteString(fmt.Sprintf(`R -e 'options(repos = c(CRAN = "%s"))`, mirrorURL))
+	if len(pkgs) != 0 {
+		sb.WriteString("; install.packages(c(")
+		for i, pkg := range pkgs {
+			sb.WriteString(fmt.Sprintf(`"%s"`, pkg))
+			if i != len(pkgs)-1 {
+				sb.WriteString(", ")
+			}
 		}
+		sb.WriteString("))")
 	}
-	sb.WriteString(`))'`)
-
-	// TODO(terrytangyuan): Support cache.
-	cmd := sb.String()
-	root = llb.User("envd")(root)
-	run := root.Run(llb.Shlex(cmd), llb.WithCustomNamef("install R packages"))
-	return run.Root()
+	if len(pinned) != 0 {
+		// install.packages always installs the latest version,
+		// thus the locked versions are installed by remotes.
+		sb.WriteString(`; if (!requireNamespace("remotes", quietly = TRUE)) install.packages("remotes")`)
+		for _, pkg := range pinned {
+			sb.WriteString(fmt.Sprintf(`; remotes::install_version("%s", version = "%s", upgrade = "never")`,
+				pkg.Name, pkg.Version))
+		}
+	}
+	sb.WriteString(`'`)
+	return sb.String()
 }
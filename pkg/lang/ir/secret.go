@@ -0,0 +1,89 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/moby/buildkit/client/llb"
+)
+
+const secretDir = "/run/secrets"
+
+func defaultSecretTarget(id string) string {
+	return filepath.Join(secretDir, id)
+}
+
+// SecretIDs returns the IDs of the declared secrets.
+func (g Graph) SecretIDs() []string {
+	ids := []string{}
+	for _, s := range g.Secrets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// checkSecrets returns an error if a run uses an undeclared secret.
+func (g Graph) checkSecrets() error {
+	declared := map[string]bool{}
+	for _, s := range g.Secrets {
+		declared[s.ID] = true
+	}
+	for _, op := range g.operations() {
+		for _, id := range op.Secrets {
+			if !declared[id] {
+				return errors.Newf("secret %s is not declared, declare it with config.secret", id)
+			}
+		}
+	}
+	return nil
+}
+
+// packageSecrets mounts all the declared secrets, e.g. the credentials of
+// the private PyPI index, into the package installation steps.
+func (g Graph) packageSecrets() []llb.RunOption {
+	return g.secretMounts(g.SecretIDs())
+}
+
+// secretMounts returns the BuildKit secret mounts of the secrets. The
+// secrets are only available in the steps, they are not in the layers.
+func (g Graph) secretMounts(ids []string) []llb.RunOption {
+	// The merged runs may use the same secret, it is mounted once.
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	opts := []llb.RunOption{}
+	for _, s := range g.Secrets {
+		if !wanted[s.ID] {
+			continue
+		}
+		if s.Target != "" {
+			opts = append(opts, llb.AddSecret(s.Target, llb.SecretID(s.ID),
+				llb.SecretFileOpt(g.uid, g.gid, 0400)))
+		}
+		if s.Env != "" {
+			opts = append(opts, llb.AddSecret(s.Env, llb.SecretID(s.ID),
+				llb.SecretAsEnv(true)))
+		}
+	}
+	return opts
+}
+
+// SecretIDs returns the IDs of the secrets declared in the default graph.
+func SecretIDs() []string {
+	return DefaultGraph.SecretIDs()
+}
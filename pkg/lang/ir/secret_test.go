@@ -0,0 +1,97 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+)
+
+func TestSecret(t *testing.T) {
+	DefaultGraph = NewGraph()
+	if err := Secret("pypi", "", "PIP_INDEX_URL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Secret("netrc", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Secret("pypi", "", ""); err == nil {
+		t.Errorf("expected an error for the duplicated secret")
+	}
+	if err := Secret("", "", ""); err == nil {
+		t.Errorf("expected an error for the empty id")
+	}
+	if DefaultGraph.Secrets[1].Target != "/run/secrets/netrc" {
+		t.Errorf("unexpected default target %s", DefaultGraph.Secrets[1].Target)
+	}
+
+	if err := Run([]string{"pip download private"}, []string{"unknown"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultGraph.checkSecrets(); err == nil {
+		t.Errorf("expected an error for the undeclared secret")
+	}
+}
+
+func TestSecretMounts(t *testing.T) {
+	g := NewGraph()
+	g.Secrets = []SecretInfo{
+		{ID: "pypi", Env: "PIP_INDEX_URL"},
+		{ID: "netrc", Target: "/home/envd/.netrc"},
+	}
+	// The secret is mounted once although both runs use it.
+	root := g.compileRun(llb.Image("ubuntu:20.04"),
+		[]string{"echo 1", "echo 2"}, []string{"netrc", "netrc"}, false)
+	def, err := root.Marshal(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exec *pb.ExecOp
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := op.Unmarshal(dt); err != nil {
+			t.Fatal(err)
+		}
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	if exec == nil {
+		t.Fatal("expected an exec op")
+	}
+	secrets := 0
+	for _, m := range exec.Mounts {
+		if m.MountType == pb.MountType_SECRET {
+			secrets++
+			if m.Dest != "/home/envd/.netrc" || m.SecretOpt.ID != "netrc" {
+				t.Errorf("unexpected secret mount %v", m)
+			}
+		}
+	}
+	if secrets != 1 {
+		t.Errorf("expected 1 secret mount, got %d", secrets)
+	}
+	if len(exec.Secretenv) != 0 {
+		t.Errorf("expected no secret env, got %v", exec.Secretenv)
+	}
+
+	if opts := g.packageSecrets(); len(opts) != 2 {
+		t.Errorf("expected the package steps to mount all the secrets, got %d", len(opts))
+	}
+}
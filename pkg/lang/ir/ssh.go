@@ -0,0 +1,50 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// sshAgentID is the ID of the agent forwarded by `envd build --ssh default`.
+	sshAgentID     = "default"
+	sshAgentSocket = "/run/buildkit/ssh_agent.0"
+)
+
+// SSHRequired returns true if a run mounts the SSH agent.
+func (g Graph) SSHRequired() bool {
+	for _, op := range g.operations() {
+		if op.SSH {
+			return true
+		}
+	}
+	return false
+}
+
+// sshAgentMount mounts the forwarded SSH agent, thus git and ssh in the
+// run can access the private repositories with the keys of the host.
+func (g Graph) sshAgentMount() []llb.RunOption {
+	return []llb.RunOption{
+		llb.AddSSHSocket(llb.SSHID(sshAgentID),
+			llb.SSHSocketOpt(sshAgentSocket, g.uid, g.gid, 0600)),
+		llb.AddEnv("SSH_AUTH_SOCK", sshAgentSocket),
+	}
+}
+
+// SSHRequired returns true if a run of the default graph mounts the agent.
+func SSHRequired() bool {
+	return DefaultGraph.SSHRequired()
+}
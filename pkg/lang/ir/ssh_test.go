@@ -0,0 +1,62 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+)
+
+func TestSSHAgentMount(t *testing.T) {
+	g := NewGraph()
+	g.Operations = []Operation{
+		{Kind: OperationKindRun, Commands: []string{"git clone git@github.com:org/repo"}, SSH: true},
+	}
+	if !g.SSHRequired() {
+		t.Errorf("expected the ssh agent to be required")
+	}
+	root := g.compileRun(llb.Image("ubuntu:20.04"), g.Operations[0].Commands, nil, true)
+	def, err := root.Marshal(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := op.Unmarshal(dt); err != nil {
+			t.Fatal(err)
+		}
+		e := op.GetExec()
+		if e == nil {
+			continue
+		}
+		for _, m := range e.Mounts {
+			if m.MountType == pb.MountType_SSH && m.Dest == sshAgentSocket && m.SSHOpt.ID == sshAgentID {
+				found = true
+			}
+		}
+		env := strings.Join(e.Meta.Env, " ")
+		if !strings.Contains(env, "SSH_AUTH_SOCK="+sshAgentSocket) {
+			t.Errorf("expected SSH_AUTH_SOCK in %s", env)
+		}
+	}
+	if !found {
+		t.Errorf("expected the ssh agent mount")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
+)
+
+// reservedDaemonNames are used by the processes started by envd.
+var reservedDaemonNames = map[string]bool{
+	"ssh":     true,
+	"jupyter": true,
+	"rstudio": true,
+}
+
+// daemonName returns a unique name of the daemon, which is the base name of
+// the executable, e.g. streamlit, streamlit-2.
+func daemonName(commands []string, existing []DaemonConfig) string {
+	name := "daemon"
+	if len(commands) != 0 && commands[0] != "" {
+		name = filepath.Base(commands[0])
+	}
+	used := map[string]bool{}
+	for _, d := range existing {
+		used[d.Name] = true
+	}
+	candidate := name
+	for i := 2; used[candidate] || reservedDaemonNames[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
+
+// shellCommand runs the command in bash, thus `~` and the environment
+// variables are expanded as in the entrypoint script used before.
+func shellCommand(args []string) []string {
+	return []string{"bash", "-c", strings.Join(args, " ")}
+}
+
+// SupervisorSpec returns the spec of the processes supervised by envd-ssh,
+// which are the ssh server, jupyter, rstudio server and the daemons.
+func (g Graph) SupervisorSpec(buildContextDir string) supervisor.Spec {
+	spec := supervisor.Spec{
+		LogDir: config.ContainerLogDir,
+		Processes: []supervisor.Process{{
+			Name: "ssh",
+			Command: []string{
+				config.ContainerEnvdSSHPath,
+				"--authorized-keys", config.ContainerAuthorizedKeysPath,
+				"--port", strconv.Itoa(config.SSHPortInContainer),
+				"--shell", g.Shell,
+			},
+			Restart: supervisor.RestartAlways,
+		}},
+	}
+	// The container runs as root if there are users, then envd-ssh runs the
+	// sessions as the users, and the other processes run as envd. The keys
+	// annotated with the other users are rejected.
+	user := ""
+	if len(g.Users) != 0 {
+		user = defaultUser
+		spec.Processes[0].Command = append(spec.Processes[0].Command,
+			"--user", defaultUser, "--allowed-users", strings.Join(g.Users, ","))
+	}
+
+	workingDir := filepath.Join("/home/envd", filepath.Base(buildContextDir))
+	if g.JupyterConfig != nil {
+		spec.Processes = append(spec.Processes, supervisor.Process{
+			Name:    "jupyter",
+			Command: shellCommand(g.generateJupyterCommand(workingDir)),
+			Restart: supervisor.RestartOnFailure,
+			User:    user,
+		})
+	}
+	if g.RStudioServerConfig != nil {
+		spec.Processes = append(spec.Processes, supervisor.Process{
+			Name:    "rstudio",
+			Command: shellCommand(g.generateRStudioCommand(workingDir)),
+			Restart: supervisor.RestartOnFailure,
+			User:    user,
+		})
+	}
+
+	for _, d := range g.RuntimeDaemon {
+		p := supervisor.Process{
+			Name:    d.Name,
+			Command: shellCommand(d.Commands),
+			Restart: supervisor.RestartPolicy(d.Restart),
+			User:    user,
+		}
+		if p.Restart == "" {
+			p.Restart = supervisor.RestartNo
+		}
+		if hc := d.Healthcheck; hc != nil {
+			p.Healthcheck = &supervisor.Healthcheck{
+				Command:  shellCommand(hc.Commands),
+				Interval: supervisor.Duration(hc.Interval),
+				Timeout:  supervisor.Duration(hc.Timeout),
+				Retries:  hc.Retries,
+			}
+		}
+		spec.Processes = append(spec.Processes, p)
+	}
+	return spec
+}
+
+func (g Graph) supervisorSpecJSON(buildContextDir string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Keep the shell redirections, e.g. 2>&1, readable.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(g.SupervisorSpec(buildContextDir)); err != nil {
+		return "", errors.Wrap(err, "failed to marshal the supervisor spec")
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tensorchord/envd/pkg/config"
+	"github.com/tensorchord/envd/pkg/remote/supervisor"
+)
+
+func TestDaemonName(t *testing.T) {
+	existing := []DaemonConfig{{Name: "streamlit"}}
+	tcs := []struct {
+		commands []string
+		expected string
+	}{
+		{[]string{"/usr/bin/python3", "serve.py"}, "python3"},
+		{[]string{"streamlit", "hello"}, "streamlit-2"},
+		{[]string{"jupyter", "lab"}, "jupyter-2"},
+		{nil, "daemon"},
+	}
+	for _, tc := range tcs {
+		if name := daemonName(tc.commands, existing); name != tc.expected {
+			t.Errorf("expected %s for %v, got %s", tc.expected, tc.commands, name)
+		}
+	}
+}
+
+func TestSupervisorSpec(t *testing.T) {
+	g := NewGraph()
+	g.Shell = "bash"
+	g.RuntimeDaemon = []DaemonConfig{
+		{Name: "python3", Commands: []string{"python3", "serve.py", ">>serve.log", "2>&1"}},
+		{
+			Name:     "streamlit",
+			Commands: []string{"streamlit", "hello"},
+			Restart:  string(supervisor.RestartOnFailure),
+			Healthcheck: &HealthcheckConfig{
+				Commands: []string{"curl", "-f", "localhost:8501"},
+				Interval: 10 * time.Second,
+				Timeout:  5 * time.Second,
+				Retries:  3,
+			},
+		},
+	}
+
+	g.JupyterConfig = &JupyterConfig{}
+
+	spec := g.SupervisorSpec("/home/user/mnist")
+	if spec.LogDir != config.ContainerLogDir {
+		t.Errorf("expected the log dir %s, got %s", config.ContainerLogDir, spec.LogDir)
+	}
+	names := []string{}
+	for _, p := range spec.Processes {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "ssh,jupyter,python3,streamlit" {
+		t.Fatalf("unexpected processes %v", names)
+	}
+	if spec.Processes[0].Restart != supervisor.RestartAlways {
+		t.Errorf("expected the ssh server to be always restarted")
+	}
+	if !strings.Contains(spec.Processes[1].Command[2], "--notebook-dir /home/envd/mnist") {
+		t.Errorf("unexpected jupyter command %v", spec.Processes[1].Command)
+	}
+	p := spec.Processes[2]
+	if p.Restart != supervisor.RestartNo || p.Healthcheck != nil ||
+		strings.Join(p.Command, " ") != "bash -c python3 serve.py >>serve.log 2>&1" {
+		t.Errorf("unexpected process %+v", p)
+	}
+	p = spec.Processes[3]
+	if p.Restart != supervisor.RestartOnFailure || p.Healthcheck == nil ||
+		p.Healthcheck.Command[2] != "curl -f localhost:8501" || p.Healthcheck.Retries != 3 {
+		t.Errorf("unexpected process %+v", p)
+	}
+
+	ep, err := g.GetEntrypoint("/home/user/mnist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ep[:5], " ") != "tini -- /var/envd/bin/envd-ssh supervise --spec-json" {
+		t.Fatalf("unexpected entrypoint %v", ep)
+	}
+	if !strings.Contains(ep[5], "2>&1") {
+		t.Errorf("expected the spec not to escape the redirections, got %s", ep[5])
+	}
+	parsed, err := supervisor.ParseSpec([]byte(ep[5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Processes) != len(spec.Processes) {
+		t.Errorf("expected %d processes in the entrypoint, got %d", len(spec.Processes), len(parsed.Processes))
+	}
+}
+
+func TestSupervisorSpecUsers(t *testing.T) {
+	g := NewGraph()
+	g.Shell = "bash"
+	g.Users = []string{"alice"}
+	g.RuntimeDaemon = []DaemonConfig{{Name: "streamlit", Commands: []string{"streamlit", "hello"}}}
+
+	spec := g.SupervisorSpec("/home/user/mnist")
+	ssh := spec.Processes[0]
+	if ssh.User != "" || strings.Join(ssh.Command[len(ssh.Command)-4:], " ") != "--user envd --allowed-users alice" {
+		t.Errorf("expected the ssh server to run the sessions as envd, got %+v", ssh)
+	}
+	if spec.Processes[1].User != "envd" {
+		t.Errorf("expected the daemon to run as envd, got %+v", spec.Processes[1])
+	}
+}
+
+func TestUsers(t *testing.T) {
+	DefaultGraph = NewGraph()
+	if err := Users([]string{"alice", "data-team"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, names := range [][]string{{"envd"}, {"Alice"}, {"alice"}, {"bob", "bob"}} {
+		if err := Users(names); err == nil {
+			t.Errorf("expected an error for %v", names)
+		}
+	}
+	if strings.Join(DefaultGraph.Users, ",") != "alice,data-team,bob" {
+		t.Errorf("unexpected users %v", DefaultGraph.Users)
+	}
+}
+
+func TestUsersCommands(t *testing.T) {
+	g := NewGraph()
+	if len(g.usersCommands()) != 0 {
+		t.Errorf("expected no commands without users")
+	}
+	g.Users = []string{"alice"}
+	commands := strings.Join(g.usersCommands(), "\n")
+	if strings.Contains(commands, `-p ""`) {
+		t.Errorf("expected the users to be created without the empty password, got %s", commands)
+	}
+	for _, c := range []string{"passwd -l envd", "NOPASSWD:ALL", "useradd -g envd -s /bin/sh -m alice", "passwd -l alice"} {
+		if !strings.Contains(commands, c) {
+			t.Errorf("expected %q in the commands, got %s", c, commands)
+		}
+	}
+}
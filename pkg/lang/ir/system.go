@@ -45,27 +45,34 @@ func (g Graph) compileUbuntuAPT(root llb.State) llb.State {
 	return root
 }
 
-func (g Graph) compileRun(root llb.State) llb.State {
-	if len(g.Exec) == 0 {
+func (g Graph) compileRun(root llb.State, commands []string, secrets []string, ssh bool) llb.State {
+	if len(commands) == 0 {
 		return root
 	}
-	root = root.AddEnv("PATH", "$PATH:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/opt/conda/bin:/usr/local/julia/bin:/opt/conda/envs/envd/bin")
-	logrus.Debugf("compile run: %s", strings.Join(g.Exec, " "))
-	if len(g.Exec) == 1 {
-		return root.Run(llb.Shlex(fmt.Sprintf("bash -c \"%s\"", g.Exec[0]))).Root()
+	root = root.AddEnv("PATH", runPath)
+	logrus.Debugf("compile run: %s", strings.Join(commands, " "))
+	mounts := g.secretMounts(secrets)
+	if ssh {
+		mounts = append(mounts, g.sshAgentMount()...)
+	}
+	if len(commands) == 1 {
+		opts := append([]llb.RunOption{
+			llb.Shlex(fmt.Sprintf("bash -c \"%s\"", commands[0])),
+		}, mounts...)
+		return root.Run(opts...).Root()
 	}
 
 	var sb strings.Builder
 	sb.WriteString("set -euo pipefail\n")
-	for _, c := range g.Exec {
+	for _, c := range commands {
 		sb.WriteString(c + "\n")
 	}
 
 	cmdStr := fmt.Sprintf("bash -c '%s'", sb.String())
 	logrus.WithField("command", cmdStr).Debug("compile run command")
 	workingDir := g.getWorkingDir()
-	run := root.Dir(workingDir).
-		Run(llb.Shlex(cmdStr))
+	opts := append([]llb.RunOption{llb.Shlex(cmdStr)}, mounts...)
+	run := root.Dir(workingDir).Run(opts...)
 	// Mount the build context into the build process.
 	// TODO(gaocegege): Maybe we should make it readonly,
 	// but these cases then cannot be supported:
@@ -75,25 +82,46 @@ func (g Graph) compileRun(root llb.State) llb.State {
 	return run.Root()
 }
 
-func (g Graph) compileCopy(root llb.State) llb.State {
-	if len(g.Copy) == 0 {
-		return root
-	}
+func (g Graph) compileCopy(root llb.State, c CopyInfo) llb.State {
+	return root.File(llb.Copy(
+		llb.Local(flag.FlagBuildContext), c.Source, c.Destination,
+		llb.WithUIDGID(g.uid, g.gid)))
+}
 
-	result := root
-	// Compose the copy command.
-	for _, c := range g.Copy {
-		result = result.File(llb.Copy(
-			llb.Local(flag.FlagBuildContext), c.Source, c.Destination,
-			llb.WithUIDGID(g.uid, g.gid)))
+// baseImage returns the image which the environment is built on.
+func (g Graph) baseImage() string {
+	if g.Image != nil {
+		return *g.Image
+	}
+	org := viper.GetString(flag.FlagDockerOrganization)
+	v := version.GetVersionForImageTag()
+	if g.CUDA != nil || g.CUDNN != nil {
+		return fmt.Sprintf(
+			"docker.io/%s/python:3.9-%s-cuda%s-cudnn%s-envd-%s",
+			org, g.OS, *g.CUDA, *g.CUDNN, v)
+	}
+	switch g.Language.Name {
+	case "r":
+		return fmt.Sprintf("docker.io/%s/r-base:4.2-envd-%s", org, v)
+	case "julia":
+		return fmt.Sprintf("docker.io/%s/julia:1.8rc1-ubuntu20.04-envd-%s", org, v)
+	default:
+		return fmt.Sprintf("docker.io/%s/python:3.9-ubuntu20.04-envd-%s", org, v)
 	}
-	return result
 }
 
-func (g *Graph) compileCUDAPackages(org, version string) llb.State {
-	return llb.Image(fmt.Sprintf(
-		"docker.io/%s/python:3.9-%s-cuda%s-cudnn%s-envd-%s",
-		org, g.OS, *g.CUDA, *g.CUDNN, version))
+// aptInstallCommand returns the command to install the system packages.
+func aptInstallCommand(pkgs []string, sudo bool) string {
+	var sb strings.Builder
+	if sudo {
+		sb.WriteString("sudo apt-get update && sudo apt-get install -y --no-install-recommends")
+	} else {
+		sb.WriteString("apt-get update && apt-get install -y --no-install-recommends")
+	}
+	for _, pkg := range pkgs {
+		sb.WriteString(fmt.Sprintf(" %s", pkg))
+	}
+	return sb.String()
 }
 
 func (g Graph) compileSystemPackages(root llb.State) llb.State {
@@ -101,20 +129,14 @@ func (g Graph) compileSystemPackages(root llb.State) llb.State {
 		return root
 	}
 
-	// Compose the package install command.
-	var sb strings.Builder
-	sb.WriteString("sudo apt-get update && sudo apt-get install -y --no-install-recommends")
-
-	for _, pkg := range g.SystemPackages {
-		sb.WriteString(fmt.Sprintf(" %s", pkg))
-	}
-
 	cacheDir := "/var/cache/apt"
 	cacheLibDir := "/var/lib/apt"
 
-	run := root.Run(llb.Shlex(fmt.Sprintf("bash -c \"%s\"", sb.String())),
-		llb.WithCustomNamef("apt-get install %s",
-			strings.Join(g.SystemPackages, " ")))
+	opts := append([]llb.RunOption{
+		llb.Shlex(fmt.Sprintf("bash -c \"%s\"", aptInstallCommand(g.SystemPackages, true))),
+		llb.WithCustomNamef("apt-get install %s", strings.Join(g.SystemPackages, " ")),
+	}, g.packageSecrets()...)
+	run := root.Run(opts...)
 	run.AddMount(cacheDir, llb.Scratch(),
 		llb.AsPersistentCacheDir(g.CacheID(cacheDir), llb.CacheMountShared))
 	run.AddMount(cacheLibDir, llb.Scratch(),
@@ -131,35 +153,25 @@ func (g *Graph) compileBase() (llb.State, error) {
 		logger = logger.WithField("version", *g.Language.Version)
 	}
 	logger.Debug("compile base image")
+	if err := g.checkPlatform(); err != nil {
+		return llb.State{}, err
+	}
 
-	var base llb.State
-	org := viper.GetString(flag.FlagDockerOrganization)
-	v := version.GetVersionForImageTag()
 	// Do not update user permission in the base image.
 	if g.Image != nil {
 		logger.WithField("image", *g.Image).Debugf("using custom base image")
 		return llb.Image(*g.Image), nil
-	} else if g.CUDA == nil && g.CUDNN == nil {
-		switch g.Language.Name {
-		case "r":
-			base = llb.Image(fmt.Sprintf("docker.io/%s/r-base:4.2-envd-%s", org, v))
-			// r-base image already has GID 1000.
-			// It is a trick, we actually use GID 1000
-			if g.gid == 1000 {
-				g.gid = 1001
-			}
-			if g.uid == 1000 {
-				g.uid = 1001
-			}
-		case "python":
-			base = llb.Image(fmt.Sprintf(
-				"docker.io/%s/python:3.9-ubuntu20.04-envd-%s", org, v))
-		case "julia":
-			base = llb.Image(fmt.Sprintf(
-				"docker.io/%s/julia:1.8rc1-ubuntu20.04-envd-%s", org, v))
+	}
+	base := llb.Image(g.baseImage())
+	if g.CUDA == nil && g.CUDNN == nil && g.Language.Name == "r" {
+		// r-base image already has GID 1000.
+		// It is a trick, we actually use GID 1000
+		if g.gid == 1000 {
+			g.gid = 1001
+		}
+		if g.uid == 1000 {
+			g.uid = 1001
 		}
-	} else {
-		base = g.compileCUDAPackages(org, v)
 	}
 	var res llb.ExecState
 
@@ -199,7 +211,7 @@ func (g *Graph) compileBase() (llb.State, error) {
 			Run(llb.Shlex("chown -R envd:envd /opt/conda"),
 				llb.WithCustomName("[internal] configure user permissions"))
 	}
-	return llb.User("envd")(res.Root()), nil
+	return llb.User("envd")(g.compileUsers(res.Root())), nil
 }
 
 func (g Graph) copySSHKey(root llb.State) (llb.State, error) {
@@ -209,6 +221,19 @@ func (g Graph) copySSHKey(root llb.State) (llb.State, error) {
 	if err != nil {
 		return llb.State{}, errors.Wrap(err, "Cannot read public SSH key")
 	}
+	if len(g.Users) != 0 {
+		// envd-ssh runs as root to run the sessions as the users, thus only
+		// root can modify the keys, otherwise envd could annotate its key
+		// with another user. The keys are added by `envd-ssh authorized-keys`.
+		run := root.
+			Run(llb.Shlex("sh -c \"mkdir -p /var/envd && chown -R root:root /var/envd\""),
+				llb.User("root"),
+				llb.WithCustomName("[internal] create /var/envd owned by root")).Root().
+			File(llb.Mkfile(config.ContainerAuthorizedKeysPath,
+				0644, []byte(dat+" envd"), llb.WithUIDGID(0, 0)),
+				llb.WithCustomName("install ssh keys"))
+		return run, nil
+	}
 	run := root.
 		File(llb.Mkdir("/var/envd", 0755, llb.WithParents(true),
 			llb.WithUIDGID(g.uid, g.gid))).
@@ -15,16 +15,22 @@
 package ir
 
 import (
+	"time"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+
 	"github.com/tensorchord/envd/pkg/editor/vscode"
+	"github.com/tensorchord/envd/pkg/lockfile"
 	"github.com/tensorchord/envd/pkg/progress/compileui"
 )
 
 // A Graph contains the state,
 // such as its call stack and thread-local storage.
-// TODO(gaocegeg): Refactor it to support order.
 type Graph struct {
 	uid int
 	gid int
+	// platform is the target platform of the compilation.
+	platform *ocispecs.Platform
 
 	OS string
 	Language
@@ -51,11 +57,28 @@ type Graph struct {
 
 	VSCodePlugins []vscode.Plugin
 
+	// Users are the Linux users of the collaborators, besides envd.
+	Users []string
+
+	// Secrets are mounted into the build steps, and never persisted
+	// in the image.
+	Secrets []SecretInfo
+
 	Exec       []string
 	Copy       []CopyInfo
 	Mount      []MountInfo
 	Entrypoint []string
 
+	// Operations keeps the build steps in the order they are declared.
+	// The fields above are the aggregated view used for labels and runtime.
+	Operations []Operation
+	// OrderedBuild compiles the operations in the declared order. Otherwise
+	// they are compiled in the fixed order: packages, copy and run.
+	OrderedBuild bool
+
+	// Lockfile pins the versions of the packages if envd.lock exists.
+	Lockfile *lockfile.File
+
 	*JupyterConfig
 	*GitConfig
 	*CondaConfig
@@ -73,11 +96,51 @@ type Graph struct {
 // The results during runtime should be maintained here
 type RuntimeGraph struct {
 	RuntimeCommands map[string]string
-	RuntimeDaemon   [][]string
+	RuntimeDaemon   []DaemonConfig
 	RuntimeEnviron  map[string]string
 	RuntimeExpose   []ExposeItem
 }
 
+// OperationKind is the kind of an ordered build step.
+type OperationKind string
+
+const (
+	OperationKindSystemPackage OperationKind = "system-packages"
+	OperationKindPyPIPackage   OperationKind = "pypi-packages"
+	OperationKindCondaPackage  OperationKind = "conda-packages"
+	OperationKindRPackage      OperationKind = "r-packages"
+	OperationKindJuliaPackage  OperationKind = "julia-packages"
+	OperationKindCopy          OperationKind = "copy"
+	OperationKindRun           OperationKind = "run"
+)
+
+// Operation is a build step recorded from a rule call.
+type Operation struct {
+	Kind OperationKind
+	// Packages is set for the package operations.
+	Packages []string
+	// RequirementsFile is set for the PyPI operation with requirements.
+	RequirementsFile *string
+	// Commands is set for the run operation.
+	Commands []string
+	// Secrets are the IDs of the secrets mounted into the run operation.
+	Secrets []string
+	// SSH mounts the SSH agent forwarded by `envd build --ssh`.
+	SSH bool
+	// Copy is set for the copy operation.
+	Copy *CopyInfo
+}
+
+// SecretInfo is a secret declared by config.secret. The value is provided
+// by `envd build --secret`.
+type SecretInfo struct {
+	ID string
+	// Target is the path of the secret file in the build steps.
+	Target string
+	// Env is the environment variable of the secret in the build steps.
+	Env string
+}
+
 type CopyInfo struct {
 	Source      string
 	Destination string
@@ -86,6 +149,14 @@ type CopyInfo struct {
 type MountInfo struct {
 	Source      string
 	Destination string
+	// Type is MountTypeBind if empty.
+	Type     MountType
+	ReadOnly bool
+	// CopyOnWrite mounts an overlay of the source, the changes are written
+	// to a per-environment upper dir instead of the source.
+	CopyOnWrite bool
+	// DataSource is fetched into Source when the environment starts.
+	DataSource DataSource
 }
 
 type RStudioServerConfig struct {
@@ -114,6 +185,22 @@ type ExposeItem struct {
 	ServiceName string
 }
 
+// DaemonConfig is a long-running process supervised in the container.
+type DaemonConfig struct {
+	// Name is generated from the command, e.g. streamlit.
+	Name        string
+	Commands    []string
+	Restart     string
+	Healthcheck *HealthcheckConfig
+}
+
+type HealthcheckConfig struct {
+	Commands []string
+	Interval time.Duration
+	Timeout  time.Duration
+	Retries  int
+}
+
 type JupyterConfig struct {
 	Token string
 	Port  int64
@@ -0,0 +1,84 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// defaultUser is the user of the environment, the sessions of the keys
+// without the user option run as it.
+const defaultUser = "envd"
+
+// userNameRegexp follows the default NAME_REGEX of adduser.
+var userNameRegexp = regexp.MustCompile(`^[a-z][-a-z0-9_]*$`)
+
+// sudoersFile grants envd sudo without the password.
+const sudoersFile = "/etc/sudoers.d/envd"
+
+// reservedUserNames are the users that cannot be declared by config.users.
+var reservedUserNames = map[string]bool{
+	defaultUser: true,
+	"root":      true,
+	"nobody":    true,
+}
+
+// userAddCommands create the user in the group envd, thus the user can read
+// the files shared in the group, and the permissions of the other files,
+// e.g. the mounted directories, are respected. The password is locked, the
+// user can only log in with the keys in the authorized_keys file.
+func userAddCommands(name string) []string {
+	return []string{
+		fmt.Sprintf("useradd -g %s -s /bin/sh -m %s", defaultUser, name),
+		fmt.Sprintf("passwd -l %s", name),
+	}
+}
+
+// lockDefaultUserCommands lock the password of envd, which is empty in the
+// base image, otherwise the users could `su envd` and then use its sudo.
+// envd keeps sudo without the password.
+func lockDefaultUserCommands() []string {
+	return []string{
+		fmt.Sprintf("sh -c \"echo '%s ALL=(ALL) NOPASSWD:ALL' > %s && chmod 0440 %s\"",
+			defaultUser, sudoersFile, sudoersFile),
+		fmt.Sprintf("passwd -l %s", defaultUser),
+	}
+}
+
+// usersCommands returns the commands to create the users declared by
+// config.users.
+func (g Graph) usersCommands() []string {
+	if len(g.Users) == 0 {
+		return nil
+	}
+	commands := lockDefaultUserCommands()
+	for _, name := range g.Users {
+		commands = append(commands, userAddCommands(name)...)
+	}
+	return commands
+}
+
+// compileUsers creates the users declared by config.users.
+func (g Graph) compileUsers(root llb.State) llb.State {
+	for _, c := range g.usersCommands() {
+		root = root.
+			Run(llb.Shlex(c),
+				llb.WithCustomName(fmt.Sprintf("[internal] %s", c))).Root()
+	}
+	return root
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lockfile manages envd.lock, which pins the versions of the
+// packages declared in build.envd.
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// FileName is the name of the lockfile. It is placed next to build.envd.
+	FileName = "envd.lock"
+	// Version is the current version of the lockfile format.
+	Version = 1
+)
+
+// Package is a package pinned to a version.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Requirements are the packages declared in the manifest.
+type Requirements struct {
+	Python []string `json:"python,omitempty"`
+	// RequirementsFile is the path to the requirements file relative to
+	// the build context, and Digest is the sha256 of its content.
+	RequirementsFile *string  `json:"requirements_file,omitempty"`
+	Digest           *string  `json:"requirements_digest,omitempty"`
+	Conda            []string `json:"conda,omitempty"`
+	R                []string `json:"r,omitempty"`
+	Julia            []string `json:"julia,omitempty"`
+}
+
+// File is the content of envd.lock.
+type File struct {
+	Version int `json:"version"`
+	// Requirements are the packages declared in the manifest when locking.
+	// They are used to detect whether the lockfile is out of date.
+	Requirements Requirements `json:"requirements"`
+
+	// Python are all the packages in the python environment, they are used
+	// as the constraints of pip.
+	Python []Package `json:"python,omitempty"`
+	Conda  []Package `json:"conda,omitempty"`
+	R      []Package `json:"r,omitempty"`
+	Julia  []Package `json:"julia,omitempty"`
+}
+
+// Path returns the path of the lockfile in the given directory.
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// Load reads the lockfile from the directory. It returns nil if the
+// lockfile does not exist.
+func Load(dir string) (*File, error) {
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %s", Path(dir))
+	}
+	f := &File{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", Path(dir))
+	}
+	if f.Version != Version {
+		return nil, errors.Newf("unsupported lockfile version %d, please run `envd lock` again", f.Version)
+	}
+	return f, nil
+}
+
+// Save writes the lockfile to the directory.
+func (f File) Save(dir string) error {
+	f.Version = Version
+	for _, pkgs := range [][]Package{f.Python, f.Conda, f.R, f.Julia} {
+		sort.Slice(pkgs, func(i, j int) bool {
+			return pkgs[i].Name < pkgs[j].Name
+		})
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the lockfile")
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(Path(dir), data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", Path(dir))
+	}
+	return nil
+}
+
+// Check returns an error if the requirements declared in the manifest
+// are different from the ones recorded in the lockfile.
+func (f File) Check(r Requirements) error {
+	diffs := []string{}
+	diffs = append(diffs, diff("python", f.Requirements.Python, r.Python)...)
+	diffs = append(diffs, diff("conda", f.Requirements.Conda, r.Conda)...)
+	diffs = append(diffs, diff("r", f.Requirements.R, r.R)...)
+	diffs = append(diffs, diff("julia", f.Requirements.Julia, r.Julia)...)
+	if value(f.Requirements.RequirementsFile) != value(r.RequirementsFile) ||
+		value(f.Requirements.Digest) != value(r.Digest) {
+		diffs = append(diffs, "python: the requirements file is changed")
+	}
+	if len(diffs) != 0 {
+		return errors.Newf("%s is out of date: %s", FileName, strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// Pinned returns the locked version of the package declared by spec,
+// e.g. `numpy>=1.20`.
+func Pinned(pkgs []Package, spec string) (string, bool) {
+	name := PackageName(spec)
+	for _, pkg := range pkgs {
+		if strings.EqualFold(pkg.Name, name) {
+			return pkg.Version, true
+		}
+	}
+	return "", false
+}
+
+// PackageName returns the name of the package declared by spec.
+func PackageName(spec string) string {
+	spec = strings.TrimSpace(spec)
+	if i := strings.IndexAny(spec, "=<>!~[@; "); i != -1 {
+		spec = spec[:i]
+	}
+	return spec
+}
+
+func diff(kind string, locked, declared []string) []string {
+	res := []string{}
+	lockedSet := make(map[string]bool)
+	for _, pkg := range locked {
+		lockedSet[pkg] = true
+	}
+	declaredSet := make(map[string]bool)
+	for _, pkg := range declared {
+		declaredSet[pkg] = true
+		if !lockedSet[pkg] {
+			res = append(res, fmt.Sprintf("%s: %s is not locked", kind, pkg))
+		}
+	}
+	for _, pkg := range locked {
+		if !declaredSet[pkg] {
+			res = append(res, fmt.Sprintf("%s: %s is no longer declared", kind, pkg))
+		}
+	}
+	return res
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("lockfile", func() {
+	It("should return nil if the lockfile does not exist", func() {
+		dir, err := os.MkdirTemp("", "envd-lock")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		f, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(BeNil())
+	})
+
+	It("should save and load the lockfile", func() {
+		dir, err := os.MkdirTemp("", "envd-lock")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		f := File{
+			Requirements: Requirements{Python: []string{"numpy"}},
+			Python: []Package{
+				{Name: "six", Version: "1.16.0"},
+				{Name: "numpy", Version: "1.23.1"},
+			},
+		}
+		Expect(f.Save(dir)).To(Succeed())
+		loaded, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Version).To(Equal(Version))
+		Expect(loaded.Python[0].Name).To(Equal("numpy"))
+		Expect(loaded.Requirements.Python).To(Equal([]string{"numpy"}))
+	})
+
+	It("should detect the out of date lockfile", func() {
+		f := File{Requirements: Requirements{
+			Python: []string{"numpy", "torch"},
+			R:      []string{"dplyr"},
+		}}
+		Expect(f.Check(Requirements{
+			Python: []string{"torch", "numpy"},
+			R:      []string{"dplyr"},
+		})).To(Succeed())
+
+		err := f.Check(Requirements{Python: []string{"numpy", "jax"}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("python: jax is not locked"))
+		Expect(err.Error()).To(ContainSubstring("python: torch is no longer declared"))
+		Expect(err.Error()).To(ContainSubstring("r: dplyr is no longer declared"))
+
+		digest := "sha256:1234"
+		path := "requirements.txt"
+		err = f.Check(Requirements{
+			Python:           []string{"numpy", "torch"},
+			R:                []string{"dplyr"},
+			RequirementsFile: &path,
+			Digest:           &digest,
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should get the pinned version", func() {
+		pkgs := []Package{{Name: "PyYAML", Version: "6.0"}}
+		version, ok := Pinned(pkgs, "pyyaml>=5.0")
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("6.0"))
+		_, ok = Pinned(pkgs, "numpy")
+		Expect(ok).To(BeFalse())
+		Expect(PackageName("torch[cuda]==1.12")).To(Equal("torch"))
+	})
+
+	It("should resolve the packages from the image", func() {
+		run := func(ctx context.Context, cmd []string) (string, error) {
+			switch filepath.Base(cmd[0]) {
+			case "python":
+				return "numpy==1.23.1\nsix==1.16.0\nfoo @ file:///tmp/foo\n", nil
+			case "conda":
+				return "# platform: linux-64\nnumpy=1.23.1=py39h\npython=3.9.12=h12\n", nil
+			case "Rscript":
+				return "dplyr 1.0.9\nbase 4.2.1\n", nil
+			}
+			return "", errors.Newf("unexpected command %v", cmd)
+		}
+		f, err := Resolve(context.Background(), run, Requirements{
+			Python: []string{"numpy"},
+			Conda:  []string{"numpy>=1.20"},
+			R:      []string{"dplyr"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Python).To(Equal([]Package{
+			{Name: "numpy", Version: "1.23.1"},
+			{Name: "six", Version: "1.16.0"},
+		}))
+		Expect(f.Conda).To(Equal([]Package{{Name: "numpy", Version: "1.23.1"}}))
+		Expect(f.R).To(Equal([]Package{{Name: "dplyr", Version: "1.0.9"}}))
+		Expect(f.Julia).To(BeEmpty())
+	})
+})
@@ -0,0 +1,122 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockfile
+
+import (
+	"bufio"
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Runner runs the command in the built image and returns the stdout.
+type Runner func(ctx context.Context, cmd []string) (string, error)
+
+var (
+	pipFreezeCommand = []string{
+		"/opt/conda/envs/envd/bin/python", "-m", "pip", "list", "--format=freeze",
+	}
+	condaListCommand = []string{
+		"/opt/conda/bin/conda", "list", "-n", "envd", "--export",
+	}
+	rListCommand = []string{
+		"Rscript", "-e", `ip <- installed.packages()[, c("Package", "Version")]; ` +
+			`write.table(ip, row.names = FALSE, col.names = FALSE, quote = FALSE)`,
+	}
+	juliaListCommand = []string{
+		"/usr/local/julia/bin/julia", "-e", `using Pkg; for (_, p) in Pkg.dependencies(); ` +
+			`p.is_direct_dep && p.version !== nothing && println(p.name, " ", p.version); end`,
+	}
+)
+
+// Resolve gets the versions of the required packages from the built image.
+func Resolve(ctx context.Context, run Runner, r Requirements) (*File, error) {
+	f := &File{
+		Version:      Version,
+		Requirements: r,
+	}
+	if len(r.Python) != 0 || r.RequirementsFile != nil {
+		out, err := run(ctx, pipFreezeCommand)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the python packages")
+		}
+		f.Python = parse(out, "==")
+	}
+	if len(r.Conda) != 0 {
+		out, err := run(ctx, condaListCommand)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the conda packages")
+		}
+		f.Conda = filter(parse(out, "="), r.Conda)
+	}
+	if len(r.R) != 0 {
+		out, err := run(ctx, rListCommand)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the R packages")
+		}
+		f.R = filter(parse(out, " "), r.R)
+	}
+	if len(r.Julia) != 0 {
+		out, err := run(ctx, juliaListCommand)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the julia packages")
+		}
+		f.Julia = filter(parse(out, " "), r.Julia)
+	}
+	logrus.WithFields(logrus.Fields{
+		"python": len(f.Python),
+		"conda":  len(f.Conda),
+		"r":      len(f.R),
+		"julia":  len(f.Julia),
+	}).Debug("resolved the locked packages")
+	return f, nil
+}
+
+// parse parses the `name<sep>version` lines. The lines which are not in the
+// format, e.g. comments and packages installed from URLs, are skipped.
+func parse(out, sep string) []Package {
+	pkgs := []Package{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, sep, 3)
+		if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:    strings.TrimSpace(fields[0]),
+			Version: strings.TrimSpace(fields[1]),
+		})
+	}
+	return pkgs
+}
+
+// filter keeps the packages that are declared in the manifest.
+func filter(pkgs []Package, specs []string) []Package {
+	res := []Package{}
+	for _, spec := range specs {
+		if version, ok := Pinned(pkgs, spec); ok {
+			res = append(res, Package{Name: PackageName(spec), Version: version})
+		} else {
+			logrus.Warnf("failed to find the version of %s in the image", spec)
+		}
+	}
+	return res
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/tensorchord/envd/pkg/editor/vscode"
+	"github.com/tensorchord/envd/pkg/progress/progressjson"
 )
 
 type Writer interface {
@@ -57,6 +58,10 @@ func New(ctx context.Context, out console.File, mode string) (Writer, error) {
 			}
 		}
 	case "plain":
+	case "json":
+		return jsonWriter{
+			action: progressjson.NewAction(progressjson.NewEncoder(out)),
+		}, nil
 	default:
 		return nil, errors.Errorf("invalid progress mode %s", mode)
 	}
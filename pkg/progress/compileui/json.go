@@ -0,0 +1,52 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compileui
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/tensorchord/envd/pkg/editor/vscode"
+	"github.com/tensorchord/envd/pkg/progress/progressjson"
+)
+
+const zshActionID = "oh-my-zsh"
+
+// jsonWriter emits the compile actions as newline-delimited JSON events.
+type jsonWriter struct {
+	action *progressjson.Action
+}
+
+func (w jsonWriter) LogVSCodePlugin(p vscode.Plugin, action Action, cached bool) {
+	w.log(p.String(), "download "+p.String(), action, cached)
+}
+
+func (w jsonWriter) LogZSH(action Action, cached bool) {
+	w.log(zshActionID, "download oh-my-zsh", action, cached)
+}
+
+func (w jsonWriter) Finish() {}
+
+func (w jsonWriter) log(id, name string, action Action, cached bool) {
+	var err error
+	switch action {
+	case ActionStart:
+		err = w.action.Start(id, name)
+	case ActionEnd:
+		err = w.action.End(id, name, cached)
+	}
+	if err != nil {
+		logrus.WithError(err).Debug("failed to write the progress event")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressjson
+
+import (
+	"sync"
+	"time"
+)
+
+// Action encodes the start and the end of the compile actions.
+type Action struct {
+	enc     *Encoder
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+func NewAction(enc *Encoder) *Action {
+	return &Action{
+		enc:     enc,
+		started: map[string]time.Time{},
+	}
+}
+
+func (a *Action) Start(id, name string) error {
+	now := time.Now()
+	a.mu.Lock()
+	a.started[id] = now
+	a.mu.Unlock()
+	return a.enc.Encode(Event{
+		Time:   now,
+		Source: SourceCompile,
+		Type:   EventStart,
+		ID:     id,
+		Name:   name,
+	})
+}
+
+func (a *Action) End(id, name string, cached bool) error {
+	now := time.Now()
+	ev := Event{
+		Time:   now,
+		Source: SourceCompile,
+		Type:   EventComplete,
+		ID:     id,
+		Name:   name,
+	}
+	if cached {
+		ev.Type = EventCached
+	}
+	a.mu.Lock()
+	start, ok := a.started[id]
+	a.mu.Unlock()
+	if ok {
+		ev.Duration = duration(&start, &now)
+	}
+	return a.enc.Encode(ev)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progressjson encodes the build progress as newline-delimited JSON
+// events, which are consumed by CI systems instead of the TTY display.
+package progressjson
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type Source string
+
+const (
+	// SourceBuildKit is the source of the events of the BuildKit vertexes.
+	SourceBuildKit Source = "buildkit"
+	// SourceCompile is the source of the events of the compile actions,
+	// e.g. downloading VS Code plugins and oh-my-zsh.
+	SourceCompile Source = "compile"
+)
+
+type EventType string
+
+const (
+	EventStart    EventType = "start"
+	EventComplete EventType = "complete"
+	EventCached   EventType = "cached"
+	EventError    EventType = "error"
+	EventLog      EventType = "log"
+)
+
+// Event is a line of the JSON progress output.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Source Source    `json:"source"`
+	Type   EventType `json:"type"`
+	// ID is the digest of the BuildKit vertex, or the name of the action.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Duration is the duration in seconds of the completed step.
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// Stream is 1 for stdout and 2 for stderr of the logs.
+	Stream int    `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+}
+
+// Encoder writes the events as newline-delimited JSON. It is safe for
+// concurrent use, the BuildKit and compile events may share the output.
+type Encoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: json.NewEncoder(w)}
+}
+
+func (e *Encoder) Encode(ev Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(ev)
+}
+
+func duration(start, end *time.Time) float64 {
+	if start == nil || end == nil {
+		return 0
+	}
+	return end.Sub(*start).Seconds()
+}
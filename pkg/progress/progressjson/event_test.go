@@ -0,0 +1,95 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []Event {
+	events := []Event{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var ev Event
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestDisplaySolveStatus(t *testing.T) {
+	start := time.Unix(100, 0)
+	end := time.Unix(103, 0)
+	run := digest.FromString("run")
+	cached := digest.FromString("cached")
+	failed := digest.FromString("failed")
+
+	ch := make(chan *client.SolveStatus, 4)
+	ch <- &client.SolveStatus{Vertexes: []*client.Vertex{
+		{Digest: run, Name: "RUN pip install", Started: &start},
+		{Digest: cached, Name: "COPY", Started: &start, Completed: &start, Cached: true},
+	}}
+	ch <- &client.SolveStatus{
+		// The started vertex is sent again without changes.
+		Vertexes: []*client.Vertex{{Digest: run, Name: "RUN pip install", Started: &start}},
+		Logs: []*client.VertexLog{
+			{Vertex: run, Stream: 1, Data: []byte("Collecting numpy\n"), Timestamp: start},
+		},
+	}
+	ch <- &client.SolveStatus{Vertexes: []*client.Vertex{
+		{Digest: run, Name: "RUN pip install", Started: &start, Completed: &end},
+		{Digest: failed, Name: "RUN false", Started: &start, Completed: &end, Error: "exit code: 1"},
+	}}
+	close(ch)
+
+	var buf bytes.Buffer
+	require.NoError(t, DisplaySolveStatus(context.Background(), NewEncoder(&buf), ch))
+
+	events := decode(t, &buf)
+	types := []EventType{}
+	for _, ev := range events {
+		require.Equal(t, SourceBuildKit, ev.Source)
+		types = append(types, ev.Type)
+	}
+	require.Equal(t, []EventType{
+		EventStart, EventStart, EventCached, EventLog,
+		EventComplete, EventStart, EventError,
+	}, types)
+	require.Equal(t, "RUN pip install", events[3].Name)
+	require.Equal(t, "Collecting numpy\n", events[3].Data)
+	require.Equal(t, 3.0, events[4].Duration)
+	require.Equal(t, "exit code: 1", events[6].Error)
+}
+
+func TestAction(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAction(NewEncoder(&buf))
+	require.NoError(t, a.Start("oh-my-zsh", "download oh-my-zsh"))
+	require.NoError(t, a.End("oh-my-zsh", "download oh-my-zsh", true))
+
+	events := decode(t, &buf)
+	require.Len(t, events, 2)
+	require.Equal(t, EventStart, events[0].Type)
+	require.Equal(t, EventCached, events[1].Type)
+	require.Equal(t, SourceCompile, events[1].Source)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressjson
+
+import (
+	"context"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// vertexState records the events emitted for a vertex, since BuildKit
+// sends the vertex again on every update.
+type vertexState struct {
+	started bool
+	done    bool
+}
+
+// DisplaySolveStatus encodes the events of the vertexes until the channel
+// is closed.
+func DisplaySolveStatus(ctx context.Context, enc *Encoder, ch chan *client.SolveStatus) error {
+	vertexes := map[digest.Digest]*vertexState{}
+	names := map[digest.Digest]string{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ss, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			for _, v := range ss.Vertexes {
+				names[v.Digest] = v.Name
+				st, ok := vertexes[v.Digest]
+				if !ok {
+					st = &vertexState{}
+					vertexes[v.Digest] = st
+				}
+				for _, ev := range vertexEvents(v, st) {
+					if err := enc.Encode(ev); err != nil {
+						return err
+					}
+				}
+			}
+			for _, l := range ss.Logs {
+				if err := enc.Encode(Event{
+					Time:   l.Timestamp,
+					Source: SourceBuildKit,
+					Type:   EventLog,
+					ID:     l.Vertex.String(),
+					Name:   names[l.Vertex],
+					Stream: l.Stream,
+					Data:   string(l.Data),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func vertexEvents(v *client.Vertex, st *vertexState) []Event {
+	if st.done {
+		return nil
+	}
+	base := Event{
+		Source: SourceBuildKit,
+		ID:     v.Digest.String(),
+		Name:   v.Name,
+	}
+	events := []Event{}
+	if v.Started != nil && !st.started {
+		st.started = true
+		ev := base
+		ev.Time = *v.Started
+		ev.Type = EventStart
+		events = append(events, ev)
+	}
+	if !v.Cached && v.Completed == nil {
+		return events
+	}
+	st.done = true
+	ev := base
+	ev.Time = time.Now()
+	if v.Completed != nil {
+		ev.Time = *v.Completed
+	}
+	switch {
+	case v.Error != "":
+		ev.Type = EventError
+		ev.Error = v.Error
+	case v.Cached:
+		ev.Type = EventCached
+	default:
+		ev.Type = EventComplete
+	}
+	ev.Duration = duration(v.Started, v.Completed)
+	return append(events, ev)
+}
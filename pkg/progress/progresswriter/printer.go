@@ -23,6 +23,7 @@ import (
 	"github.com/containerd/console"
 	"github.com/moby/buildkit/client"
 
+	"github.com/tensorchord/envd/pkg/progress/progressjson"
 	"github.com/tensorchord/envd/pkg/progress/progressui"
 )
 
@@ -97,6 +98,12 @@ func NewPrinter(ctx context.Context, out console.File, mode string) (Writer, err
 			}
 		}
 	case "plain":
+	case "json":
+		go func() {
+			pw.err = progressjson.DisplaySolveStatus(ctx, progressjson.NewEncoder(out), statusCh)
+			close(doneCh)
+		}()
+		return pw, nil
 	default:
 		return nil, errors.Errorf("invalid progress mode %s", mode)
 	}
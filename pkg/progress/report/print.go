@@ -0,0 +1,80 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const maxNameLength = 60
+
+// Print renders the summary of the report.
+func Print(w io.Writer, r Report) {
+	fmt.Fprintf(w, "\nBuild report of %s: %.1fs, %d steps cached, %d steps executed\n",
+		r.Tag, r.Duration, r.Cached, r.Executed)
+	if r.InvalidatedBy != "" {
+		fmt.Fprintf(w, "The cache of the previous build is invalidated by %s (changed: %s)\n",
+			r.InvalidatedBy, strings.Join(r.ChangedRules, ", "))
+	}
+
+	fmt.Fprint(w, "\nTime in the package managers:\n")
+	table := newTable(w)
+	table.SetHeader([]string{"category", "duration"})
+	for _, c := range []Category{CategoryAPT, CategoryPyPI, CategoryConda, CategoryR} {
+		table.Append([]string{string(c), fmt.Sprintf("%.1fs", r.Categories[c])})
+	}
+	table.Render()
+
+	if len(r.Slowest) == 0 {
+		return
+	}
+	fmt.Fprint(w, "\nSlowest steps:\n")
+	table = newTable(w)
+	table.SetHeader([]string{"step", "category", "duration"})
+	for _, v := range r.Slowest {
+		table.Append([]string{
+			truncate(v.Name, maxNameLength), string(v.Category), fmt.Sprintf("%.1fs", v.Duration),
+		})
+	}
+	table.Render()
+}
+
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	return table
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report summarizes the timings and the cache efficiency of a build
+// from the BuildKit solve status.
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+// Category is the package manager of a step.
+type Category string
+
+const (
+	CategoryAPT   Category = "apt"
+	CategoryPyPI  Category = "pip"
+	CategoryConda Category = "conda"
+	CategoryR     Category = "R"
+	CategoryOther Category = "other"
+)
+
+// categoryKeywords are matched against the vertex names in order, e.g.
+// `conda run pip install` is a pip step.
+var categoryKeywords = []struct {
+	category Category
+	keywords []string
+}{
+	{CategoryAPT, []string{"apt-get ", "apt "}},
+	{CategoryPyPI, []string{"pip install", "pip3 install"}},
+	{CategoryConda, []string{"conda install", "conda create", "mamba install", "mamba create"}},
+	{CategoryR, []string{"R -e", "Rscript", "install.packages"}},
+}
+
+// DefaultSlowest is the number of the slowest steps in the report.
+const DefaultSlowest = 10
+
+type Vertex struct {
+	Digest   string   `json:"digest"`
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration"`
+	Cached   bool    `json:"cached"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Tag string `json:"tag"`
+	// Duration is the wall time of the build in seconds.
+	Duration float64 `json:"duration"`
+	Cached   int     `json:"cached"`
+	Executed int     `json:"executed"`
+	// Categories is the total time in seconds of the executed steps of
+	// each package manager.
+	Categories map[Category]float64 `json:"categories"`
+	Slowest    []Vertex             `json:"slowest"`
+	// InvalidatedBy is the first rule which differs from the previous build
+	// of the same tag, the steps after it are not cached.
+	InvalidatedBy string `json:"invalidated_by,omitempty"`
+	// ChangedRules are all the rules which differ from the previous build.
+	ChangedRules []string `json:"changed_rules,omitempty"`
+	Vertexes     []Vertex `json:"vertexes"`
+}
+
+// Collector collects the vertexes from the solve status.
+type Collector struct {
+	order    []digest.Digest
+	vertexes map[digest.Digest]*client.Vertex
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		vertexes: map[digest.Digest]*client.Vertex{},
+	}
+}
+
+// Collect consumes the channel until it is closed.
+func (c *Collector) Collect(ch chan *client.SolveStatus) {
+	for ss := range ch {
+		c.Add(ss)
+	}
+}
+
+// Add records the latest state of the vertexes.
+func (c *Collector) Add(ss *client.SolveStatus) {
+	for _, v := range ss.Vertexes {
+		if _, ok := c.vertexes[v.Digest]; !ok {
+			c.order = append(c.order, v.Digest)
+		}
+		c.vertexes[v.Digest] = v
+	}
+}
+
+// Report summarizes the collected vertexes.
+func (c *Collector) Report(tag string, slowest int) Report {
+	r := Report{
+		Tag:        tag,
+		Categories: map[Category]float64{},
+		Vertexes:   []Vertex{},
+		Slowest:    []Vertex{},
+	}
+	var start, end *time.Time
+	for _, d := range c.order {
+		v := c.vertexes[d]
+		if v.Started != nil && (start == nil || v.Started.Before(*start)) {
+			start = v.Started
+		}
+		if v.Completed != nil && (end == nil || v.Completed.After(*end)) {
+			end = v.Completed
+		}
+		rv := Vertex{
+			Digest:   d.String(),
+			Name:     v.Name,
+			Category: categorize(v.Name),
+			Cached:   v.Cached,
+			Error:    v.Error,
+		}
+		if v.Started != nil && v.Completed != nil {
+			rv.Duration = v.Completed.Sub(*v.Started).Seconds()
+		}
+		if rv.Cached {
+			r.Cached++
+		} else {
+			r.Executed++
+			r.Categories[rv.Category] += rv.Duration
+		}
+		r.Vertexes = append(r.Vertexes, rv)
+	}
+	if start != nil && end != nil {
+		r.Duration = end.Sub(*start).Seconds()
+	}
+
+	executed := []Vertex{}
+	for _, v := range r.Vertexes {
+		if !v.Cached {
+			executed = append(executed, v)
+		}
+	}
+	sort.SliceStable(executed, func(i, j int) bool {
+		return executed[i].Duration > executed[j].Duration
+	})
+	if len(executed) > slowest {
+		executed = executed[:slowest]
+	}
+	r.Slowest = executed
+	return r
+}
+
+// Compare records the rules which differ from the previous build.
+func (r *Report) Compare(previous, current []types.RuleDigest) {
+	if previous == nil {
+		return
+	}
+	// The rules are compared by the position among the rules of the same
+	// name, thus adding a rule does not mark the following ones as changed.
+	seen := map[string]int{}
+	prev := map[string][]string{}
+	for _, d := range previous {
+		prev[d.Rule] = append(prev[d.Rule], d.Digest)
+	}
+	for _, d := range current {
+		i := seen[d.Rule]
+		seen[d.Rule]++
+		if i < len(prev[d.Rule]) && prev[d.Rule][i] == d.Digest {
+			continue
+		}
+		if r.InvalidatedBy == "" {
+			r.InvalidatedBy = d.Rule
+		}
+		r.ChangedRules = append(r.ChangedRules, d.Rule)
+	}
+}
+
+// WriteFile saves the report as JSON.
+func (r Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the build report")
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write the build report to %s", path)
+	}
+	return nil
+}
+
+func categorize(name string) Category {
+	for _, c := range categoryKeywords {
+		for _, k := range c.keywords {
+			if strings.Contains(name, k) {
+				return c.category
+			}
+		}
+	}
+	return CategoryOther
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tensorchord/envd/pkg/types"
+)
+
+func vertex(name string, start, end int64, cached bool) *client.Vertex {
+	started := time.Unix(start, 0)
+	completed := time.Unix(end, 0)
+	return &client.Vertex{
+		Digest:    digest.FromString(name),
+		Name:      name,
+		Started:   &started,
+		Completed: &completed,
+		Cached:    cached,
+	}
+}
+
+func TestReport(t *testing.T) {
+	c := NewCollector()
+	c.Add(&client.SolveStatus{Vertexes: []*client.Vertex{
+		{Digest: digest.FromString("apt-get install -y git"), Name: "apt-get install -y git"},
+	}})
+	c.Add(&client.SolveStatus{Vertexes: []*client.Vertex{
+		vertex("apt-get install -y git", 0, 20, false),
+		vertex("pip install numpy", 20, 50, false),
+		vertex("conda install -y pytorch", 20, 30, false),
+		vertex("copy /envd/bin", 0, 0, true),
+	}})
+
+	r := c.Report("mnist:dev", 2)
+	require.Equal(t, 50.0, r.Duration)
+	require.Equal(t, 1, r.Cached)
+	require.Equal(t, 3, r.Executed)
+	require.Equal(t, 20.0, r.Categories[CategoryAPT])
+	require.Equal(t, 30.0, r.Categories[CategoryPyPI])
+	require.Equal(t, 10.0, r.Categories[CategoryConda])
+	require.Len(t, r.Slowest, 2)
+	require.Equal(t, "pip install numpy", r.Slowest[0].Name)
+	require.Equal(t, "apt-get install -y git", r.Slowest[1].Name)
+	require.Len(t, r.Vertexes, 4)
+}
+
+func TestCompare(t *testing.T) {
+	previous := []types.RuleDigest{
+		{Rule: "base", Digest: "a"},
+		{Rule: "install.python_packages", Digest: "b"},
+		{Rule: "run", Digest: "c"},
+	}
+	for _, tc := range []struct {
+		name          string
+		previous      []types.RuleDigest
+		current       []types.RuleDigest
+		invalidatedBy string
+		changed       []string
+	}{
+		{
+			name:     "no previous build",
+			previous: nil,
+			current:  previous,
+		},
+		{
+			name:     "unchanged",
+			previous: previous,
+			current:  previous,
+		},
+		{
+			name:     "changed",
+			previous: previous,
+			current: []types.RuleDigest{
+				{Rule: "base", Digest: "a"},
+				{Rule: "install.python_packages", Digest: "x"},
+				{Rule: "run", Digest: "y"},
+			},
+			invalidatedBy: "install.python_packages",
+			changed:       []string{"install.python_packages", "run"},
+		},
+		{
+			name:     "added",
+			previous: previous,
+			current: []types.RuleDigest{
+				{Rule: "base", Digest: "a"},
+				{Rule: "install.system_packages", Digest: "d"},
+				{Rule: "install.python_packages", Digest: "b"},
+				{Rule: "run", Digest: "c"},
+			},
+			invalidatedBy: "install.system_packages",
+			changed:       []string{"install.system_packages"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := Report{}
+			r.Compare(tc.previous, tc.current)
+			require.Equal(t, tc.invalidatedBy, r.InvalidatedBy)
+			require.Equal(t, tc.changed, r.ChangedRules)
+		})
+	}
+}
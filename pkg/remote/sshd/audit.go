@@ -0,0 +1,149 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gliderlabs/ssh"
+	"github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/tensorchord/envd/pkg/util/asciicast"
+)
+
+// AuditRecord is a line in the audit log, written when the session ends.
+type AuditRecord struct {
+	SessionID string `json:"session_id"`
+	User      string `json:"user"`
+	// RunAs is the Linux user running the session, empty for the user of
+	// envd-ssh.
+	RunAs       string    `json:"run_as,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Command     string    `json:"command,omitempty"`
+	PTY         bool      `json:"pty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	ExitStatus  int       `json:"exit_status"`
+	// Recording is the asciicast file of the PTY session.
+	Recording string `json:"recording,omitempty"`
+}
+
+// Auditor writes the audit log of the sessions in JSON lines, and records
+// the PTY sessions if RecordingDir is not empty.
+type Auditor struct {
+	Path         string
+	RecordingDir string
+
+	mu sync.Mutex
+}
+
+func newAuditRecord(sessionID string, s ssh.Session) AuditRecord {
+	r := AuditRecord{
+		SessionID:  sessionID,
+		User:       s.User(),
+		RemoteAddr: s.RemoteAddr().String(),
+		Command:    s.RawCommand(),
+		Start:      time.Now(),
+	}
+	if key := s.PublicKey(); key != nil {
+		r.Fingerprint = gossh.FingerprintSHA256(key)
+	}
+	return r
+}
+
+// Log appends the record to the audit log.
+func (a *Auditor) Log(r AuditRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(a.Path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the directory of the audit log")
+	}
+	f, err := os.OpenFile(a.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the audit log")
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// recordingPath returns the path of the recording of the session.
+func (a *Auditor) recordingPath(sessionID string) string {
+	return filepath.Join(a.RecordingDir, sessionID+".cast")
+}
+
+// ReadAuditLog parses the audit log.
+func ReadAuditLog(data []byte) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		r := AuditRecord{}
+		if err := dec.Decode(&r); err != nil {
+			return nil, errors.Wrap(err, "failed to parse the audit log")
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// openRecording creates the recording of the PTY session.
+func (a *Auditor) openRecording(sessionID string, ptyReq ssh.Pty,
+	shell string) (*os.File, *asciicast.Recorder, error) {
+	if err := os.MkdirAll(a.RecordingDir, 0755); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create the recording directory")
+	}
+	f, err := os.OpenFile(a.recordingPath(sessionID), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create the recording")
+	}
+	rec, err := asciicast.NewRecorder(f, ptyReq.Window.Width, ptyReq.Window.Height,
+		map[string]string{"TERM": ptyReq.Term, "SHELL": shell})
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, rec, nil
+}
+
+// recordingWriter ignores the errors of the recording, thus the session is
+// not interrupted by them.
+type recordingWriter struct {
+	logger *logrus.Entry
+	rec    *asciicast.Recorder
+	failed bool
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		return len(p), nil
+	}
+	if _, err := w.rec.Write(p); err != nil {
+		w.logger.WithError(err).Warn("failed to record the session")
+		w.failed = true
+	}
+	return len(p), nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gliderlabs/ssh"
+	"github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// keyOptionUser is the option in the authorized_keys file to run the
+// sessions as the user, e.g. `user="alice" ssh-ed25519 AAAA... alice@laptop`.
+const keyOptionUser = "user"
+
+// AuthorizedKeysCommand is the envd-ssh command to replace the authorized_keys
+// file. It runs as root, since the file is owned by root if the sessions run
+// as the users.
+const AuthorizedKeysCommand = "authorized-keys"
+
+// AuthorizedKey is a key in the authorized_keys file. The sessions
+// authenticated by the key run as User if it is not empty. The user may be a
+// person, or a role shared by several keys.
+type AuthorizedKey struct {
+	Key  ssh.PublicKey
+	User string
+}
+
+// ParseAuthorizedKeys parses the keys in the authorized_keys format.
+func ParseAuthorizedKeys(data []byte) ([]AuthorizedKey, error) {
+	keys := []AuthorizedKey{}
+	for len(data) > 0 {
+		pubKey, _, options, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, err
+		}
+		k := AuthorizedKey{Key: pubKey}
+		for _, o := range options {
+			name, value, ok := strings.Cut(o, "=")
+			if !ok || name != keyOptionUser {
+				continue
+			}
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			k.User = value
+		}
+		keys = append(keys, k)
+		data = rest
+	}
+	return keys, nil
+}
+
+// FormatAuthorizedKey returns the line of the key in the authorized_keys
+// file, annotated with the user if it is not empty.
+func FormatAuthorizedKey(key ssh.PublicKey, user, comment string) string {
+	line := strings.TrimSuffix(string(gossh.MarshalAuthorizedKey(key)), "\n")
+	if comment != "" {
+		line += " " + comment
+	}
+	if user != "" {
+		line = keyOptionUser + "=" + strconv.Quote(user) + " " + line
+	}
+	return line
+}
+
+// LoadAuthorizedKeys loads path as an array.
+// It will return nil if path doesn't exist.
+func LoadAuthorizedKeys(path string) ([]AuthorizedKey, error) {
+	authorizedKeysBytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	authorizedKeys, err := ParseAuthorizedKeys(authorizedKeysBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authorizedKeys) == 0 {
+		return nil, errors.New("no keys found")
+	}
+
+	return authorizedKeys, nil
+}
+
+// keyring reloads the authorized_keys file when it is modified, thus the
+// keys added by `envd envs share` take effect without restarting the server.
+type keyring struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	keys    []AuthorizedKey
+}
+
+func (k *keyring) get() []AuthorizedKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	info, err := os.Stat(k.path)
+	if err != nil || info.ModTime().Equal(k.modTime) {
+		return k.keys
+	}
+	keys, err := LoadAuthorizedKeys(k.path)
+	if err != nil {
+		logrus.WithError(err).Warnf("failed to reload the authorized keys at %s", k.path)
+		return k.keys
+	}
+	logrus.Debugf("loaded %d authorized keys from %s", len(keys), k.path)
+	k.keys = keys
+	k.modTime = info.ModTime()
+	return k.keys
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package sshd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newSigner() gossh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	signer, err := gossh.NewSignerFromKey(priv)
+	Expect(err).NotTo(HaveOccurred())
+	return signer
+}
+
+var _ = Describe("authorized keys", func() {
+	It("parses the user option", func() {
+		owner, alice := newSigner(), newSigner()
+		data := FormatAuthorizedKey(owner.PublicKey(), "", "envd") + "\n" +
+			FormatAuthorizedKey(alice.PublicKey(), "alice", "alice@laptop") + "\n"
+		Expect(data).To(ContainSubstring(`user="alice" ssh-ed25519 `))
+
+		keys, err := ParseAuthorizedKeys([]byte(data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0].User).To(BeEmpty())
+		Expect(keys[0].Key.Marshal()).To(Equal(owner.PublicKey().Marshal()))
+		Expect(keys[1].User).To(Equal("alice"))
+	})
+
+	It("runs the session as the user of the key and reloads the keys", func() {
+		current, err := user.Current()
+		Expect(err).NotTo(HaveOccurred())
+		// The sessions never run as root, root runs them as nobody.
+		collaboratorUser := current.Username
+		if current.Uid == "0" {
+			collaboratorUser = "nobody"
+		}
+		owner, collaborator, intruder := newSigner(), newSigner(), newSigner()
+
+		path := filepath.Join(GinkgoT().TempDir(), "authorized_keys")
+		Expect(os.WriteFile(path,
+			[]byte(FormatAuthorizedKey(owner.PublicKey(), "", "")+"\n"), 0644)).To(Succeed())
+		keys, err := LoadAuthorizedKeys(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		srv := &Server{
+			Shell:              "sh",
+			AuthorizedKeys:     keys,
+			AuthorizedKeysPath: path,
+			AllowedUsers:       []string{collaboratorUser},
+		}
+		server, err := srv.getServer()
+		Expect(err).NotTo(HaveOccurred())
+		go func() {
+			_ = server.Serve(l)
+		}()
+		defer server.Close()
+		port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+		run := func(signer gossh.Signer) (string, error) {
+			cli, err := gossh.Dial("tcp", net.JoinHostPort("127.0.0.1", port), &gossh.ClientConfig{
+				User:            "envd",
+				Auth:            []gossh.AuthMethod{gossh.PublicKeys(signer)},
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			})
+			if err != nil {
+				return "", err
+			}
+			defer cli.Close()
+			sess, err := cli.NewSession()
+			Expect(err).NotTo(HaveOccurred())
+			defer sess.Close()
+			out, err := sess.Output("echo $USER")
+			return strings.TrimSpace(string(out)), err
+		}
+
+		_, err = run(collaborator)
+		Expect(err).To(HaveOccurred())
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString(FormatAuthorizedKey(collaborator.PublicKey(), collaboratorUser, "") + "\n")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString(FormatAuthorizedKey(intruder.PublicKey(), "root", "") + "\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		out, err := run(collaborator)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(collaboratorUser))
+		_, err = run(intruder)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects the keys annotated with the users not allowed", func() {
+		srv := &Server{DefaultUser: "envd", AllowedUsers: []string{"alice"}}
+		Expect(srv.userAllowed("")).To(BeTrue())
+		Expect(srv.userAllowed("envd")).To(BeTrue())
+		Expect(srv.userAllowed("alice")).To(BeTrue())
+		Expect(srv.userAllowed("bob")).To(BeFalse())
+		Expect(srv.userAllowed("root")).To(BeFalse())
+		Expect((&Server{DefaultUser: "root"}).userAllowed("root")).To(BeFalse())
+		Expect((&Server{DefaultUser: "root"}).userAllowed("")).To(BeFalse())
+	})
+
+	It("writes the authorized keys and keeps the mode", func() {
+		owner, alice := newSigner(), newSigner()
+		path := filepath.Join(GinkgoT().TempDir(), "authorized_keys")
+		Expect(os.WriteFile(path,
+			[]byte(FormatAuthorizedKey(owner.PublicKey(), "", "")+"\n"), 0600)).To(Succeed())
+
+		data := FormatAuthorizedKey(owner.PublicKey(), "", "") + "\n" +
+			FormatAuthorizedKey(alice.PublicKey(), "alice", "") + "\n"
+		Expect(WriteAuthorizedKeys(path, []byte(data))).To(Succeed())
+		keys, err := LoadAuthorizedKeys(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		Expect(WriteAuthorizedKeys(path,
+			[]byte(FormatAuthorizedKey(alice.PublicKey(), "root", "")))).To(HaveOccurred())
+		Expect(WriteAuthorizedKeys(path, nil)).To(HaveOccurred())
+		keys, err = LoadAuthorizedKeys(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+	})
+})
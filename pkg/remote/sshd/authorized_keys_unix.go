@@ -0,0 +1,66 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package sshd
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/tensorchord/envd/pkg/util/fileutil"
+)
+
+// WriteAuthorizedKeys replaces the authorized_keys file atomically, thus the
+// running server reloads the whole file. The keys annotated with root are
+// rejected. The mode and the owner of the existing file are kept.
+func WriteAuthorizedKeys(path string, data []byte) error {
+	keys, err := ParseAuthorizedKeys(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the authorized keys")
+	}
+	if len(keys) == 0 {
+		return errors.New("no keys found")
+	}
+	for _, k := range keys {
+		if k.User == "root" {
+			return errors.New("the sessions cannot run as root")
+		}
+	}
+
+	perm := os.FileMode(0644)
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to stat %s", path)
+	}
+	if info != nil {
+		perm = info.Mode().Perm()
+	}
+	if err := fileutil.WriteFileAtomic(path, data, perm); err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := os.Chown(path, int(stat.Uid), int(stat.Gid)); err != nil {
+			return errors.Wrapf(err, "failed to change the owner of %s", path)
+		}
+	}
+	return nil
+}
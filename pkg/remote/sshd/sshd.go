@@ -13,6 +13,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !windows
+// +build !windows
+
 package sshd
 
 import (
@@ -34,45 +37,35 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/sftp"
 	"github.com/sirupsen/logrus"
-)
-
-// LoadAuthorizedKeys loads path as an array.
-// It will return nil if path doesn't exist.
-func LoadAuthorizedKeys(path string) ([]ssh.PublicKey, error) {
-	authorizedKeysBytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-
-		return nil, err
-	}
-
-	authorizedKeys := []ssh.PublicKey{}
-	for len(authorizedKeysBytes) > 0 {
-		pubKey, _, _, rest, err := ssh.ParseAuthorizedKey(authorizedKeysBytes)
-		if err != nil {
-			return nil, err
-		}
-
-		authorizedKeys = append(authorizedKeys, pubKey)
-		authorizedKeysBytes = rest
-	}
-
-	if len(authorizedKeys) == 0 {
-		return nil, errors.New("no keys found")
-	}
+	gossh "golang.org/x/crypto/ssh"
 
-	return authorizedKeys, nil
-}
+	"github.com/tensorchord/envd/pkg/util/asciicast"
+	"github.com/tensorchord/envd/pkg/util/osutil"
+)
 
 // Server holds the ssh server configuration.
 type Server struct {
 	Port           int
 	Shell          string
-	AuthorizedKeys []ssh.PublicKey
+	AuthorizedKeys []AuthorizedKey
+	// AuthorizedKeysPath is reloaded when it is modified if it is not
+	// empty, and the keys in it are used instead of AuthorizedKeys.
+	AuthorizedKeysPath string
+	// DefaultUser runs the sessions of the keys without the user option.
+	// The sessions run as the current user if it is empty.
+	DefaultUser string
+	// AllowedUsers are the users besides DefaultUser that the keys can be
+	// annotated with. The keys annotated with the other users are rejected.
+	AllowedUsers []string
+	// Auditor writes the audit log of the sessions if it is not nil.
+	Auditor *Auditor
+
+	keyring *keyring
 }
 
+// contextKeyUser is the user of the authorized key in the ssh context.
+var contextKeyUser = &struct{ name string }{"envd-user"}
+
 // ListenAndServe starts the SSH server using port
 func (srv *Server) ListenAndServe() error {
 	server, err := srv.getServer()
@@ -105,11 +98,14 @@ func (srv *Server) getServer() (*ssh.Server, error) {
 			"cancel-tcpip-forward": forwardHandler.HandleSSHRequest,
 		},
 		SubsystemHandlers: map[string]ssh.SubsystemHandler{
-			"sftp": sftpHandler,
+			"sftp": srv.sftpHandler,
 		},
 	}
 
-	if srv.AuthorizedKeys != nil {
+	if srv.AuthorizedKeysPath != "" {
+		srv.keyring = &keyring{path: srv.AuthorizedKeysPath, keys: srv.AuthorizedKeys}
+	}
+	if srv.AuthorizedKeys != nil || srv.keyring != nil {
 		server.PublicKeyHandler = srv.authorize
 	} else {
 		server.PublicKeyHandler = nil
@@ -119,7 +115,16 @@ func (srv *Server) getServer() (*ssh.Server, error) {
 	return server, nil
 }
 
-func (srv Server) buildCmd(logger *logrus.Entry, s ssh.Session) *exec.Cmd {
+// sessionUser returns the user to run the session as, or empty to run it as
+// the current user.
+func (srv *Server) sessionUser(s ssh.Session) string {
+	if user, ok := s.Context().Value(contextKeyUser).(string); ok && user != "" {
+		return user
+	}
+	return srv.DefaultUser
+}
+
+func (srv *Server) buildCmd(logger *logrus.Entry, s ssh.Session) (*exec.Cmd, error) {
 	var cmd *exec.Cmd
 
 	if len(s.RawCommand()) == 0 {
@@ -132,8 +137,15 @@ func (srv Server) buildCmd(logger *logrus.Entry, s ssh.Session) *exec.Cmd {
 	cmd.Env = append(cmd.Env, os.Environ()...)
 	cmd.Env = append(cmd.Env, s.Environ()...)
 
+	if user := srv.sessionUser(s); user != "" {
+		if err := osutil.RunAs(cmd, user); err != nil {
+			return nil, err
+		}
+		logger = logger.WithField("user", user)
+	}
+
 	logger.Debugf("ssh server command: %s", cmd.String())
-	return cmd
+	return cmd, nil
 }
 
 func (srv *Server) connectionHandler(s ssh.Session) {
@@ -143,6 +155,18 @@ func (srv *Server) connectionHandler(s ssh.Session) {
 	l.SetLevel(logrus.GetLevel())
 	logger := l.WithField("session.id", sessionID)
 
+	var record AuditRecord
+	if srv.Auditor != nil {
+		record = newAuditRecord(sessionID, s)
+		record.RunAs = srv.sessionUser(s)
+		defer func() {
+			record.End = time.Now()
+			if err := srv.Auditor.Log(record); err != nil {
+				logger.WithError(err).Warn("failed to write the audit log")
+			}
+		}()
+	}
+
 	defer func() {
 		s.Close()
 		logger.Info("session closed")
@@ -150,7 +174,13 @@ func (srv *Server) connectionHandler(s ssh.Session) {
 
 	logger.Infof("starting ssh session with command '%+v'", s.RawCommand())
 
-	cmd := srv.buildCmd(logger, s)
+	cmd, err := srv.buildCmd(logger, s)
+	if err != nil {
+		logger.WithError(err).Error("failed to build the command")
+		record.ExitStatus = 1
+		sendErrAndExit(logger, s, err)
+		return
+	}
 
 	if ssh.AgentRequested(s) {
 		logger.Info("agent requested")
@@ -168,7 +198,20 @@ func (srv *Server) connectionHandler(s ssh.Session) {
 	ptyReq, winCh, isPty := s.Pty()
 	if isPty {
 		logger.Infoln("handling PTY session")
-		if err := handlePTY(logger, cmd, s, ptyReq, winCh); err != nil {
+		record.PTY = true
+		var rec *asciicast.Recorder
+		if srv.Auditor != nil && srv.Auditor.RecordingDir != "" {
+			f, r, err := srv.Auditor.openRecording(sessionID, ptyReq, srv.Shell)
+			if err != nil {
+				logger.WithError(err).Warn("failed to record the session")
+			} else {
+				defer f.Close()
+				rec = r
+				record.Recording = f.Name()
+			}
+		}
+		if err := handlePTY(logger, cmd, s, ptyReq, winCh, rec); err != nil {
+			record.ExitStatus = getExitStatusFromError(err)
 			sendErrAndExit(logger, s, err)
 			return
 		}
@@ -182,17 +225,19 @@ func (srv *Server) connectionHandler(s ssh.Session) {
 
 	logger.Infoln("handling non PTY session")
 	if err := handleNoTTY(logger, cmd, s); err != nil {
+		record.ExitStatus = getExitStatusFromError(err)
 		sendErrAndExit(logger, s, err)
 		return
 	}
 
-	err := s.Exit(0)
+	err = s.Exit(0)
 	if err != nil {
 		logger.Warningln("exit session with error:", err)
 	}
 }
 
-func handlePTY(logger *logrus.Entry, cmd *exec.Cmd, s ssh.Session, ptyReq ssh.Pty, winCh <-chan ssh.Window) error {
+func handlePTY(logger *logrus.Entry, cmd *exec.Cmd, s ssh.Session, ptyReq ssh.Pty,
+	winCh <-chan ssh.Window, rec *asciicast.Recorder) error {
 	if len(ptyReq.Term) > 0 {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("TERM=%s", ptyReq.Term))
 	}
@@ -203,9 +248,17 @@ func handlePTY(logger *logrus.Entry, cmd *exec.Cmd, s ssh.Session, ptyReq ssh.Pt
 		return err
 	}
 
+	var stdout io.Writer = s
+	if rec != nil {
+		stdout = io.MultiWriter(s, &recordingWriter{logger: logger, rec: rec})
+	}
+
 	go func() {
 		for win := range winCh {
 			setWinsize(f, win.Width, win.Height)
+			if rec != nil {
+				_ = rec.Resize(win.Width, win.Height)
+			}
 		}
 	}()
 
@@ -219,7 +272,7 @@ func handlePTY(logger *logrus.Entry, cmd *exec.Cmd, s ssh.Session, ptyReq ssh.Pt
 	waitCh := make(chan struct{})
 	go func() {
 		defer close(waitCh)
-		_, err := io.Copy(s, f) // stdout
+		_, err := io.Copy(stdout, f) // stdout
 		if err != nil {
 			logger.WithError(err).Warningln("failed to copy stdin")
 		}
@@ -266,7 +319,7 @@ func getExitStatusFromError(err error) int {
 		return 0
 	}
 
-	var exitErr exec.ExitError
+	var exitErr *exec.ExitError
 	if ok := errors.As(err, &exitErr); !ok {
 		return 1
 	}
@@ -342,9 +395,19 @@ func handleNoTTY(logger *logrus.Entry, cmd *exec.Cmd, s ssh.Session) error {
 }
 
 func (srv *Server) authorize(ctx ssh.Context, key ssh.PublicKey) bool {
-	for _, k := range srv.AuthorizedKeys {
-		if ssh.KeysEqual(key, k) {
-			logrus.Debugf("authorized key: %s", k.Type())
+	keys := srv.AuthorizedKeys
+	if srv.keyring != nil {
+		keys = srv.keyring.get()
+	}
+	for _, k := range keys {
+		if ssh.KeysEqual(key, k.Key) {
+			if !srv.userAllowed(k.User) {
+				logrus.Warnf("access denied: the key %s is annotated with the user %s, which is not allowed",
+					gossh.FingerprintSHA256(key), k.User)
+				return false
+			}
+			logrus.Debugf("authorized key: %s, user: %s", k.Key.Type(), k.User)
+			ctx.SetValue(contextKeyUser, k.User)
 			return true
 		}
 	}
@@ -353,23 +416,78 @@ func (srv *Server) authorize(ctx ssh.Context, key ssh.PublicKey) bool {
 	return false
 }
 
-func sftpHandler(sess ssh.Session) {
+// userAllowed returns true if the sessions can run as the user annotated
+// in the authorized_keys file. The sessions never run as root, the keys
+// without the user option run as DefaultUser, or the current user.
+func (srv *Server) userAllowed(user string) bool {
+	if user == "" {
+		user = srv.DefaultUser
+	}
+	switch user {
+	case "root":
+		return false
+	case "", srv.DefaultUser:
+		return true
+	}
+	for _, u := range srv.AllowedUsers {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// sftpHandler serves the sftp subsystem in the process, or in a child
+// process running as the user of the session if envd-ssh is root.
+func (srv *Server) sftpHandler(sess ssh.Session) {
+	user := srv.sessionUser(sess)
+	if user == "" || os.Geteuid() != 0 {
+		if err := ServeSFTP(sess); err != nil {
+			logrus.Infoln("sftp server completed with error:", err)
+		}
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		logrus.WithError(err).Error("failed to get the executable of envd-ssh")
+		return
+	}
+	cmd := exec.Command(executable, SFTPServerCommand)
+	cmd.Stdin = sess
+	cmd.Stdout = sess
+	cmd.Stderr = sess.Stderr()
+	if err := osutil.RunAs(cmd, user); err != nil {
+		logrus.WithError(err).Errorf("failed to serve sftp as the user %s", user)
+		return
+	}
+	if err := cmd.Run(); err != nil {
+		logrus.Infoln("sftp server completed with error:", err)
+	}
+}
+
+// SFTPServerCommand is the subcommand of envd-ssh to serve sftp on the
+// standard input and output.
+const SFTPServerCommand = "sftp-server"
+
+// ServeSFTP serves sftp on the stream until the client exits.
+func ServeSFTP(rwc io.ReadWriteCloser) error {
 	debugStream := ioutil.Discard
 	serverOptions := []sftp.ServerOption{
 		sftp.WithDebug(debugStream),
 	}
 	server, err := sftp.NewServer(
-		sess,
+		rwc,
 		serverOptions...,
 	)
 	if err != nil {
-		logrus.Infof("sftp server init error: %s\n", err)
-		return
+		return errors.Wrap(err, "failed to init the sftp server")
 	}
 	if err := server.Serve(); errors.Is(err, io.EOF) {
 		server.Close()
 		logrus.Infoln("sftp client exited session.")
 	} else if err != nil {
-		logrus.Infoln("sftp server completed with error:", err)
+		return err
 	}
+	return nil
 }
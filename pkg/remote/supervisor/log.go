@@ -0,0 +1,90 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogPath returns the log file of the process.
+func LogPath(dir, name string) string {
+	return filepath.Join(dir, name+".log")
+}
+
+// ParseLogLine splits the line in the log file into the timestamp and the
+// output of the process.
+func ParseLogLine(line string) (time.Time, string, bool) {
+	ts, msg, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, line, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return t, msg, true
+}
+
+// logWriter prefixes every line of the output with the timestamp.
+type logWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+	now func() time.Time
+}
+
+func newLogWriter(w io.Writer) *logWriter {
+	return &logWriter{w: w, now: time.Now}
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := l.writeLine(l.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line which does not end with a newline.
+func (l *logWriter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) == 0 {
+		return nil
+	}
+	err := l.writeLine(append(l.buf, '\n'))
+	l.buf = nil
+	return err
+}
+
+func (l *logWriter) writeLine(line []byte) error {
+	ts := l.now().UTC().Format(time.RFC3339Nano)
+	_, err := l.w.Write(append([]byte(ts+" "), line...))
+	return err
+}
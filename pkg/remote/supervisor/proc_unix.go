@@ -0,0 +1,55 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/tensorchord/envd/pkg/util/osutil"
+)
+
+// setProcessGroup runs the process in a new process group, thus the
+// children started by the shell are stopped together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// setUser runs the command as the user if the supervisor is root.
+func setUser(cmd *exec.Cmd, username string) error {
+	if username == "" || os.Geteuid() != 0 {
+		return nil
+	}
+	return osutil.RunAs(cmd, username)
+}
+
+// stopProcess sends SIGTERM to the process group, then SIGKILL if it does
+// not exit in time.
+func stopProcess(cmd *exec.Cmd, exited <-chan error, timeout time.Duration) error {
+	pgid := -cmd.Process.Pid
+	_ = syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(timeout):
+	}
+	_ = syscall.Kill(pgid, syscall.SIGKILL)
+	return <-exited
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/cockroachdb/errors"
+)
+
+const statusURL = "http://supervisor/status"
+
+// Serve serves the status of the processes at /status over the unix socket
+// until the context is canceled.
+func (s *Supervisor) Serve(ctx context.Context, socketPath string) error {
+	// Remove the stale socket of the last run.
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove the socket %s", socketPath)
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", socketPath)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+			s.logger.WithError(err).Debug("failed to write the status")
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "failed to serve the status")
+	}
+	return nil
+}
+
+// GetStatus gets the status of the processes from the supervisor listening
+// on the unix socket.
+func GetStatus(ctx context.Context, socketPath string) ([]Status, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to the supervisor at %s", socketPath)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the status")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("failed to get the status: %s", resp.Status)
+	}
+	return ParseStatus(data)
+}
@@ -0,0 +1,360 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package supervisor runs and restarts the processes in the container.
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// minBackoff and maxBackoff bound the delay before restarting a process.
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+	// stableDuration resets the backoff if the process runs longer than it.
+	stableDuration = 10 * time.Second
+	// stopTimeout is the time to wait after SIGTERM before SIGKILL.
+	stopTimeout = 10 * time.Second
+
+	defaultHealthcheckInterval = 10 * time.Second
+)
+
+// Supervisor starts the processes in the spec, and restarts them according
+// to their restart policies and healthchecks.
+type Supervisor struct {
+	spec       Spec
+	statusPath string
+	logger     *logrus.Entry
+
+	mu       sync.Mutex
+	statuses map[string]*Status
+	// writeMu keeps the status file in the order of the updates.
+	writeMu sync.Mutex
+}
+
+// Load reads the spec from the file.
+func Load(path string) (Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, errors.Wrapf(err, "failed to read the supervisor spec %s", path)
+	}
+	return ParseSpec(data)
+}
+
+// ParseSpec parses the spec in JSON.
+func ParseSpec(data []byte) (Spec, error) {
+	spec := Spec{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, errors.Wrap(err, "failed to parse the supervisor spec")
+	}
+	return spec, nil
+}
+
+// New creates the supervisor. The status of the processes is written to
+// statusPath if it is not empty.
+func New(spec Spec, statusPath string) *Supervisor {
+	s := &Supervisor{
+		spec:       spec,
+		statusPath: statusPath,
+		logger:     logrus.WithField("component", "supervisor"),
+		statuses:   make(map[string]*Status),
+	}
+	for _, p := range spec.Processes {
+		s.statuses[p.Name] = &Status{Name: p.Name, State: StateStarting, Since: time.Now()}
+	}
+	return s
+}
+
+// Run starts the processes and blocks until the context is canceled and
+// all the processes are stopped.
+func (s *Supervisor) Run(ctx context.Context) error {
+	for _, p := range s.spec.Processes {
+		if len(p.Command) == 0 {
+			return errors.Newf("the command of process %s is empty", p.Name)
+		}
+	}
+	if s.spec.LogDir != "" {
+		if err := os.MkdirAll(s.spec.LogDir, 0755); err != nil {
+			return errors.Wrapf(err, "failed to create the log directory %s", s.spec.LogDir)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, p := range s.spec.Processes {
+		wg.Add(1)
+		go func(p Process) {
+			defer wg.Done()
+			s.supervise(ctx, p)
+		}(p)
+	}
+	wg.Wait()
+	return nil
+}
+
+// Status returns the status of the processes in the order of the spec.
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []Status{}
+	for _, p := range s.spec.Processes {
+		res = append(res, *s.statuses[p.Name])
+	}
+	return res
+}
+
+func (s *Supervisor) supervise(ctx context.Context, p Process) {
+	logger := s.logger.WithField("process", p.Name)
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		exitCode, unhealthy, err := s.runOnce(ctx, p, logger)
+		if ctx.Err() != nil {
+			logger.WithField("exit-code", exitCode).Info("process stopped")
+			s.update(p.Name, func(st *Status) {
+				st.State = StateStopped
+				st.PID = 0
+				st.ExitCode = exitCode
+			})
+			return
+		}
+
+		failed := err != nil || exitCode != 0 || unhealthy
+		state := StateExited
+		if failed {
+			state = StateFailed
+		}
+		exitLogger := logger.WithFields(logrus.Fields{
+			"exit-code": exitCode,
+			"unhealthy": unhealthy,
+		})
+		if err != nil {
+			exitLogger = exitLogger.WithError(err)
+		}
+		exitLogger.Info("process exited")
+
+		restart := p.Restart == RestartAlways || (p.Restart == RestartOnFailure && failed)
+		if !restart {
+			s.update(p.Name, func(st *Status) {
+				st.State = state
+				st.PID = 0
+				st.ExitCode = exitCode
+			})
+			return
+		}
+
+		if time.Since(started) > stableDuration {
+			backoff = minBackoff
+		}
+		s.update(p.Name, func(st *Status) {
+			st.State = StateBackoff
+			st.PID = 0
+			st.ExitCode = exitCode
+			st.Restarts++
+		})
+		logger.Debugf("restarting the process in %s", backoff)
+		select {
+		case <-ctx.Done():
+			s.update(p.Name, func(st *Status) { st.State = StateStopped })
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// runOnce runs the process until it exits, the context is canceled or it
+// is killed for being unhealthy.
+func (s *Supervisor) runOnce(ctx context.Context, p Process, logger *logrus.Entry) (int, bool, error) {
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if s.spec.LogDir != "" {
+		f, err := os.OpenFile(LogPath(s.spec.LogDir, p.Name),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return -1, false, errors.Wrapf(err, "failed to open the log file of %s", p.Name)
+		}
+		defer f.Close()
+		w := newLogWriter(f)
+		defer w.Flush()
+		cmd.Stdout = w
+		cmd.Stderr = w
+	}
+	setProcessGroup(cmd)
+	if err := setUser(cmd, p.User); err != nil {
+		return -1, false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return -1, false, errors.Wrapf(err, "failed to start %s", p.Name)
+	}
+	s.update(p.Name, func(st *Status) {
+		st.State = StateRunning
+		st.PID = cmd.Process.Pid
+		st.Healthy = nil
+	})
+	logger.WithField("pid", cmd.Process.Pid).Info("process started")
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	unhealthyC := make(chan struct{})
+	hcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if p.Healthcheck != nil {
+		go s.healthcheck(hcCtx, p, logger, unhealthyC)
+	}
+
+	unhealthy := false
+	select {
+	case err := <-exited:
+		return exitCode(cmd, err), false, nil
+	case <-ctx.Done():
+	case <-unhealthyC:
+		unhealthy = true
+		logger.Warn("process is unhealthy, stopping it")
+	}
+	err := stopProcess(cmd, exited, stopTimeout)
+	return exitCode(cmd, err), unhealthy, nil
+}
+
+func (s *Supervisor) healthcheck(ctx context.Context, p Process,
+	logger *logrus.Entry, unhealthy chan<- struct{}) {
+	hc := p.Healthcheck
+	retries := hc.Retries
+	if retries <= 0 {
+		retries = 1
+	}
+	interval, timeout := time.Duration(hc.Interval), time.Duration(hc.Timeout)
+	if interval <= 0 {
+		interval = defaultHealthcheckInterval
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	failures := 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		cmd := exec.CommandContext(checkCtx, hc.Command[0], hc.Command[1:]...)
+		err := setUser(cmd, p.User)
+		if err == nil {
+			err = cmd.Run()
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := err == nil
+		if healthy {
+			failures = 0
+		} else {
+			failures++
+			logger.WithError(err).WithField("failures", failures).Debug("healthcheck failed")
+		}
+		s.update(p.Name, func(st *Status) {
+			// Keep the status healthy until the retries are exhausted.
+			v := healthy || failures < retries
+			st.Healthy = &v
+		})
+		if failures >= retries {
+			close(unhealthy)
+			return
+		}
+	}
+}
+
+func (s *Supervisor) update(name string, f func(st *Status)) {
+	s.mu.Lock()
+	st := s.statuses[name]
+	prev := st.State
+	f(st)
+	if st.State != prev {
+		st.Since = time.Now()
+	}
+	s.mu.Unlock()
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.writeStatus(); err != nil {
+		s.logger.WithError(err).Warn("failed to write the status")
+	}
+}
+
+// writeStatus writes the status file atomically, thus the readers never see
+// a partial file.
+func (s *Supervisor) writeStatus() error {
+	if s.statusPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.Status())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.statusPath), ".status-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.statusPath)
+}
+
+// ParseStatus parses the content of the status file.
+func ParseStatus(data []byte) ([]Status, error) {
+	statuses := []Status{}
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the supervisor status")
+	}
+	return statuses, nil
+}
+
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Supervisor", func() {
+	var statusPath string
+
+	BeforeEach(func() {
+		minBackoff, maxBackoff = time.Millisecond, 10*time.Millisecond
+		statusPath = filepath.Join(GinkgoT().TempDir(), "status.json")
+	})
+
+	run := func(spec Spec, timeout time.Duration) *Supervisor {
+		s := New(spec, statusPath)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		Expect(s.Run(ctx)).To(Succeed())
+		return s
+	}
+
+	It("should not restart the process by default", func() {
+		s := run(Spec{Processes: []Process{
+			{Name: "fail", Command: []string{"sh", "-c", "exit 3"}},
+		}}, time.Second)
+		status := s.Status()
+		Expect(status).To(HaveLen(1))
+		Expect(status[0].State).To(Equal(StateFailed))
+		Expect(status[0].ExitCode).To(Equal(3))
+		Expect(status[0].Restarts).To(Equal(0))
+	})
+
+	It("should restart the failed process", func() {
+		s := run(Spec{Processes: []Process{
+			{Name: "fail", Command: []string{"sh", "-c", "exit 1"}, Restart: RestartOnFailure},
+			{Name: "ok", Command: []string{"true"}, Restart: RestartOnFailure},
+		}}, 500*time.Millisecond)
+		status := s.Status()
+		Expect(status[0].Restarts).To(BeNumerically(">", 1))
+		Expect(status[0].State).To(Equal(StateStopped))
+		Expect(status[1].Restarts).To(Equal(0))
+		Expect(status[1].State).To(Equal(StateExited))
+	})
+
+	It("should stop the process when the context is canceled", func() {
+		start := time.Now()
+		s := run(Spec{Processes: []Process{
+			{Name: "sleep", Command: []string{"sleep", "60"}, Restart: RestartAlways},
+		}}, 200*time.Millisecond)
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(s.Status()[0].State).To(Equal(StateStopped))
+	})
+
+	It("should restart the unhealthy process", func() {
+		s := run(Spec{Processes: []Process{{
+			Name:    "sleep",
+			Command: []string{"sleep", "60"},
+			Restart: RestartOnFailure,
+			Healthcheck: &Healthcheck{
+				Command:  []string{"false"},
+				Interval: Duration(20 * time.Millisecond),
+				Timeout:  Duration(time.Second),
+				Retries:  2,
+			},
+		}}}, time.Second)
+		status := s.Status()
+		Expect(status[0].Restarts).To(BeNumerically(">=", 1))
+	})
+
+	It("should write the status file", func() {
+		run(Spec{Processes: []Process{
+			{Name: "ok", Command: []string{"true"}},
+		}}, time.Second)
+		data, err := os.ReadFile(statusPath)
+		Expect(err).NotTo(HaveOccurred())
+		status, err := ParseStatus(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(HaveLen(1))
+		Expect(status[0].Name).To(Equal("ok"))
+		Expect(status[0].State).To(Equal(StateExited))
+	})
+
+	It("should write the output to the log files with timestamps", func() {
+		dir := GinkgoT().TempDir()
+		run(Spec{LogDir: dir, Processes: []Process{
+			{Name: "echo", Command: []string{"sh", "-c", "echo hello; printf world >&2"}},
+		}}, time.Second)
+		data, err := os.ReadFile(LogPath(dir, "echo"))
+		Expect(err).NotTo(HaveOccurred())
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		Expect(lines).To(HaveLen(2))
+		msgs := []string{}
+		for _, line := range lines {
+			ts, msg, ok := ParseLogLine(line)
+			Expect(ok).To(BeTrue())
+			Expect(time.Since(ts)).To(BeNumerically("<", time.Minute))
+			msgs = append(msgs, msg)
+		}
+		Expect(msgs).To(ConsistOf("hello", "world"))
+	})
+
+	It("should serve the status over the unix socket", func() {
+		// The path of the unix socket is limited to about 100 bytes.
+		dir, err := os.MkdirTemp("", "supervisor")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		socket := filepath.Join(dir, "supervisor.sock")
+
+		s := New(Spec{Processes: []Process{
+			{Name: "sleep", Command: []string{"sleep", "60"}},
+		}}, "")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Run(ctx)
+		go s.Serve(ctx, socket)
+
+		Eventually(func() (State, error) {
+			status, err := GetStatus(ctx, socket)
+			if err != nil || len(status) != 1 {
+				return "", err
+			}
+			return status[0].State, nil
+		}, 5*time.Second, 50*time.Millisecond).Should(Equal(StateRunning))
+	})
+
+	It("should encode the durations as strings", func() {
+		data, err := json.Marshal(Healthcheck{Interval: Duration(10 * time.Second)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"interval":"10s"`))
+		hc := Healthcheck{}
+		Expect(json.Unmarshal(data, &hc)).To(Succeed())
+		Expect(time.Duration(hc.Interval)).To(Equal(10 * time.Second))
+	})
+})
@@ -0,0 +1,115 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// RestartPolicy decides whether the process is restarted after it exits.
+type RestartPolicy string
+
+const (
+	RestartNo        RestartPolicy = "no"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// ParseRestartPolicy parses the policy, the empty string means no restart.
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch RestartPolicy(s) {
+	case "", RestartNo:
+		return RestartNo, nil
+	case RestartOnFailure, RestartAlways:
+		return RestartPolicy(s), nil
+	default:
+		return "", errors.Newf("invalid restart policy %s, expected no, on-failure or always", s)
+	}
+}
+
+// State is the state of a supervised process.
+type State string
+
+const (
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateBackoff  State = "backoff"
+	StateExited   State = "exited"
+	StateFailed   State = "failed"
+	StateStopped  State = "stopped"
+)
+
+// Duration is a time.Duration written as a string, e.g. "10s", in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Spec is the processes supervised in the container.
+type Spec struct {
+	// LogDir is the directory of the log files of the processes, the output
+	// is written to stdout and stderr if it is empty.
+	LogDir    string    `json:"log_dir,omitempty"`
+	Processes []Process `json:"processes"`
+}
+
+// Process is a supervised process.
+type Process struct {
+	Name        string        `json:"name"`
+	Command     []string      `json:"command"`
+	Restart     RestartPolicy `json:"restart,omitempty"`
+	Healthcheck *Healthcheck  `json:"healthcheck,omitempty"`
+	// User runs the process and the healthcheck as the user if the
+	// supervisor is root, otherwise as the current user.
+	User string `json:"user,omitempty"`
+}
+
+// Healthcheck checks the process periodically. The process is killed after
+// the command fails for Retries times in a row, then the restart policy
+// decides whether to start it again.
+type Healthcheck struct {
+	Command  []string `json:"command"`
+	Interval Duration `json:"interval"`
+	Timeout  Duration `json:"timeout"`
+	Retries  int      `json:"retries"`
+}
+
+// Status is the status of a supervised process.
+type Status struct {
+	Name     string    `json:"name"`
+	State    State     `json:"state"`
+	PID      int       `json:"pid,omitempty"`
+	Restarts int       `json:"restarts"`
+	ExitCode int       `json:"exit_code"`
+	Healthy  *bool     `json:"healthy,omitempty"`
+	Since    time.Time `json:"since"`
+}
@@ -15,9 +15,11 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"os"
 
@@ -33,6 +35,24 @@ const (
 	bitSize = 4096
 )
 
+// KeyType is the type of the SSH key pair generated by envd.
+type KeyType string
+
+const (
+	KeyTypeRSA     KeyType = "rsa"
+	KeyTypeED25519 KeyType = "ed25519"
+)
+
+// ParseKeyType parses the key type, e.g. rsa, ed25519.
+func ParseKeyType(s string) (KeyType, error) {
+	switch t := KeyType(s); t {
+	case KeyTypeRSA, KeyTypeED25519:
+		return t, nil
+	default:
+		return "", errors.Newf("unsupported ssh key type %s, expected rsa or ed25519", s)
+	}
+}
+
 // KeyExists returns true if the okteto key pair exists
 func KeyExists(public, private string) bool {
 	// public, private := getKeyPaths()
@@ -56,30 +76,37 @@ func KeyExists(public, private string) bool {
 
 // GenerateKeys generates a SSH key pair on path
 func GenerateKeys() error {
+	return GenerateKeysWithType(KeyTypeRSA)
+}
+
+// GenerateKeysWithType generates a SSH key pair of the type on path.
+func GenerateKeysWithType(keyType KeyType) error {
 	publicKeyPath, privateKeyPath, err := getDefaultKeyPaths()
 	if err != nil {
 		return err
 	}
-	return generateKeys(publicKeyPath, privateKeyPath, bitSize)
+	return generateKeys(publicKeyPath, privateKeyPath, keyType)
 }
 
-func generateKeys(public, private string, bitSize int) error {
+func generateKeys(public, private string, keyType KeyType) error {
 	if KeyExists(public, private) {
 		return nil
 	}
 
-	privateKey, err := generatePrivateKey(bitSize)
+	publicKeyBytes, privateKeyBytes, err := GenerateKeyPair(keyType)
 	if err != nil {
-		return errors.Wrap(err, "failed to generate private SSH key")
+		return err
 	}
-
-	publicKeyBytes, err := generatePublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return errors.Wrap(err, "failed to generate public SSH key")
+	if err := WriteKeys(public, private, publicKeyBytes, privateKeyBytes); err != nil {
+		return err
 	}
 
-	privateKeyBytes := encodePrivateKeyToPEM(privateKey)
+	logrus.Debugf("created ssh keypair at  %s and %s", public, private)
+	return nil
+}
 
+// WriteKeys writes the key pair to the paths.
+func WriteKeys(public, private string, publicKeyBytes, privateKeyBytes []byte) error {
 	if err := os.WriteFile(public, publicKeyBytes, 0600); err != nil {
 		return errors.Wrap(err, "failed to write public SSH key")
 	}
@@ -87,11 +114,55 @@ func generateKeys(public, private string, bitSize int) error {
 	if err := os.WriteFile(private, privateKeyBytes, 0600); err != nil {
 		return errors.Wrap(err, "failed to write private SSH key")
 	}
-
-	logrus.Debugf("created ssh keypair at  %s and %s", public, private)
 	return nil
 }
 
+// GenerateKeyPair returns the public key in the authorized_keys format, and
+// the private key in PEM, which is in the OpenSSH format for ed25519.
+func GenerateKeyPair(keyType KeyType) ([]byte, []byte, error) {
+	switch keyType {
+	case KeyTypeED25519:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate private SSH key")
+		}
+		publicKeyBytes, err := generatePublicKey(publicKey)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate public SSH key")
+		}
+		return publicKeyBytes, encodeED25519PrivateKeyToPEM(privateKey), nil
+	case KeyTypeRSA:
+		privateKey, err := generatePrivateKey(bitSize)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate private SSH key")
+		}
+
+		publicKeyBytes, err := generatePublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate public SSH key")
+		}
+		return publicKeyBytes, encodePrivateKeyToPEM(privateKey), nil
+	default:
+		return nil, nil, errors.Newf("unsupported ssh key type %s", keyType)
+	}
+}
+
+// GetKeyType returns the type of the public key.
+func GetKeyType(public string) (KeyType, error) {
+	data, err := os.ReadFile(public)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the public key")
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse the public key")
+	}
+	if key.Type() == ssh.KeyAlgoED25519 {
+		return KeyTypeED25519, nil
+	}
+	return KeyTypeRSA, nil
+}
+
 func generatePrivateKey(bitSize int) (*rsa.PrivateKey, error) {
 	// Private Key generation
 	privateKey, err := rsa.GenerateKey(rand.Reader, bitSize)
@@ -122,7 +193,59 @@ func encodePrivateKeyToPEM(privateKey *rsa.PrivateKey) []byte {
 	return privatePEM
 }
 
-func generatePublicKey(privatekey *rsa.PublicKey) ([]byte, error) {
+// encodeED25519PrivateKeyToPEM encodes the key in the openssh-key-v1
+// format without encryption, which is the only format of ed25519 keys
+// accepted by OpenSSH.
+func encodeED25519PrivateKeyToPEM(privateKey ed25519.PrivateKey) []byte {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	sshPublicKey, _ := ssh.NewPublicKey(publicKey)
+
+	var check [4]byte
+	_, _ = rand.Read(check[:])
+	checkInt := binary.BigEndian.Uint32(check[:])
+	private := struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{
+		Check1:  checkInt,
+		Check2:  checkInt,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     publicKey,
+		Priv:    privateKey,
+	}
+	// The private section is padded to the block size 8 with 1, 2, 3...
+	n := len(ssh.Marshal(private))
+	for i := 0; (n+i)%8 != 0; i++ {
+		private.Pad = append(private.Pad, byte(i+1))
+	}
+
+	key := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       sshPublicKey.Marshal(),
+		PrivKeyBlock: ssh.Marshal(private),
+	}
+	block := pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), ssh.Marshal(key)...),
+	}
+	return pem.EncodeToMemory(&block)
+}
+
+func generatePublicKey(privatekey interface{}) ([]byte, error) {
 	publicRsaKey, err := ssh.NewPublicKey(privatekey)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,58 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ = Describe("ssh key", func() {
+	DescribeTable("should generate the key pair",
+		func(keyType KeyType, algo string) {
+			pub, priv, err := GenerateKeyPair(keyType)
+			Expect(err).NotTo(HaveOccurred())
+			publicKey, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(publicKey.Type()).To(Equal(algo))
+
+			signer, err := ssh.ParsePrivateKey(priv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signer.PublicKey().Marshal()).To(Equal(publicKey.Marshal()))
+
+			path := filepath.Join(GinkgoT().TempDir(), "key.pub")
+			Expect(os.WriteFile(path, pub, 0600)).To(Succeed())
+			Expect(GetKeyType(path)).To(Equal(keyType))
+		},
+		Entry("ed25519", KeyTypeED25519, ssh.KeyAlgoED25519),
+		Entry("rsa", KeyTypeRSA, ssh.KeyAlgoRSA),
+	)
+
+	It("should set the identity file of the entry", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config")
+		Expect(add(path, buildHostname("mnist"), "localhost", 2222, "/old/key")).To(Succeed())
+		Expect(setIdentityFile(path, buildHostname("mnist"), "/new/key")).To(Succeed())
+		Expect(setIdentityFile(path, buildHostname("missing"), "/new/key")).NotTo(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`IdentityFile "/new/key"`))
+		Expect(string(data)).NotTo(ContainSubstring("/old/key"))
+	})
+})
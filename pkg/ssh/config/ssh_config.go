@@ -29,6 +29,7 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/tensorchord/envd/pkg/util/fileutil"
 	"github.com/tensorchord/envd/pkg/util/osutil"
 )
 
@@ -330,36 +331,19 @@ func AddEntry(name, iface string, port int, privateKeyPath string) error {
 }
 
 func ReplaceKeyManagedByEnvd(oldKey string, newKey string) error {
-	cfg, err := getConfig(getSSHConfigPath())
-	if err != nil {
-		return err
-	}
 	logrus.Infof("Rewrite ssh keys old: %s, new: %s", oldKey, newKey)
-	for ih, h := range cfg.hosts {
-		for _, hn := range h.hostnames {
-			logrus.Info(h.hostnames)
-			if strings.HasSuffix(hn, ".envd") {
-				for ip, p := range h.params {
-					if p.keyword == identityFile && strings.Trim(p.args[0], "\"") == oldKey {
-						logrus.Debug("Change key")
-						cfg.hosts[ih].params[ip].args[0] = newKey
-					}
-				}
-			}
-		}
-	}
-
-	path, err := GetPrivateKey()
-	if err != nil {
-		return err
-	}
-
-	err = os.Rename(path, newKey)
-	if err != nil {
-		return err
-	}
+	err := update(getSSHConfigPath(), func(cfg *sshConfig) (bool, error) {
+		replaceKey(cfg, oldKey, newKey)
 
-	err = save(cfg, getSSHConfigPath())
+		path, err := GetPrivateKey()
+		if err != nil {
+			return false, err
+		}
+		if err := os.Rename(path, newKey); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
 	if err != nil {
 		return err
 	}
@@ -369,10 +353,6 @@ func ReplaceKeyManagedByEnvd(oldKey string, newKey string) error {
 		if err != nil {
 			return err
 		}
-		cfg, err := getConfig(winSshConfig)
-		if err != nil {
-			return err
-		}
 		winNewKey, err := osutil.CopyToWinEnvdHome(newKey, 0600)
 		if err != nil {
 			return err
@@ -382,33 +362,76 @@ func ReplaceKeyManagedByEnvd(oldKey string, newKey string) error {
 			return err
 		}
 		logrus.Infof("Rewrite WSL ssh keys old: %s, new: %s", winOldKey, winNewKey)
-		for ih, h := range cfg.hosts {
-			for _, hn := range h.hostnames {
-				logrus.Info(h.hostnames)
-				if strings.HasSuffix(hn, ".envd") {
-					for ip, p := range h.params {
-						if p.keyword == identityFile && strings.Trim(p.args[0], "\"") == winOldKey {
-							logrus.Debug("Change key")
-							cfg.hosts[ih].params[ip].args[0] = winNewKey
-						}
+		err = update(winSshConfig, func(cfg *sshConfig) (bool, error) {
+			replaceKey(cfg, winOldKey, winNewKey)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceKey(cfg *sshConfig, oldKey, newKey string) {
+	for ih, h := range cfg.hosts {
+		for _, hn := range h.hostnames {
+			logrus.Debug(h.hostnames)
+			if strings.HasSuffix(hn, ".envd") {
+				for ip, p := range h.params {
+					if p.keyword == identityFile && strings.Trim(p.args[0], "\"") == oldKey {
+						logrus.Debug("Change key")
+						cfg.hosts[ih].params[ip].args[0] = newKey
 					}
 				}
 			}
 		}
-		err = save(cfg, winSshConfig)
+	}
+}
+
+// SetIdentityFile sets the private key of the entry in the user's sshconfig.
+func SetIdentityFile(name, privateKeyPath string) error {
+	if err := setIdentityFile(getSSHConfigPath(), buildHostname(name), privateKeyPath); err != nil {
+		return err
+	}
+	if osutil.IsWsl() {
+		winSshConfig, err := osutil.GetWslHostSshConfig()
 		if err != nil {
 			return err
 		}
+		winKeyPath, err := osutil.CopyToWinEnvdHome(privateKeyPath, 0600)
+		if err != nil {
+			return err
+		}
+		return setIdentityFile(winSshConfig, buildHostname(name), winKeyPath)
 	}
 	return nil
 }
 
+func setIdentityFile(path, name, privateKeyPath string) error {
+	return update(path, func(cfg *sshConfig) (bool, error) {
+		i, found := findHost(cfg, name)
+		if !found {
+			return false, errors.Newf("entry %s not found in %s", name, path)
+		}
+		if p := cfg.hosts[i].getParam(identityFile); p != nil {
+			p.args = []string{"\"" + privateKeyPath + "\""}
+		} else {
+			cfg.hosts[i].params = append(cfg.hosts[i].params,
+				newParam(identityFile, []string{"\"" + privateKeyPath + "\""}, nil))
+		}
+		return true, nil
+	})
+}
+
 func add(path, name, iface string, port int, privateKeyPath string) error {
-	cfg, err := getConfig(path)
-	if err != nil {
-		return err
-	}
+	return update(path, func(cfg *sshConfig) (bool, error) {
+		addHost(cfg, name, iface, port, privateKeyPath)
+		return true, nil
+	})
+}
 
+func addHost(cfg *sshConfig, name, iface string, port int, privateKeyPath string) {
 	_ = removeHost(cfg, name)
 
 	// TODO: Use private key to authenticate ssh
@@ -427,7 +450,6 @@ func add(path, name, iface string, port int, privateKeyPath string) error {
 	}
 
 	cfg.hosts = append(cfg.hosts, host)
-	return save(cfg, path)
 }
 
 // RemoveEntry removes the entry to the user's sshconfig if found
@@ -477,16 +499,9 @@ func GetPort(name string) (int, error) {
 }
 
 func remove(path, name string) error {
-	cfg, err := getConfig(path)
-	if err != nil {
-		return err
-	}
-
-	if removeHost(cfg, name) {
-		return save(cfg, path)
-	}
-
-	return nil
+	return update(path, func(cfg *sshConfig) (bool, error) {
+		return removeHost(cfg, name), nil
+	})
 }
 
 func removeHost(cfg *sshConfig, name string) bool {
@@ -537,6 +552,33 @@ func getConfig(path string) (*sshConfig, error) {
 	return cfg, nil
 }
 
+// update reloads the config at path and applies fn to it while holding the
+// advisory lock of the file, so that concurrent envd processes do not
+// overwrite each other's entries. The config is saved only if fn reports
+// a change.
+func update(path string, fn func(cfg *sshConfig) (bool, error)) error {
+	sshDir := filepath.Dir(path)
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create SSH directory %s", sshDir)
+	}
+	// The lock is kept in the envd config dir instead of the ssh dir.
+	lock, err := fileutil.ConfigFile("ssh_config.lock")
+	if err != nil {
+		return errors.Wrap(err, "failed to get the ssh config lock file")
+	}
+	return fileutil.WithLockFile(lock, func() error {
+		cfg, err := getConfig(path)
+		if err != nil {
+			return err
+		}
+		changed, err := fn(cfg)
+		if err != nil || !changed {
+			return err
+		}
+		return save(cfg, path)
+	})
+}
+
 func save(cfg *sshConfig, path string) error {
 	if err := cfg.writeToFilepath(path); err != nil {
 		return errors.Newf("fail to update SSH config file %s: %w", path, err)
@@ -15,8 +15,14 @@
 package config
 
 import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/tensorchord/envd/pkg/util/fileutil"
 )
 
 var _ = Describe("ssh config", func() {
@@ -37,4 +43,47 @@ var _ = Describe("ssh config", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
+	When("adding entries concurrently", func() {
+		It("Should keep all the entries", func() {
+			configDir := fileutil.DefaultConfigDir
+			fileutil.DefaultConfigDir = GinkgoT().TempDir()
+			defer func() { fileutil.DefaultConfigDir = configDir }()
+			path := filepath.Join(GinkgoT().TempDir(), ".ssh", "config")
+			n := 32
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					errs <- add(path, buildHostname(fmt.Sprintf("env-%d", i)), "localhost", 2222+i, "key")
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			cfg, err := getConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.hosts).To(HaveLen(n))
+			for i := 0; i < n; i++ {
+				_, found := findHost(cfg, buildHostname(fmt.Sprintf("env-%d", i)))
+				Expect(found).To(BeTrue())
+			}
+
+			for i := 0; i < n; i += 2 {
+				Expect(remove(path, buildHostname(fmt.Sprintf("env-%d", i)))).To(Succeed())
+			}
+			cfg, err = getConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.hosts).To(HaveLen(n / 2))
+
+			// The lock is not left in the ssh dir.
+			Expect(path + ".lock").NotTo(BeAnExistingFile())
+			Expect(filepath.Join(fileutil.DefaultConfigDir, "ssh_config.lock")).To(BeAnExistingFile())
+		})
+	})
 })
@@ -0,0 +1,288 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// minReconnectBackoff and maxReconnectBackoff bound the delay before
+	// reconnecting to the server.
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+	// keepAliveInterval is the interval to check the connection.
+	keepAliveInterval = 15 * time.Second
+	// keepAliveTimeout is the time to wait for the reply of the keepalive.
+	// The reply never comes if the connection is half-open, e.g. after the
+	// laptop sleeps or the NAT drops the mapping.
+	keepAliveTimeout = 10 * time.Second
+)
+
+// Forward is a port forwarded over the SSH connection.
+type Forward struct {
+	// Reverse forwards the connections to RemoteAddr in the environment to
+	// LocalAddr on the host, otherwise from LocalAddr to RemoteAddr.
+	Reverse    bool
+	LocalAddr  string
+	RemoteAddr string
+}
+
+func (f Forward) String() string {
+	if f.Reverse {
+		return f.RemoteAddr + " (remote) -> " + f.LocalAddr
+	}
+	return f.LocalAddr + " -> " + f.RemoteAddr + " (remote)"
+}
+
+// ParseForward parses the forward in the `[LOCAL_PORT:]REMOTE_PORT` format,
+// or `[REMOTE_PORT:]LOCAL_PORT` if it is reverse. The local port listens on
+// the address, and the remote port listens on localhost in the environment.
+func ParseForward(s, address string, reverse bool) (Forward, error) {
+	ports := strings.Split(s, ":")
+	if len(ports) > 2 {
+		return Forward{}, errors.Newf("invalid port forward %s", s)
+	}
+	for _, p := range ports {
+		if n, err := strconv.Atoi(p); err != nil || n < 0 || n > 65535 {
+			return Forward{}, errors.Newf("invalid port %s in the port forward %s", p, s)
+		}
+	}
+	first, second := ports[0], ports[len(ports)-1]
+	f := Forward{Reverse: reverse}
+	if reverse {
+		f.RemoteAddr = net.JoinHostPort("localhost", first)
+		f.LocalAddr = net.JoinHostPort(address, second)
+	} else {
+		f.LocalAddr = net.JoinHostPort(address, first)
+		f.RemoteAddr = net.JoinHostPort("localhost", second)
+	}
+	return f, nil
+}
+
+// PortForwarder forwards the ports over the SSH connection, and reconnects
+// when the connection drops. The local ports keep listening during the
+// reconnection.
+type PortForwarder struct {
+	opt      Options
+	forwards []Forward
+	logger   *logrus.Entry
+
+	keepAliveInterval time.Duration
+	keepAliveTimeout  time.Duration
+
+	mu  sync.Mutex
+	cli *ssh.Client
+}
+
+func NewPortForwarder(opt Options, forwards []Forward) *PortForwarder {
+	return &PortForwarder{
+		opt:               opt,
+		forwards:          forwards,
+		keepAliveInterval: keepAliveInterval,
+		keepAliveTimeout:  keepAliveTimeout,
+		logger: logrus.WithFields(logrus.Fields{
+			"server": opt.Server,
+			"port":   opt.Port,
+		}),
+	}
+}
+
+// Run forwards the ports until the context is canceled.
+func (f *PortForwarder) Run(ctx context.Context) error {
+	for _, fw := range f.forwards {
+		if fw.Reverse {
+			continue
+		}
+		l, err := net.Listen("tcp", fw.LocalAddr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to listen on %s", fw.LocalAddr)
+		}
+		go func() {
+			<-ctx.Done()
+			l.Close()
+		}()
+		go f.acceptLocal(l, fw)
+	}
+
+	backoff := minReconnectBackoff
+	for {
+		cli, _, err := dial(f.opt)
+		if err == nil {
+			backoff = minReconnectBackoff
+			f.serve(ctx, cli)
+			if ctx.Err() != nil {
+				return nil
+			}
+			f.logger.Warn("the ssh connection is lost, reconnecting")
+		} else {
+			f.logger.WithError(err).Warnf("failed to connect, retrying in %s", backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if err != nil {
+			backoff *= 2
+			if backoff > maxReconnectBackoff {
+				backoff = maxReconnectBackoff
+			}
+		}
+	}
+}
+
+// serve sets up the reverse forwards on the connection, and blocks until
+// the connection drops or the context is canceled.
+func (f *PortForwarder) serve(ctx context.Context, cli *ssh.Client) {
+	f.setClient(cli)
+	defer f.setClient(nil)
+	defer cli.Close()
+
+	for _, fw := range f.forwards {
+		if !fw.Reverse {
+			continue
+		}
+		l, err := cli.Listen("tcp", fw.RemoteAddr)
+		if err != nil {
+			f.logger.WithError(err).Errorf("failed to forward %s", fw)
+			continue
+		}
+		go f.acceptRemote(l, fw)
+	}
+	f.logger.Debug("the ssh connection is established")
+
+	closed := make(chan struct{})
+	go func() {
+		_ = cli.Wait()
+		close(closed)
+	}()
+	ticker := time.NewTicker(f.keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := keepAlive(cli, f.keepAliveTimeout); err != nil {
+				f.logger.WithError(err).Debug("the keepalive failed")
+				return
+			}
+		}
+	}
+}
+
+// keepAlive checks the connection. The server replies false to the unknown
+// request, the error or the timeout means the connection is broken. The
+// pending request returns once the client is closed.
+func keepAlive(cli *ssh.Client, timeout time.Duration) error {
+	errC := make(chan error, 1)
+	go func() {
+		_, _, err := cli.SendRequest("keepalive@openssh.com", true, nil)
+		errC <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errC:
+		return err
+	case <-timer.C:
+		return errors.Newf("no keepalive reply in %s", timeout)
+	}
+}
+
+func (f *PortForwarder) setClient(cli *ssh.Client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cli = cli
+}
+
+func (f *PortForwarder) client() *ssh.Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cli
+}
+
+func (f *PortForwarder) acceptLocal(l net.Listener, fw Forward) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			cli := f.client()
+			if cli == nil {
+				f.logger.Warnf("the ssh connection is not ready, dropping the connection to %s", fw.LocalAddr)
+				conn.Close()
+				return
+			}
+			remote, err := cli.Dial("tcp", fw.RemoteAddr)
+			if err != nil {
+				f.logger.WithError(err).Warnf("failed to forward the connection to %s", fw.RemoteAddr)
+				conn.Close()
+				return
+			}
+			pipe(conn, remote)
+		}()
+	}
+}
+
+func (f *PortForwarder) acceptRemote(l net.Listener, fw Forward) {
+	// The listener is closed with the connection.
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			local, err := net.Dial("tcp", fw.LocalAddr)
+			if err != nil {
+				f.logger.WithError(err).Warnf("failed to forward the connection to %s", fw.LocalAddr)
+				conn.Close()
+				return
+			}
+			pipe(conn, local)
+		}()
+	}
+}
+
+// pipe copies the data between the connections until one of them is closed.
+func pipe(a, b net.Conn) {
+	defer a.Close()
+	defer b.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
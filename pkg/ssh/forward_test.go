@@ -0,0 +1,209 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	rawssh "github.com/gliderlabs/ssh"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// startServer starts the ssh server without authentication, which supports
+// the local and reverse port forwarding.
+func startServer(addr string) *rawssh.Server {
+	forwardHandler := &rawssh.ForwardedTCPHandler{}
+	srv := &rawssh.Server{
+		Addr: addr,
+		ChannelHandlers: map[string]rawssh.ChannelHandler{
+			"direct-tcpip": rawssh.DirectTCPIPHandler,
+		},
+		LocalPortForwardingCallback: func(ctx rawssh.Context, host string, port uint32) bool {
+			return true
+		},
+		ReversePortForwardingCallback: func(ctx rawssh.Context, host string, port uint32) bool {
+			return true
+		},
+		RequestHandlers: map[string]rawssh.RequestHandler{
+			"tcpip-forward":        forwardHandler.HandleSSHRequest,
+			"cancel-tcpip-forward": forwardHandler.HandleSSHRequest,
+		},
+	}
+	l, err := net.Listen("tcp", addr)
+	Expect(err).NotTo(HaveOccurred())
+	go srv.Serve(l)
+	return srv
+}
+
+// startEcho starts the echo server and returns its port.
+func startEcho() (string, net.Listener) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), l
+}
+
+func freePort() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func echo(addr string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return "", err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+var _ = Describe("port forward", func() {
+	var backoff time.Duration
+	BeforeEach(func() {
+		backoff = minReconnectBackoff
+		minReconnectBackoff = 10 * time.Millisecond
+	})
+	AfterEach(func() {
+		minReconnectBackoff = backoff
+	})
+
+	It("should parse the port forwards", func() {
+		f, err := ParseForward("6006", "localhost", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(Equal(Forward{LocalAddr: "localhost:6006", RemoteAddr: "localhost:6006"}))
+
+		f, err = ParseForward("8080:6006", "0.0.0.0", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(Equal(Forward{LocalAddr: "0.0.0.0:8080", RemoteAddr: "localhost:6006"}))
+
+		f, err = ParseForward("9000:3000", "localhost", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(Equal(Forward{Reverse: true, LocalAddr: "localhost:3000", RemoteAddr: "localhost:9000"}))
+
+		_, err = ParseForward("a:1", "localhost", false)
+		Expect(err).To(HaveOccurred())
+		_, err = ParseForward("1:2:3", "localhost", false)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should forward the ports and reconnect", func() {
+		echoPort, echoListener := startEcho()
+		defer echoListener.Close()
+
+		sshAddr := "127.0.0.1:" + freePort()
+		srv := startServer(sshAddr)
+		port, _ := strconv.Atoi(sshAddr[len("127.0.0.1:"):])
+
+		localPort, remotePort := freePort(), freePort()
+		local, err := ParseForward(localPort+":"+echoPort, "127.0.0.1", false)
+		Expect(err).NotTo(HaveOccurred())
+		reverse, err := ParseForward(remotePort+":"+echoPort, "127.0.0.1", true)
+		Expect(err).NotTo(HaveOccurred())
+
+		opt := DefaultOptions()
+		opt.Server, opt.Port, opt.Auth = "127.0.0.1", port, false
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = NewPortForwarder(opt, []Forward{local, reverse}).Run(ctx)
+		}()
+
+		Eventually(func() (string, error) {
+			return echo("127.0.0.1:" + localPort)
+		}, 5*time.Second, 20*time.Millisecond).Should(Equal("ping"))
+		// The server and the host share the network in the test.
+		Eventually(func() (string, error) {
+			return echo("127.0.0.1:" + remotePort)
+		}, 5*time.Second, 20*time.Millisecond).Should(Equal("ping"))
+
+		Expect(srv.Close()).To(Succeed())
+		srv = startServer(sshAddr)
+		defer srv.Close()
+		Eventually(func() (string, error) {
+			return echo("127.0.0.1:" + localPort)
+		}, 5*time.Second, 20*time.Millisecond).Should(Equal("ping"))
+	})
+
+	It("should reconnect if the keepalive is not replied", func() {
+
+		// The server never replies the keepalive as if the connection
+		// is half-open.
+		var conns int32
+		hang := make(chan struct{})
+		defer close(hang)
+		sshAddr := "127.0.0.1:" + freePort()
+		srv := &rawssh.Server{
+			Addr: sshAddr,
+			ConnCallback: func(ctx rawssh.Context, conn net.Conn) net.Conn {
+				atomic.AddInt32(&conns, 1)
+				return conn
+			},
+			RequestHandlers: map[string]rawssh.RequestHandler{
+				"keepalive@openssh.com": func(ctx rawssh.Context, srv *rawssh.Server,
+					req *gossh.Request) (bool, []byte) {
+					<-hang
+					return false, nil
+				},
+			},
+		}
+		l, err := net.Listen("tcp", sshAddr)
+		Expect(err).NotTo(HaveOccurred())
+		go srv.Serve(l)
+		defer srv.Close()
+		port, _ := strconv.Atoi(sshAddr[len("127.0.0.1:"):])
+
+		opt := DefaultOptions()
+		opt.Server, opt.Port, opt.Auth = "127.0.0.1", port, false
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		fw := NewPortForwarder(opt, nil)
+		fw.keepAliveInterval, fw.keepAliveTimeout = 20*time.Millisecond, 50*time.Millisecond
+		go func() {
+			_ = fw.Run(ctx)
+		}()
+
+		Eventually(func() int32 {
+			return atomic.LoadInt32(&conns)
+		}, 5*time.Second, 20*time.Millisecond).Should(BeNumerically(">=", 2))
+	})
+})
@@ -20,6 +20,7 @@
 package ssh
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -31,6 +32,7 @@ import (
 
 	"github.com/alessio/shellescape"
 	"github.com/cockroachdb/errors"
+	"github.com/pkg/sftp"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -43,6 +45,11 @@ import (
 type Client interface {
 	Attach() error
 	ExecWithOutput(cmd string) ([]byte, error)
+	// Output runs the command and returns the stdout, the connection is
+	// kept open.
+	Output(cmd string) ([]byte, error)
+	// SFTP opens the sftp subsystem on the connection.
+	SFTP() (*sftp.Client, error)
 	Close() error
 }
 
@@ -84,7 +91,8 @@ type generalClient struct {
 	cli *ssh.Client
 }
 
-func NewClient(opt Options) (Client, error) {
+// dial opens the SSH connection to the server.
+func dial(opt Options) (*ssh.Client, *ssh.ClientConfig, error) {
 	config := &ssh.ClientConfig{
 		User: opt.User,
 		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
@@ -93,32 +101,55 @@ func NewClient(opt Options) (Client, error) {
 		},
 	}
 
-	var cli *ssh.Client
-
 	if opt.Auth {
 		// read private key file
 		pemBytes, err := ioutil.ReadFile(opt.PrivateKeyPath)
 		if err != nil {
-			return nil, errors.Wrapf(
+			return nil, nil, errors.Wrapf(
 				err, "reading private key %s failed", opt.PrivateKeyPath)
 		}
 		// create signer
 		signer, err := signerFromPem(pemBytes, []byte(opt.PrivateKeyPwd))
-		if err != nil {
-			return nil, errors.Wrap(err, "creating signer from private key failed")
-		}
-		config.Auth = []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
+		var missing *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &missing):
+			// The encrypted key is used via the agent, e.g. added by ssh-add.
+			publicKey := missing.PublicKey
+			if publicKey == nil {
+				publicKey = readPublicKey(opt.PrivateKeyPath + ".pub")
+			}
+			agentConn, signers, err := agentSigners(publicKey)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err,
+					"private key %s is encrypted, add it to the ssh agent", opt.PrivateKeyPath)
+			}
+			defer agentConn.Close()
+			config.Auth = []ssh.AuthMethod{
+				ssh.PublicKeys(signers...),
+			}
+		case err != nil:
+			return nil, nil, errors.Wrap(err, "creating signer from private key failed")
+		default:
+			config.Auth = []ssh.AuthMethod{
+				ssh.PublicKeys(signer),
+			}
 		}
 	}
 
 	host := fmt.Sprintf("%s:%d", opt.Server, opt.Port)
 	// open connection
-	conn, err := ssh.Dial("tcp", host, config)
+	cli, err := ssh.Dial("tcp", host, config)
 	if err != nil {
-		return nil, errors.Wrap(err, "dialing failed")
+		return nil, nil, errors.Wrap(err, "dialing failed")
+	}
+	return cli, config, nil
+}
+
+func NewClient(opt Options) (Client, error) {
+	cli, config, err := dial(opt)
+	if err != nil {
+		return nil, err
 	}
-	cli = conn
 
 	// open connection to the local agent
 	socketLocation := os.Getenv("SSH_AUTH_SOCK")
@@ -164,6 +195,23 @@ func (c generalClient) ExecWithOutput(cmd string) ([]byte, error) {
 	return session.CombinedOutput(cmd)
 }
 
+func (c generalClient) Output(cmd string) ([]byte, error) {
+	session, err := c.cli.NewSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating session failed")
+	}
+	defer session.Close()
+	return session.Output(cmd)
+}
+
+func (c generalClient) SFTP() (*sftp.Client, error) {
+	cli, err := sftp.NewClient(c.cli)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating sftp client failed")
+	}
+	return cli, nil
+}
+
 func (c generalClient) Attach() error {
 	// open session
 	session, err := c.cli.NewSession()
@@ -282,6 +330,9 @@ func signerFromPem(pemBytes []byte, password []byte) (ssh.Signer, error) {
 	// handle encrypted key
 	// nolint
 	if x509.IsEncryptedPEMBlock(pemBlock) {
+		if len(password) == 0 {
+			return nil, &ssh.PassphraseMissingError{}
+		}
 		// decrypt PEM
 		// nolint
 		pemBlock.Bytes, err = x509.DecryptPEMBlock(pemBlock, []byte(password))
@@ -303,8 +354,13 @@ func signerFromPem(pemBytes []byte, password []byte) (ssh.Signer, error) {
 
 		return signer, nil
 	} else {
-		// generate signer instance from plain key
+		// generate signer instance from plain key, or the OpenSSH key
+		// encrypted with the password
 		signer, err := ssh.ParsePrivateKey(pemBytes)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) && len(password) != 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, password)
+		}
 		if err != nil {
 			return nil, errors.Newf("parsing plain private key failed %w", err)
 		}
@@ -340,3 +396,45 @@ func parsePemBlock(block *pem.Block) (interface{}, error) {
 		return nil, errors.Newf("Parsing private key failed, unsupported key type %q", block.Type)
 	}
 }
+
+// agentSigners returns the signers of the key in the ssh agent, or all the
+// keys in the agent if the public key is nil.
+func agentSigners(publicKey ssh.PublicKey) (net.Conn, []ssh.Signer, error) {
+	socketLocation := os.Getenv("SSH_AUTH_SOCK")
+	if socketLocation == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK is not set")
+	}
+	agentConn, err := net.Dial("unix", socketLocation)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not connect to local agent socket")
+	}
+	signers, err := agent.NewClient(agentConn).Signers()
+	if err != nil {
+		agentConn.Close()
+		return nil, nil, errors.Wrap(err, "listing the keys in the agent failed")
+	}
+	matched := []ssh.Signer{}
+	for _, s := range signers {
+		if publicKey == nil || bytes.Equal(s.PublicKey().Marshal(), publicKey.Marshal()) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) == 0 {
+		agentConn.Close()
+		return nil, nil, errors.New("the key is not found in the agent")
+	}
+	return agentConn, matched, nil
+}
+
+// readPublicKey returns nil if the public key cannot be read.
+func readPublicKey(path string) ssh.PublicKey {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil
+	}
+	return key
+}
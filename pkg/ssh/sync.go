@@ -0,0 +1,493 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alessio/shellescape"
+	"github.com/cockroachdb/errors"
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+)
+
+// syncDebounce is the delay to batch the file events before syncing.
+var syncDebounce = 200 * time.Millisecond
+
+// Download copies the remote file or directory to the local path, as
+// `cp -r` does.
+func Download(cli *sftp.Client, remote, local string) error {
+	info, err := cli.Stat(remote)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s in the environment", remote)
+	}
+	if st, err := os.Stat(local); err == nil && st.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+	if !info.IsDir() {
+		return downloadFile(cli, remote, local, info.Mode())
+	}
+
+	walker := cli.Walk(remote)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return errors.Wrapf(err, "failed to walk %s in the environment", remote)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), remote), "/")
+		target := filepath.Join(local, filepath.FromSlash(rel))
+		st := walker.Stat()
+		switch {
+		case st.IsDir():
+			if err := os.MkdirAll(target, st.Mode().Perm()|0700); err != nil {
+				return errors.Wrapf(err, "failed to create %s", target)
+			}
+		case st.Mode().IsRegular():
+			if err := downloadFile(cli, walker.Path(), target, st.Mode()); err != nil {
+				return err
+			}
+		default:
+			logrus.Debugf("skip %s since it is not a regular file", walker.Path())
+		}
+	}
+	return nil
+}
+
+func downloadFile(cli *sftp.Client, remote, local string, mode os.FileMode) error {
+	src, err := cli.Open(remote)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s in the environment", remote)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(local, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", local)
+	}
+	defer dst.Close()
+	if _, err := src.WriteTo(dst); err != nil {
+		return errors.Wrapf(err, "failed to download %s", remote)
+	}
+	return nil
+}
+
+// Upload copies the local file or directory to the remote path, as `cp -r`
+// does.
+func Upload(cli *sftp.Client, local, remote string) error {
+	info, err := os.Stat(local)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", local)
+	}
+	if st, err := cli.Stat(remote); err == nil && st.IsDir() {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+	if !info.IsDir() {
+		return uploadFile(cli, local, remote, info.Mode())
+	}
+
+	return filepath.Walk(local, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(remote, filepath.ToSlash(rel))
+		switch {
+		case info.IsDir():
+			if err := cli.MkdirAll(target); err != nil {
+				return errors.Wrapf(err, "failed to create %s in the environment", target)
+			}
+		case info.Mode().IsRegular():
+			return uploadFile(cli, p, target, info.Mode())
+		default:
+			logrus.Debugf("skip %s since it is not a regular file", p)
+		}
+		return nil
+	})
+}
+
+func uploadFile(cli *sftp.Client, local, remote string, mode os.FileMode) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", local)
+	}
+	defer src.Close()
+	dst, err := cli.Create(remote)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s in the environment", remote)
+	}
+	defer dst.Close()
+	if _, err := dst.ReadFrom(src); err != nil {
+		return errors.Wrapf(err, "failed to upload %s", local)
+	}
+	return cli.Chmod(remote, mode.Perm())
+}
+
+// SyncOptions configures the Syncer.
+type SyncOptions struct {
+	// Ignore are the patterns of the files not synced, in the
+	// .dockerignore format.
+	Ignore []string
+	// Delete removes the remote files which do not exist locally.
+	Delete bool
+}
+
+// SyncResult is the summary of a sync.
+type SyncResult struct {
+	Uploaded int
+	Deleted  int
+}
+
+// Syncer synchronizes the local directory to the remote directory over
+// sftp. The files are compared by SHA256, thus only the changed files are
+// uploaded.
+type Syncer struct {
+	cli    Client
+	sftp   *sftp.Client
+	local  string
+	remote string
+	delete bool
+	ignore *fileutils.PatternMatcher
+	logger *logrus.Entry
+
+	// hashes are the SHA256 of the remote files, keyed by the slash
+	// separated path relative to the remote directory.
+	hashes map[string]string
+}
+
+func NewSyncer(cli Client, local, remote string, opt SyncOptions) (*Syncer, error) {
+	ignore, err := fileutils.NewPatternMatcher(opt.Ignore)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ignore patterns")
+	}
+	local, err = filepath.Abs(local)
+	if err != nil {
+		return nil, err
+	}
+	sftpClient, err := cli.SFTP()
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{
+		cli:    cli,
+		sftp:   sftpClient,
+		local:  local,
+		remote: remote,
+		delete: opt.Delete,
+		ignore: ignore,
+		logger: logrus.WithFields(logrus.Fields{
+			"local":  local,
+			"remote": remote,
+		}),
+		hashes: map[string]string{},
+	}, nil
+}
+
+func (s *Syncer) Close() error {
+	return s.sftp.Close()
+}
+
+// Sync compares the local directory with the remote one, and uploads the
+// changed files.
+func (s *Syncer) Sync() (SyncResult, error) {
+	res := SyncResult{}
+	hashes, err := s.remoteHashes()
+	if err != nil {
+		return res, err
+	}
+	s.hashes = hashes
+
+	existing := map[string]bool{}
+	if err := s.walk(s.local, func(p, rel string, info os.FileInfo) error {
+		existing[rel] = true
+		uploaded, err := s.syncFile(p, rel, info)
+		if uploaded {
+			res.Uploaded++
+		}
+		return err
+	}); err != nil {
+		return res, err
+	}
+
+	if !s.delete {
+		return res, nil
+	}
+	removed := []string{}
+	for rel := range s.hashes {
+		if !existing[rel] && !s.ignored(rel) {
+			removed = append(removed, rel)
+		}
+	}
+	sort.Strings(removed)
+	for _, rel := range removed {
+		if err := s.removeFile(rel); err != nil {
+			return res, err
+		}
+		res.Deleted++
+	}
+	return res, nil
+}
+
+// Watch syncs the changed files until the context is canceled. It should be
+// called after Sync.
+func (s *Syncer) Watch(ctx context.Context, onSync func(SyncResult)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create the file watcher")
+	}
+	defer watcher.Close()
+	if err := s.watchDir(watcher, s.local); err != nil {
+		return err
+	}
+
+	dirty := map[string]bool{}
+	timer := time.NewTimer(syncDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			dirty[event.Name] = true
+			timer.Reset(syncDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			s.logger.WithError(err).Warn("error from the file watcher")
+		case <-timer.C:
+			res, err := s.syncPaths(watcher, dirty)
+			if err != nil {
+				s.logger.WithError(err).Warn("failed to sync the changes")
+			}
+			dirty = map[string]bool{}
+			if onSync != nil && (res.Uploaded != 0 || res.Deleted != 0) {
+				onSync(res)
+			}
+		}
+	}
+}
+
+func (s *Syncer) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if rel := s.rel(p); rel != "." && s.ignored(rel) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(p); err != nil {
+			return errors.Wrapf(err, "failed to watch %s", p)
+		}
+		return nil
+	})
+}
+
+// syncPaths syncs the changed files or directories.
+func (s *Syncer) syncPaths(watcher *fsnotify.Watcher, paths map[string]bool) (SyncResult, error) {
+	res := SyncResult{}
+	sorted := []string{}
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		rel := s.rel(p)
+		if rel == "." || strings.HasPrefix(rel, "../") || s.ignored(rel) {
+			continue
+		}
+		info, err := os.Lstat(p)
+		switch {
+		case os.IsNotExist(err):
+			if !s.delete {
+				continue
+			}
+			for _, f := range s.remoteFilesUnder(rel) {
+				if err := s.removeFile(f); err != nil {
+					return res, err
+				}
+				res.Deleted++
+			}
+		case err != nil:
+			return res, err
+		case info.IsDir():
+			if err := s.watchDir(watcher, p); err != nil {
+				return res, err
+			}
+			if err := s.walk(p, func(p, rel string, info os.FileInfo) error {
+				uploaded, err := s.syncFile(p, rel, info)
+				if uploaded {
+					res.Uploaded++
+				}
+				return err
+			}); err != nil {
+				return res, err
+			}
+		default:
+			uploaded, err := s.syncFile(p, rel, info)
+			if err != nil {
+				return res, err
+			}
+			if uploaded {
+				res.Uploaded++
+			}
+		}
+	}
+	return res, nil
+}
+
+// walk calls fn for the regular files under the directory which are not
+// ignored.
+func (s *Syncer) walk(dir string, fn func(p, rel string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := s.rel(p)
+		if rel == "." {
+			return nil
+		}
+		if s.ignored(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return fn(p, rel, info)
+	})
+}
+
+// syncFile uploads the file if its hash differs from the remote one.
+func (s *Syncer) syncFile(p, rel string, info os.FileInfo) (bool, error) {
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+	hash, err := fileHash(p)
+	if err != nil {
+		return false, err
+	}
+	if s.hashes[rel] == hash {
+		return false, nil
+	}
+	target := path.Join(s.remote, rel)
+	if err := s.sftp.MkdirAll(path.Dir(target)); err != nil {
+		return false, errors.Wrapf(err, "failed to create %s in the environment", path.Dir(target))
+	}
+	if err := uploadFile(s.sftp, p, target, info.Mode()); err != nil {
+		return false, err
+	}
+	s.logger.Debugf("uploaded %s", rel)
+	s.hashes[rel] = hash
+	return true, nil
+}
+
+func (s *Syncer) removeFile(rel string) error {
+	target := path.Join(s.remote, rel)
+	if err := s.sftp.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "failed to remove %s in the environment", target)
+	}
+	s.logger.Debugf("removed %s", rel)
+	delete(s.hashes, rel)
+	return nil
+}
+
+// remoteFilesUnder returns the remote file or the files in the remote
+// directory.
+func (s *Syncer) remoteFilesUnder(rel string) []string {
+	files := []string{}
+	for f := range s.hashes {
+		if f == rel || strings.HasPrefix(f, rel+"/") {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// remoteHashes computes the hashes of the remote files with sha256sum over
+// the same connection.
+func (s *Syncer) remoteHashes() (map[string]string, error) {
+	cmd := fmt.Sprintf("cd %s 2>/dev/null && find . -type f -exec sha256sum {} + || true",
+		shellescape.Quote(s.remote))
+	out, err := s.cli.Output(cmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute the hashes of the remote files")
+	}
+	return parseSHA256Sum(out), nil
+}
+
+// parseSHA256Sum parses the output of sha256sum. The escaped file names,
+// which contain backslashes or newlines, are skipped thus always uploaded.
+func parseSHA256Sum(out []byte) map[string]string {
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok || strings.HasPrefix(hash, "\\") {
+			continue
+		}
+		hashes[strings.TrimPrefix(name, "./")] = hash
+	}
+	return hashes
+}
+
+func (s *Syncer) rel(p string) string {
+	rel, err := filepath.Rel(s.local, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (s *Syncer) ignored(rel string) bool {
+	matched, err := s.ignore.MatchesOrParentMatches(filepath.FromSlash(rel))
+	return err == nil && matched
+}
+
+func fileHash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "failed to hash %s", p)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	rawssh "github.com/gliderlabs/ssh"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/sftp"
+)
+
+// startFileServer starts the ssh server without authentication, which
+// runs the commands in sh and serves sftp.
+func startFileServer() (*rawssh.Server, Options) {
+	addr := "127.0.0.1:" + freePort()
+	srv := &rawssh.Server{
+		Addr: addr,
+		Handler: func(s rawssh.Session) {
+			cmd := exec.Command("sh", "-c", s.RawCommand())
+			cmd.Stdout = s
+			cmd.Stderr = s.Stderr()
+			if err := cmd.Run(); err != nil {
+				_ = s.Exit(1)
+				return
+			}
+			_ = s.Exit(0)
+		},
+		SubsystemHandlers: map[string]rawssh.SubsystemHandler{
+			"sftp": func(s rawssh.Session) {
+				server, err := sftp.NewServer(s)
+				if err != nil {
+					return
+				}
+				_ = server.Serve()
+			},
+		},
+	}
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+
+	opt := DefaultOptions()
+	opt.Server, opt.Auth = "127.0.0.1", false
+	opt.Port, _ = strconv.Atoi(addr[len("127.0.0.1:"):])
+	Eventually(func() error {
+		cli, _, err := dial(opt)
+		if err == nil {
+			cli.Close()
+		}
+		return err
+	}, 5*time.Second, 20*time.Millisecond).Should(Succeed())
+	return srv, opt
+}
+
+func writeFile(p, content string) {
+	Expect(os.MkdirAll(filepath.Dir(p), 0755)).To(Succeed())
+	Expect(os.WriteFile(p, []byte(content), 0644)).To(Succeed())
+}
+
+func readFile(p string) string {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+var _ = Describe("sync", func() {
+	var (
+		srv           *rawssh.Server
+		cli           Client
+		local, remote string
+		debounce      time.Duration
+	)
+
+	BeforeEach(func() {
+		debounce = syncDebounce
+		syncDebounce = 10 * time.Millisecond
+		var opt Options
+		srv, opt = startFileServer()
+		raw, _, err := dial(opt)
+		Expect(err).NotTo(HaveOccurred())
+		cli = &generalClient{cli: raw}
+		local, remote = GinkgoT().TempDir(), GinkgoT().TempDir()
+	})
+
+	AfterEach(func() {
+		syncDebounce = debounce
+		cli.Close()
+		srv.Close()
+	})
+
+	It("should copy the directories", func() {
+		writeFile(filepath.Join(local, "src", "a.txt"), "a")
+		writeFile(filepath.Join(local, "src", "sub", "b.txt"), "b")
+		sftpClient, err := cli.SFTP()
+		Expect(err).NotTo(HaveOccurred())
+		defer sftpClient.Close()
+
+		Expect(Upload(sftpClient, filepath.Join(local, "src"), remote)).To(Succeed())
+		Expect(readFile(filepath.Join(remote, "src", "sub", "b.txt"))).To(Equal("b"))
+
+		dst := filepath.Join(local, "dst")
+		Expect(Download(sftpClient, filepath.Join(remote, "src"), dst)).To(Succeed())
+		Expect(readFile(filepath.Join(dst, "a.txt"))).To(Equal("a"))
+		Expect(readFile(filepath.Join(dst, "sub", "b.txt"))).To(Equal("b"))
+	})
+
+	It("should upload the changed files only", func() {
+		writeFile(filepath.Join(local, "a.txt"), "a")
+		writeFile(filepath.Join(local, "sub", "b.txt"), "b")
+		writeFile(filepath.Join(local, "data", "big.bin"), "ignored")
+		writeFile(filepath.Join(remote, "sub", "b.txt"), "b")
+		writeFile(filepath.Join(remote, "stale.txt"), "stale")
+
+		syncer, err := NewSyncer(cli, local, remote, SyncOptions{
+			Ignore: []string{"data"},
+			Delete: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer syncer.Close()
+
+		res, err := syncer.Sync()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(SyncResult{Uploaded: 1, Deleted: 1}))
+		Expect(readFile(filepath.Join(remote, "a.txt"))).To(Equal("a"))
+		Expect(filepath.Join(remote, "stale.txt")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(remote, "data")).NotTo(BeADirectory())
+
+		res, err = syncer.Sync()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(SyncResult{}))
+	})
+
+	It("should sync the changes when watching", func() {
+		writeFile(filepath.Join(local, "a.txt"), "a")
+		syncer, err := NewSyncer(cli, local, remote, SyncOptions{Delete: true})
+		Expect(err).NotTo(HaveOccurred())
+		defer syncer.Close()
+		_, err = syncer.Sync()
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- syncer.Watch(ctx, nil)
+		}()
+		defer func() {
+			cancel()
+			Expect(<-done).To(Succeed())
+		}()
+
+		// Wait for the watcher to be ready.
+		time.Sleep(100 * time.Millisecond)
+		writeFile(filepath.Join(local, "a.txt"), "changed")
+		writeFile(filepath.Join(local, "new", "b.txt"), "b")
+		Eventually(func() string {
+			return readFile(filepath.Join(remote, "a.txt"))
+		}, 5*time.Second, 20*time.Millisecond).Should(Equal("changed"))
+		Eventually(func() string {
+			return readFile(filepath.Join(remote, "new", "b.txt"))
+		}, 5*time.Second, 20*time.Millisecond).Should(Equal("b"))
+
+		Expect(os.Remove(filepath.Join(local, "a.txt"))).To(Succeed())
+		Eventually(filepath.Join(remote, "a.txt"), 5*time.Second, 20*time.Millisecond).
+			ShouldNot(BeAnExistingFile())
+	})
+})
@@ -16,6 +16,7 @@ package types
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/docker/docker/api/types"
 )
@@ -56,6 +57,21 @@ type Context struct {
 	Name          string      `json:"name,omitempty"`
 	Builder       BuilderType `json:"builder,omitempty"`
 	BuilderSocket string      `json:"builder_socket,omitempty"`
+	Runner        RunnerType  `json:"runner,omitempty"`
+	// RunnerAddress is the address of the runner API, e.g.
+	// unix:///run/podman/podman.sock, or the path to the kubeconfig of the
+	// kube-pod context. The default address is used if empty.
+	RunnerAddress string `json:"runner_address,omitempty"`
+	// RunnerNamespace is the namespace of the environments of the kube-pod
+	// context.
+	RunnerNamespace string `json:"runner_namespace,omitempty"`
+}
+
+// RunsOnKubernetes returns true if the environments of the context run as
+// pods. The images are built by the kube-pod builder and pushed to the
+// registry, the runner only manages the local images.
+func (c Context) RunsOnKubernetes() bool {
+	return c.Builder == BuilderTypeKubernetes
 }
 
 type BuilderType string
@@ -66,6 +82,14 @@ const (
 	BuilderTypeTCP        BuilderType = "tcp"
 )
 
+// RunnerType is the container runtime which runs the environments.
+type RunnerType string
+
+const (
+	RunnerTypeDocker RunnerType = "docker"
+	RunnerTypePodman RunnerType = "podman"
+)
+
 type Dependency struct {
 	APTPackages  []string `json:"apt_packages,omitempty"`
 	PyPIPackages []string `json:"pypi_packages,omitempty"`
@@ -78,6 +102,26 @@ type PortBinding struct {
 	HostPort string
 }
 
+// Dataset is a data dir in the envd cache dir, created by data.envd or
+// downloaded by the other data sources.
+type Dataset struct {
+	Name  string `json:"name,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Files int    `json:"files,omitempty"`
+	// LastUsed is the last time an environment mounting the dataset was
+	// started, or the last modification of the dataset.
+	LastUsed time.Time `json:"last_used,omitempty"`
+	// Mounts are the environments mounting the dataset.
+	Mounts []DatasetMount `json:"mounts,omitempty"`
+}
+
+type DatasetMount struct {
+	Environment string `json:"environment,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Running     bool   `json:"running,omitempty"`
+}
+
 func NewImage(image types.ImageSummary) (*EnvdImage, error) {
 	img := EnvdImage{
 		ImageSummary: image,
@@ -197,3 +241,22 @@ func parsePyPIPackages(lst string) ([]string, error) {
 	err := json.Unmarshal([]byte(lst), &pkgs)
 	return pkgs, err
 }
+
+// RuleDigest is the digest of the inputs of a build rule, it is used to find
+// the rule which invalidates the cache of the previous build.
+type RuleDigest struct {
+	Rule   string `json:"rule"`
+	Digest string `json:"digest"`
+}
+
+// RuleDigestsFromLabels returns the rule digests of the image, or nil if the
+// image is built by an older envd.
+func RuleDigestsFromLabels(labels map[string]string) ([]RuleDigest, error) {
+	str, ok := labels[ImageLabelRules]
+	if !ok {
+		return nil, nil
+	}
+	var digests []RuleDigest
+	err := json.Unmarshal([]byte(str), &digests)
+	return digests, err
+}
@@ -29,6 +29,12 @@ const (
 	ImageLabelCUDNN     = "ai.tensorchord.envd.gpu.cudnn"
 	ImageLabelContext   = "ai.tensorchord.envd.build.context"
 	ImageLabelCacheHash = "ai.tensorchord.envd.build.digest"
+	ImageLabelRules     = "ai.tensorchord.envd.build.rules"
 
 	ImageVendorEnvd = "envd"
+
+	// VolumeLabelCopyOnWrite is the environment of the copy-on-write volume.
+	VolumeLabelCopyOnWrite = "ai.tensorchord.envd.volume.cow"
+	// VolumeLabelCopyOnWriteSource is the lower dir of the copy-on-write volume.
+	VolumeLabelCopyOnWriteSource = "ai.tensorchord.envd.volume.cow.source"
 )
@@ -0,0 +1,172 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asciicast
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	version = 2
+
+	EventOutput = "o"
+	EventResize = "r"
+)
+
+// Header is the first line of the recording.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes the output of the terminal as the events.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	// pending is the incomplete UTF-8 sequence at the end of the last write.
+	pending []byte
+}
+
+// NewRecorder writes the header and returns the recorder.
+func NewRecorder(w io.Writer, width, height int, env map[string]string) (*Recorder, error) {
+	start := time.Now()
+	header, err := json.Marshal(Header{
+		Version:   version,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Env:       env,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", header); err != nil {
+		return nil, errors.Wrap(err, "failed to write the asciicast header")
+	}
+	return &Recorder{w: w, start: start}, nil
+}
+
+// Write records the output.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.pending, p...)
+	// Keep the incomplete rune for the next write, the events are strings.
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[end:]...)
+	if end == 0 {
+		return len(p), nil
+	}
+	if err := r.event(EventOutput, string(data[:end])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize records the new size of the terminal.
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.event(EventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) event(typ, data string) error {
+	elapsed := time.Since(r.start).Seconds()
+	line, err := json.Marshal([]interface{}{elapsed, typ, data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, "%s\n", line)
+	return err
+}
+
+// Play writes the output in the recording to w with the original timing,
+// which is accelerated by speed. The idle time between the events is
+// limited to maxIdle if it is positive.
+func Play(ctx context.Context, r io.Reader, w io.Writer, speed float64, maxIdle time.Duration) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return errors.New("empty asciicast recording")
+	}
+	header := Header{}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return errors.Wrap(err, "failed to parse the asciicast header")
+	}
+	if header.Version != version {
+		return errors.Newf("unsupported asciicast version %d", header.Version)
+	}
+
+	last := 0.0
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return errors.Wrap(err, "failed to parse the asciicast event")
+		}
+		if len(event) != 3 {
+			return errors.Newf("invalid asciicast event %s", scanner.Text())
+		}
+		t, ok := event[0].(float64)
+		typ, _ := event[1].(string)
+		data, _ := event[2].(string)
+		if !ok {
+			return errors.Newf("invalid asciicast event %s", scanner.Text())
+		}
+
+		delay := time.Duration((t - last) * float64(time.Second))
+		last = t
+		if maxIdle > 0 && delay > maxIdle {
+			delay = maxIdle
+		}
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(float64(delay) / speed)):
+			}
+		}
+		if typ != EventOutput {
+			continue
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, 80, 24, map[string]string{"TERM": "xterm"})
+	assert.NoError(t, err)
+
+	_, err = r.Write([]byte("hello "))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Resize(100, 30))
+	// The rune is split into two writes.
+	world := []byte("wörld\r\n")
+	_, err = r.Write(world[:2])
+	assert.NoError(t, err)
+	_, err = r.Write(world[2:])
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[0], `"version":2`)
+	assert.Contains(t, lines[2], `"r","100x30"`)
+	assert.Contains(t, lines[4], `"o","örld\r\n"`)
+
+	var out bytes.Buffer
+	assert.NoError(t, Play(context.Background(), &buf, &out, 1000, time.Millisecond))
+	assert.Equal(t, "hello wörld\r\n", out.String())
+}
+
+func TestPlayInvalid(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, Play(context.Background(), strings.NewReader(`{"version":1}`), &out, 1, 0))
+	assert.Error(t, Play(context.Background(), strings.NewReader(""), &out, 1, 0))
+}
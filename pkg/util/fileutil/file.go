@@ -144,6 +144,7 @@ func validateAndJoin(dir, file string) (string, error) {
 }
 
 // DownloadOrUpdateGitRepo downloads (if not exist) or update (if exist)
+// The private repos are accessed over SSH with the keys in the SSH agent.
 func DownloadOrUpdateGitRepo(url string) (path string, err error) {
 	logger := logrus.WithField("git", url)
 	path = filepath.Join(DefaultEnvdLibDir, strings.ReplaceAll(url, "/", "_"))
@@ -154,11 +155,7 @@ func DownloadOrUpdateGitRepo(url string) (path string, err error) {
 	}
 	if !exist {
 		logger.Debugf("clone repo to %s", path)
-		// check https://github.com/go-git/go-git/issues/305
-		_, err = git.PlainClone(path, false, &git.CloneOptions{
-			URL: url,
-		})
-		if err != nil {
+		if err = cloneGitRepo(path, url); err != nil {
 			return
 		}
 	} else {
@@ -167,17 +164,63 @@ func DownloadOrUpdateGitRepo(url string) (path string, err error) {
 		if err != nil {
 			return
 		}
-		var wt *git.Worktree
-		wt, err = repo.Worktree()
-		if err != nil {
-			return
-		}
 		logger.Debug("try to pull latest")
-		err = wt.Pull(&git.PullOptions{})
-		if err != nil && errors.Is(err, git.NoErrAlreadyUpToDate) {
-			return
+		// The cached repo is used if it fails to pull, e.g. offline.
+		if err := pullGitRepo(repo); err != nil {
+			logger.Warnf("failed to pull the latest repo: %s", err)
 		}
 	}
 
 	return path, nil
 }
+
+// pullGitRepo pulls the repo, with the ssh agent if it is cloned over SSH.
+func pullGitRepo(repo *git.Repository) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return err
+	}
+	opt := &git.PullOptions{}
+	if urls := remote.Config().URLs; len(urls) != 0 && isGitSSHURL(urls[0]) {
+		if opt.Auth, err = gitSSHAuth(urls[0]); err != nil {
+			return err
+		}
+	}
+	if err := wt.Pull(opt); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// cloneGitRepo clones the repo, and falls back to SSH if the HTTP URL
+// requires authentication.
+func cloneGitRepo(path, url string) error {
+	opt := &git.CloneOptions{URL: url}
+	if isGitSSHURL(url) {
+		auth, err := gitSSHAuth(url)
+		if err != nil {
+			return err
+		}
+		opt.Auth = auth
+	}
+	// check https://github.com/go-git/go-git/issues/305
+	_, err := git.PlainClone(path, false, opt)
+	if err == nil || !isGitAuthError(err) {
+		return err
+	}
+	sshURL, ok := gitSSHURL(url)
+	if !ok {
+		return err
+	}
+	logrus.WithField("git", url).Debugf("authentication required, clone with ssh %s", sshURL)
+	auth, authErr := gitSSHAuth(sshURL)
+	if authErr != nil {
+		return errors.Wrapf(err, "failed to fall back to ssh: %s", authErr)
+	}
+	_, err = git.PlainClone(path, false, &git.CloneOptions{URL: sshURL, Auth: auth})
+	return err
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const defaultGitSSHUser = "git"
+
+// isGitSSHURL returns true for the SSH URLs, e.g. git@github.com:org/repo
+// and ssh://git@github.com/org/repo.
+func isGitSSHURL(url string) bool {
+	ep, err := transport.NewEndpoint(url)
+	return err == nil && ep.Protocol == "ssh"
+}
+
+// gitSSHURL converts the HTTP URL to the SSH one, e.g.
+// https://github.com/org/repo to git@github.com:org/repo.
+func gitSSHURL(url string) (string, bool) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil || (ep.Protocol != "https" && ep.Protocol != "http") {
+		return "", false
+	}
+	return fmt.Sprintf("%s@%s:%s", defaultGitSSHUser, ep.Host,
+		strings.TrimPrefix(ep.Path, "/")), true
+}
+
+// gitSSHAuth authenticates with the keys in the SSH agent of the host.
+func gitSSHAuth(url string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the git url %s", url)
+	}
+	user := ep.User
+	if user == "" {
+		user = defaultGitSSHUser
+	}
+	auth, err := gitssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to use the ssh agent")
+	}
+	return auth, nil
+}
+
+// isGitAuthError returns true if the repo may be private. GitHub returns
+// not found instead of unauthorized for the private repos.
+func isGitAuthError(err error) bool {
+	return errors.Is(err, transport.ErrAuthenticationRequired) ||
+		errors.Is(err, transport.ErrAuthorizationFailed) ||
+		errors.Is(err, transport.ErrRepositoryNotFound)
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitSSHURL(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		isSSH    bool
+		expected string
+	}{
+		{"https://github.com/tensorchord/envdlib", false, "git@github.com:tensorchord/envdlib"},
+		{"https://github.com/tensorchord/envdlib.git", false, "git@github.com:tensorchord/envdlib.git"},
+		{"git@github.com:tensorchord/envdlib.git", true, ""},
+		{"ssh://git@gitlab.com/org/lib", true, ""},
+		{"/tmp/envdlib", false, ""},
+	} {
+		require.Equal(t, tc.isSSH, isGitSSHURL(tc.url), tc.url)
+		url, ok := gitSSHURL(tc.url)
+		require.Equal(t, tc.expected != "", ok, tc.url)
+		require.Equal(t, tc.expected, url, tc.url)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 The envd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofrs/flock"
+)
+
+const (
+	lockTimeout    = 30 * time.Second
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// WithLock runs fn while holding the advisory lock <file>.lock, so that
+// concurrent envd processes do not clobber each other's changes.
+func WithLock(file string, fn func() error) error {
+	return WithLockFile(file+".lock", fn)
+}
+
+// WithLockFile runs fn while holding the advisory lock file, it is used
+// when the lock cannot be kept next to the file, e.g. in the user's ssh dir.
+func WithLockFile(lockFile string, fn func() error) error {
+	l := flock.New(lockFile)
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	locked, err := l.TryLockContext(ctx, lockRetryDelay)
+	if err != nil {
+		return errors.Wrapf(err, "failed to lock %s", lockFile)
+	}
+	if !locked {
+		return errors.Newf("failed to lock %s", lockFile)
+	}
+	defer l.Unlock()
+	return fn()
+}
+
+// WriteFileAtomic writes the data to a temp file in the same dir and renames
+// it to the file, so that the readers never see a partial file.
+func WriteFileAtomic(file string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write %s", f.Name())
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", f.Name())
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return errors.Wrapf(err, "failed to change the mode of %s", f.Name())
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		return errors.Wrapf(err, "failed to rename %s", f.Name())
+	}
+	return nil
+}